@@ -0,0 +1,267 @@
+// Package auth implements api keys authentication: scopes, per key rate limits and daily quotas
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+)
+
+// Scopes of api keys
+const (
+	ScopeTrace      = "trace"
+	ScopeScreenshot = "screenshot"
+	ScopeRead       = "read"
+)
+
+// Scopes is a list of all supported scopes
+var Scopes = []string{ScopeTrace, ScopeScreenshot, ScopeRead}
+
+// Error codes of rejected requests
+const (
+	ErrorCodeUnauthorized  = "unauthorized"
+	ErrorCodeForbidden     = "forbidden"
+	ErrorCodeRateLimited   = "rate_limited"
+	ErrorCodeQuotaExceeded = "quota_exceeded"
+	errorCodeInternal      = "internal_error"
+)
+
+// api key is sent in `X-API-Key` header or `api_key` query param,
+// admin token is sent in `Authorization: Bearer <token>` header
+const (
+	KeyHeader     = "X-API-Key"
+	KeyQueryParam = "api_key"
+	bearerPrefix  = "Bearer "
+)
+
+// keyLookupTimeout limits api key loading and usage counting
+const keyLookupTimeout = 5 * time.Second
+
+// keyContextKey is a request context key of authenticated api key
+type keyContextKey struct{}
+
+// Limits are default rate limit (requests per minute) and daily quota of issued keys, 0 - unlimited
+type Limits struct {
+	RateLimit  int
+	DailyQuota int
+}
+
+// Authenticator check api keys of requests
+type Authenticator struct {
+	keys       storage.KeyRepository
+	enabled    bool
+	adminToken string
+	limiter    *limiter
+	now        func() time.Time
+}
+
+// NewAuthenticator create authenticator. If it isn't enabled every request is allowed,
+// admin endpoints are rejected if admin token is empty
+func NewAuthenticator(keys storage.KeyRepository, enabled bool, adminToken string) *Authenticator {
+	return &Authenticator{
+		keys:       keys,
+		enabled:    enabled,
+		adminToken: adminToken,
+		limiter:    newLimiter(),
+		now:        time.Now,
+	}
+}
+
+// ValidScope check if scope is supported
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Require wrap handle with api key check: key should exist, not be revoked, grant the scope
+// and fit rate limit and daily quota
+func (a *Authenticator) Require(scope string, handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if !a.enabled {
+			handle(w, r, ps)
+
+			return
+		}
+
+		secret := requestKey(r)
+		if secret == "" {
+			failed(w, http.StatusUnauthorized, ErrorCodeUnauthorized, "api key is required")
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), keyLookupTimeout)
+		defer cancel()
+
+		key, err := a.keys.FindKey(ctx, HashKey(secret))
+		if err == storage.ErrKeyNotFound || (err == nil && key.Revoked()) {
+			failed(w, http.StatusUnauthorized, ErrorCodeUnauthorized, "invalid api key")
+
+			return
+		}
+
+		if err != nil {
+			log.Printf("api key loading failed. %s", err)
+			failed(w, http.StatusInternalServerError, errorCodeInternal, "sorry, an error occurred. try again later")
+
+			return
+		}
+
+		if !key.HasScope(scope) {
+			failed(w, http.StatusForbidden, ErrorCodeForbidden, fmt.Sprintf("api key doesn't grant `%s` scope", scope))
+
+			return
+		}
+
+		if !a.charge(ctx, w, key, 1) {
+			return
+		}
+
+		handle(w, r.WithContext(context.WithValue(r.Context(), keyContextKey{}, key)), ps)
+	}
+}
+
+// Charge count n more requests of the api key the request was authenticated with, e.g. items of batch request.
+// Failure response is written and false is returned if rate limit or daily quota is exceeded.
+// Requests without api key (nil or disabled authenticator) are not charged
+func (a *Authenticator) Charge(w http.ResponseWriter, r *http.Request, n int) bool {
+	if a == nil || !a.enabled || n <= 0 {
+		return true
+	}
+
+	key, ok := r.Context().Value(keyContextKey{}).(*storage.APIKey)
+	if !ok {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), keyLookupTimeout)
+	defer cancel()
+
+	if a.charge(ctx, w, key, n) {
+		return true
+	}
+
+	// the request is rejected, so it isn't counted as well
+	a.limiter.refund(key.ID, key.RateLimit, 1)
+
+	if key.DailyQuota > 0 {
+		if _, err := a.keys.IncrementUsage(ctx, key.ID, a.now(), -1); err != nil {
+			log.Printf("api key usage counting failed. %s", err)
+		}
+	}
+
+	return false
+}
+
+// charge take n requests of the key rate limit and daily quota. Rejected requests aren't counted,
+// rate limit tokens are returned if daily quota is exceeded
+func (a *Authenticator) charge(ctx context.Context, w http.ResponseWriter, key *storage.APIKey, n int) bool {
+	if key.RateLimit > 0 && n > key.RateLimit {
+		failed(w, http.StatusTooManyRequests, ErrorCodeRateLimited, fmt.Sprintf("%d requests exceed rate limit of %d requests per minute", n, key.RateLimit))
+
+		return false
+	}
+
+	now := a.now()
+
+	if ok, wait := a.limiter.allow(key.ID, key.RateLimit, n, now); !ok {
+		w.Header().Set("Retry-After", retryAfter(wait))
+		failed(w, http.StatusTooManyRequests, ErrorCodeRateLimited, fmt.Sprintf("rate limit of %d requests per minute is exceeded", key.RateLimit))
+
+		return false
+	}
+
+	if key.DailyQuota <= 0 {
+		return true
+	}
+
+	used, err := a.keys.IncrementUsage(ctx, key.ID, now, n)
+	if err != nil {
+		a.limiter.refund(key.ID, key.RateLimit, n)
+		log.Printf("api key usage counting failed. %s", err)
+		failed(w, http.StatusInternalServerError, errorCodeInternal, "sorry, an error occurred. try again later")
+
+		return false
+	}
+
+	if used > key.DailyQuota {
+		a.limiter.refund(key.ID, key.RateLimit, n)
+
+		if _, err := a.keys.IncrementUsage(ctx, key.ID, now, -n); err != nil {
+			log.Printf("api key usage counting failed. %s", err)
+		}
+
+		w.Header().Set("Retry-After", retryAfter(nextDay(now).Sub(now)))
+		failed(w, http.StatusTooManyRequests, ErrorCodeQuotaExceeded, fmt.Sprintf("daily quota of %d requests is exceeded", key.DailyQuota))
+
+		return false
+	}
+
+	return true
+}
+
+// RequireAdmin wrap handle with admin token check
+func (a *Authenticator) RequireAdmin(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), bearerPrefix)
+
+		if a.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(a.adminToken)) != 1 {
+			failed(w, http.StatusUnauthorized, ErrorCodeUnauthorized, "invalid admin token")
+
+			return
+		}
+
+		handle(w, r, ps)
+	}
+}
+
+// requestKey return api key secret of the request. Query param is removed from request url,
+// so the secret isn't logged or passed to controllers
+func requestKey(r *http.Request) string {
+	secret := r.Header.Get(KeyHeader)
+
+	query := r.URL.Query()
+	if _, ok := query[KeyQueryParam]; ok {
+		if secret == "" {
+			secret = query.Get(KeyQueryParam)
+		}
+
+		query.Del(KeyQueryParam)
+		r.URL.RawQuery = query.Encode()
+	}
+
+	return secret
+}
+
+// nextDay return the beginning of the next day, daily quota is reset then
+func nextDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// retryAfter format duration as `Retry-After` header value (whole seconds)
+func retryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+func failed(w http.ResponseWriter, status int, code, message string) {
+	(&response.Response{
+		Status:     false,
+		Message:    message,
+		StatusCode: status,
+		Data:       nil}).FailedWithCode(w, code)
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lroman242/redirective/storage"
+)
+
+// newTestAuthenticator create enabled authenticator with a key of provided scopes and limits
+func newTestAuthenticator(t *testing.T, rateLimit, dailyQuota int, scopes ...string) (*Authenticator, *storage.APIKey, string) {
+	t.Helper()
+
+	secret, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	repo := storage.NewMemoryRepository()
+	key := &storage.APIKey{Name: "test", Hash: HashKey(secret), Scopes: scopes, RateLimit: rateLimit, DailyQuota: dailyQuota}
+
+	if err := repo.SaveKey(context.Background(), key); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	return NewAuthenticator(repo, true, "admin-token"), key, secret
+}
+
+// serveTest call handle wrapped with scope check and return response status and error code
+func serveTest(a *Authenticator, scope string, r *http.Request) (*httptest.ResponseRecorder, string) {
+	handle := a.Require(scope, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	handle(rec, r, nil)
+
+	data := struct {
+		ErrorCode string `json:"error_code"`
+	}{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &data)
+
+	return rec, data.ErrorCode
+}
+
+func newTestRequest(secret string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/trace/http?url=http://example.com", nil)
+	if secret != "" {
+		r.Header.Set(KeyHeader, secret)
+	}
+
+	return r
+}
+
+func TestAuthenticator_Require(t *testing.T) {
+	a, key, secret := newTestAuthenticator(t, 0, 0, ScopeTrace, ScopeRead)
+
+	if rec, _ := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusOK {
+		t.Errorf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	if rec, code := serveTest(a, ScopeTrace, newTestRequest("")); rec.Code != http.StatusUnauthorized || code != ErrorCodeUnauthorized {
+		t.Errorf("expect missing key to be rejected but get %d %s", rec.Code, code)
+	}
+
+	if rec, code := serveTest(a, ScopeTrace, newTestRequest("rdv_unknown")); rec.Code != http.StatusUnauthorized || code != ErrorCodeUnauthorized {
+		t.Errorf("expect unknown key to be rejected but get %d %s", rec.Code, code)
+	}
+
+	if rec, code := serveTest(a, ScopeScreenshot, newTestRequest(secret)); rec.Code != http.StatusForbidden || code != ErrorCodeForbidden {
+		t.Errorf("expect key without scope to be rejected but get %d %s", rec.Code, code)
+	}
+
+	revokedAt := time.Now()
+	key.RevokedAt = &revokedAt
+	_ = a.keys.SaveKey(context.Background(), key)
+
+	if rec, code := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusUnauthorized || code != ErrorCodeUnauthorized {
+		t.Errorf("expect revoked key to be rejected but get %d %s", rec.Code, code)
+	}
+}
+
+func TestAuthenticator_Require_QueryParam(t *testing.T) {
+	a, _, secret := newTestAuthenticator(t, 0, 0, ScopeRead)
+
+	r := httptest.NewRequest(http.MethodGet, "/api/traces?limit=2&api_key="+secret, nil)
+
+	if rec, _ := serveTest(a, ScopeRead, r); rec.Code != http.StatusOK {
+		t.Errorf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	if strings.Contains(r.URL.RawQuery, secret) || r.URL.Query().Get("limit") != "2" {
+		t.Errorf("expect api key to be removed from query but get `%s`", r.URL.RawQuery)
+	}
+}
+
+func TestAuthenticator_Require_Disabled(t *testing.T) {
+	a := NewAuthenticator(storage.NewMemoryRepository(), false, "")
+
+	if rec, _ := serveTest(a, ScopeScreenshot, newTestRequest("")); rec.Code != http.StatusOK {
+		t.Errorf("expect every request to be allowed but get %d", rec.Code)
+	}
+}
+
+func TestAuthenticator_Require_RateLimit(t *testing.T) {
+	a, _, secret := newTestAuthenticator(t, 2, 0, ScopeTrace)
+
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if rec, _ := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusOK {
+			t.Errorf("expect request %d to be allowed but get %d", i, rec.Code)
+		}
+	}
+
+	rec, code := serveTest(a, ScopeTrace, newTestRequest(secret))
+	if rec.Code != http.StatusTooManyRequests || code != ErrorCodeRateLimited {
+		t.Errorf("expect request to be rate limited but get %d %s", rec.Code, code)
+	}
+
+	if rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("expect Retry-After 30 but get `%s`", rec.Header().Get("Retry-After"))
+	}
+
+	now = now.Add(30 * time.Second)
+
+	if rec, _ := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusOK {
+		t.Errorf("expect request to be allowed after a token is refilled but get %d", rec.Code)
+	}
+}
+
+func TestAuthenticator_Require_DailyQuota(t *testing.T) {
+	a, _, secret := newTestAuthenticator(t, 0, 2, ScopeTrace)
+
+	now := time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if rec, _ := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusOK {
+			t.Errorf("expect request %d to be allowed but get %d", i, rec.Code)
+		}
+	}
+
+	rec, code := serveTest(a, ScopeTrace, newTestRequest(secret))
+	if rec.Code != http.StatusTooManyRequests || code != ErrorCodeQuotaExceeded {
+		t.Errorf("expect quota to be exceeded but get %d %s", rec.Code, code)
+	}
+
+	if rec.Header().Get("Retry-After") != "3600" {
+		t.Errorf("expect Retry-After 3600 but get `%s`", rec.Header().Get("Retry-After"))
+	}
+
+	now = now.Add(time.Hour)
+
+	if rec, _ := serveTest(a, ScopeTrace, newTestRequest(secret)); rec.Code != http.StatusOK {
+		t.Errorf("expect quota to be reset the next day but get %d", rec.Code)
+	}
+}
+
+// serveTestBatch call handle charging items of the batch and return response status and error code
+func serveTestBatch(a *Authenticator, items int, r *http.Request) (*httptest.ResponseRecorder, string) {
+	handle := a.Require(ScopeTrace, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		if a.Charge(w, r, items-1) {
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	handle(rec, r, nil)
+
+	data := struct {
+		ErrorCode string `json:"error_code"`
+	}{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &data)
+
+	return rec, data.ErrorCode
+}
+
+func TestAuthenticator_Charge(t *testing.T) {
+	a, key, secret := newTestAuthenticator(t, 0, 5, ScopeTrace)
+
+	rec, code := serveTestBatch(a, 10, newTestRequest(secret))
+	if rec.Code != http.StatusTooManyRequests || code != ErrorCodeQuotaExceeded {
+		t.Errorf("expect batch to exceed quota but get %d %s", rec.Code, code)
+	}
+
+	// rejected batch isn't counted
+	if rec, _ := serveTestBatch(a, 4, newTestRequest(secret)); rec.Code != http.StatusOK {
+		t.Errorf("expect batch to be allowed but get %d", rec.Code)
+	}
+
+	used, _ := a.keys.IncrementUsage(context.Background(), key.ID, a.now(), 0)
+	if used != 4 {
+		t.Errorf("expect every batch item to be counted but get usage %d", used)
+	}
+
+	if rec, _ := serveTestBatch(a, 2, newTestRequest(secret)); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expect batch to exceed remaining quota but get %d", rec.Code)
+	}
+
+	a, _, secret = newTestAuthenticator(t, 3, 0, ScopeTrace)
+
+	if rec, code := serveTestBatch(a, 5, newTestRequest(secret)); rec.Code != http.StatusTooManyRequests || code != ErrorCodeRateLimited {
+		t.Errorf("expect batch to exceed rate limit but get %d %s", rec.Code, code)
+	}
+
+	// batch rejected by quota doesn't take rate limit tokens
+	a, _, secret = newTestAuthenticator(t, 3, 2, ScopeTrace)
+
+	if rec, code := serveTestBatch(a, 3, newTestRequest(secret)); rec.Code != http.StatusTooManyRequests || code != ErrorCodeQuotaExceeded {
+		t.Errorf("expect batch to exceed quota but get %d %s", rec.Code, code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec, code := serveTestBatch(a, 1, newTestRequest(secret)); rec.Code != http.StatusOK {
+			t.Errorf("expect request %d to be allowed but get %d %s", i, rec.Code, code)
+		}
+	}
+}
+
+func TestAuthenticator_RequireAdmin(t *testing.T) {
+	handle := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for token, expected := range map[string]int{
+		"Bearer admin-token": http.StatusOK,
+		"Bearer wrong-token": http.StatusUnauthorized,
+		"":                   http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/keys", nil)
+		r.Header.Set("Authorization", token)
+
+		rec := httptest.NewRecorder()
+		NewAuthenticator(storage.NewMemoryRepository(), true, "admin-token").RequireAdmin(handle)(rec, r, nil)
+
+		if rec.Code != expected {
+			t.Errorf("expect status code %d but get %d for token `%s`", expected, rec.Code, token)
+		}
+	}
+
+	// admin endpoints are disabled without admin token
+	rec := httptest.NewRecorder()
+	NewAuthenticator(storage.NewMemoryRepository(), true, "").RequireAdmin(handle)(rec, httptest.NewRequest(http.MethodGet, "/api/keys", nil), nil)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expect status code %d but get %d", http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	secret, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	other, _ := GenerateKey()
+
+	if !strings.HasPrefix(secret, keySecretPrefix) || len(secret) != len(keySecretPrefix)+2*keySecretBytes || secret == other {
+		t.Errorf("invalid generated key `%s`", secret)
+	}
+
+	if HashKey(secret) != HashKey(secret) || HashKey(secret) == HashKey(other) {
+		t.Error("expect hash to depend on secret only")
+	}
+
+	if KeyPrefix(secret) != secret[:keyPrefixLength] {
+		t.Errorf("invalid key prefix `%s`", KeyPrefix(secret))
+	}
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	keySecretPrefix = "rdv_"
+	keySecretBytes  = 24
+	keyPrefixLength = 8
+)
+
+// GenerateKey create new random api key secret
+func GenerateKey() (string, error) {
+	secret := make([]byte, keySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("cannot generate api key. %s", err)
+	}
+
+	return keySecretPrefix + hex.EncodeToString(secret), nil
+}
+
+// HashKey return sha256 hash of the secret, only hash is saved to storage
+func HashKey(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// KeyPrefix return first characters of the secret to recognize the key
+func KeyPrefix(secret string) string {
+	if len(secret) <= keyPrefixLength {
+		return secret
+	}
+
+	return secret[:keyPrefixLength]
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// limiter is a per key token bucket. Bucket capacity is a rate limit (requests per minute)
+// and it is refilled continuously
+type limiter struct {
+	sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{buckets: make(map[string]*bucket)}
+}
+
+// allow take n tokens of key bucket. If there are not enough tokens, the time to wait for them is returned
+func (l *limiter) allow(id string, perMinute, n int, now time.Time) (bool, time.Duration) {
+	if perMinute <= 0 {
+		return true, 0
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	capacity := float64(perMinute)
+	perSecond := capacity / 60
+
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[id] = b
+	}
+
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed.Seconds()*perSecond)
+		b.updated = now
+	}
+
+	if b.tokens < float64(n) {
+		return false, time.Duration(math.Ceil((float64(n) - b.tokens) / perSecond * float64(time.Second)))
+	}
+
+	b.tokens -= float64(n)
+
+	return true, 0
+}
+
+// refund return n tokens taken by allow, e.g. if the request is rejected later
+func (l *limiter) refund(id string, perMinute, n int) {
+	if perMinute <= 0 {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if b, ok := l.buckets[id]; ok {
+		b.tokens = math.Min(float64(perMinute), b.tokens+float64(n))
+	}
+}
@@ -0,0 +1,559 @@
+// Package browser implements a pool of headless google chrome processes
+package browser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultChromePath          = "/usr/bin/google-chrome"
+	defaultBasePort            = 9222
+	defaultDevToolsAddress     = "0.0.0.0"
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultStartupTimeout      = 10 * time.Second
+	healthCheckTimeout         = 2 * time.Second
+	startupPollInterval        = 100 * time.Millisecond
+	// terminateTimeout is how long browser is given to exit after SIGTERM before it is killed
+	terminateTimeout = 5 * time.Second
+)
+
+// DefaultUserAgent is a desktop google chrome user agent used instead of headless one
+const DefaultUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Safari/537.36"
+
+const (
+	errorMessagePoolClosed     = "browser pool is closed"
+	errorMessageStartupTimeout = "browser didn't start in time"
+	errorMessageInvalidStatus  = "unexpected devtools status code"
+)
+
+// Options describe pool size and browsers lifecycle
+type Options struct {
+	// ChromePath is a path to google chrome executable
+	ChromePath string
+	// UserAgent overrides default browser user agent if not empty
+	UserAgent string
+	// Size is an amount of chrome processes
+	Size int
+	// BasePort is a devtools port of the first browser, next ones use BasePort+1, BasePort+2...
+	BasePort int
+	// DevToolsAddress is an interface devtools listen on
+	DevToolsAddress string
+	// MaxInFlight is a max amount of simultaneous traces per browser
+	MaxInFlight int
+	// RecycleAfter restarts browser after it served provided amount of traces (0 - never)
+	RecycleAfter int
+	// HealthCheckInterval is a period of `/json/version` checks
+	HealthCheckInterval time.Duration
+	// StartupTimeout limits how long pool waits for devtools of launched browser
+	StartupTimeout time.Duration
+}
+
+// Browser represent single chrome process in the pool
+type Browser struct {
+	port    int
+	cmd     *exec.Cmd
+	exited  chan struct{}
+	healthy bool
+	// draining browser doesn't accept new traces and is restarted when in-flight traces are done
+	draining   bool
+	restarting bool
+	inFlight   int
+	traces     int
+}
+
+// Address return devtools address of the browser
+func (b *Browser) Address() string {
+	return "localhost:" + strconv.Itoa(b.port)
+}
+
+// Pool manages chrome processes: limits amount of simultaneous traces per browser,
+// restarts crashed or unresponsive browsers and recycles them after configured amount of traces
+type Pool struct {
+	sync.Mutex
+
+	options  Options
+	browsers []*Browser
+	// changed is closed (and replaced) on every state change to wake up waiting Acquire calls
+	changed chan struct{}
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	// conns are devtools connections of running traces, they are closed if shutdown deadline is exceeded
+	conns map[io.Closer]struct{}
+
+	launch           func(port int) (*exec.Cmd, error)
+	check            func(address string) error
+	startupTimeout   time.Duration
+	terminateTimeout time.Duration
+}
+
+// NewPool create new pool instance, zero options are replaced with default values
+func NewPool(options Options) *Pool {
+	if options.ChromePath == "" {
+		options.ChromePath = defaultChromePath
+	}
+
+	if options.Size <= 0 {
+		options.Size = 1
+	}
+
+	if options.BasePort <= 0 {
+		options.BasePort = defaultBasePort
+	}
+
+	if options.DevToolsAddress == "" {
+		options.DevToolsAddress = defaultDevToolsAddress
+	}
+
+	if options.MaxInFlight <= 0 {
+		options.MaxInFlight = 1
+	}
+
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = defaultHealthCheckInterval
+	}
+
+	if options.StartupTimeout <= 0 {
+		options.StartupTimeout = defaultStartupTimeout
+	}
+
+	p := &Pool{
+		options:          options,
+		changed:          make(chan struct{}),
+		stop:             make(chan struct{}),
+		conns:            make(map[io.Closer]struct{}),
+		check:            checkDevTools,
+		startupTimeout:   options.StartupTimeout,
+		terminateTimeout: terminateTimeout,
+	}
+	p.launch = p.launchChrome
+
+	return p
+}
+
+// Start run all browsers and health checker
+func (p *Pool) Start() error {
+	p.Lock()
+	for i := 0; i < p.options.Size; i++ {
+		p.browsers = append(p.browsers, &Browser{port: p.options.BasePort + i, restarting: true})
+	}
+	p.Unlock()
+
+	for _, b := range p.browsers {
+		if err := p.restart(b); err != nil {
+			return err
+		}
+	}
+
+	p.wg.Add(1)
+
+	go p.healthCheck()
+
+	return nil
+}
+
+// Acquire reserve a slot on the least loaded healthy browser.
+// It blocks until a slot is available or context is done.
+// Release should be called when the browser isn't needed anymore
+func (p *Pool) Acquire(ctx context.Context) (*Browser, error) {
+	for {
+		p.Lock()
+		if p.closed {
+			p.Unlock()
+			return nil, errors.New(errorMessagePoolClosed)
+		}
+
+		var selected *Browser
+
+		for _, b := range p.browsers {
+			if !b.healthy || b.draining || b.restarting || b.inFlight >= p.options.MaxInFlight {
+				continue
+			}
+
+			if selected == nil || b.inFlight < selected.inFlight {
+				selected = b
+			}
+		}
+
+		if selected != nil {
+			selected.inFlight++
+			p.Unlock()
+
+			return selected, nil
+		}
+
+		changed := p.changed
+		p.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release free the slot reserved by Acquire
+func (p *Pool) Release(b *Browser) {
+	p.Lock()
+	b.inFlight--
+	b.traces++
+
+	if p.options.RecycleAfter > 0 && b.traces >= p.options.RecycleAfter {
+		b.draining = true
+	}
+
+	recycle := b.draining && b.inFlight == 0 && !b.restarting && !p.closed
+	if recycle {
+		b.restarting = true
+		// restart is registered under lock, so Shutdown waits for it
+		p.wg.Add(1)
+	}
+
+	p.notify()
+	p.Unlock()
+
+	if recycle {
+		log.Printf("recycling google-chrome on port %d after %d traces\n", b.port, b.traces)
+
+		go func() {
+			defer p.wg.Done()
+
+			if err := p.restart(b); err != nil {
+				log.Printf("browser restart failed. error: %s\n", err)
+			}
+		}()
+	}
+}
+
+// Track register devtools connection of running trace, returned function should be called
+// when the connection is closed by the trace
+func (p *Pool) Track(conn io.Closer) func() {
+	p.Lock()
+	p.conns[conn] = struct{}{}
+	p.Unlock()
+
+	return func() {
+		p.Lock()
+		delete(p.conns, conn)
+		p.Unlock()
+	}
+}
+
+// Shutdown stop accepting new traces and wait until running ones release their browsers or context is done.
+// Devtools connections left open after the deadline are closed, then browsers are terminated gracefully
+// (SIGTERM, SIGKILL if browser didn't exit in time). Context error is returned if the deadline is exceeded
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		return nil
+	}
+
+	p.closed = true
+	close(p.stop)
+	p.notify()
+	p.Unlock()
+
+	drainErr := p.drain(ctx)
+	if drainErr != nil {
+		p.closeConns()
+	}
+
+	p.wg.Wait()
+
+	var lastErr error
+
+	for _, b := range p.browsers {
+		if err := p.terminate(b); err != nil {
+			lastErr = err
+		}
+	}
+
+	if lastErr == nil {
+		lastErr = drainErr
+	}
+
+	return lastErr
+}
+
+// Close stop health checker, close devtools connections of running traces and terminate all browsers
+func (p *Pool) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := p.Shutdown(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	return err
+}
+
+// drain wait until all browsers are released
+func (p *Pool) drain(ctx context.Context) error {
+	for {
+		p.Lock()
+		inFlight := 0
+		for _, b := range p.browsers {
+			inFlight += b.inFlight
+		}
+
+		changed := p.changed
+		p.Unlock()
+
+		if inFlight == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("%d traces are still running, closing their devtools connections\n", inFlight)
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// closeConns close all tracked devtools connections
+func (p *Pool) closeConns() {
+	p.Lock()
+	conns := make([]io.Closer, 0, len(p.conns))
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	p.Unlock()
+
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil {
+			log.Printf("failed to close devtools connection. error: %s\n", err)
+		}
+	}
+}
+
+// notify wake up all waiting Acquire calls, should be called under lock
+func (p *Pool) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// restart kill browser process (if any) and run a new one. Browser should be marked as restarting
+func (p *Pool) restart(b *Browser) error {
+	p.Lock()
+	b.healthy = false
+	p.Unlock()
+
+	if err := p.terminate(b); err != nil {
+		log.Printf("failed to stop google-chrome on port %d. error: %s\n", b.port, err)
+	}
+
+	cmd, err := p.launch(b.port)
+	if err == nil {
+		err = p.waitStarted(b.Address())
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if cmd != nil {
+		b.cmd = cmd
+		b.exited = make(chan struct{})
+
+		go func(cmd *exec.Cmd, exited chan struct{}) {
+			_ = cmd.Wait()
+			close(exited)
+		}(cmd, b.exited)
+	}
+
+	b.restarting = false
+
+	if err != nil {
+		return fmt.Errorf("google-chrome on port %d failed to start. %s", b.port, err)
+	}
+
+	b.healthy = true
+	b.draining = false
+	b.traces = 0
+	p.notify()
+
+	log.Printf("google-chrome headless runned with PID %d on %d port\n", cmd.Process.Pid, b.port)
+
+	return nil
+}
+
+// terminate stop browser process group with SIGTERM and kill it if browser didn't exit in time.
+// Child processes left after browser exit (renderers, gpu) are killed as well
+func (p *Pool) terminate(b *Browser) error {
+	p.Lock()
+	cmd, exited := b.cmd, b.exited
+	b.cmd = nil
+	p.Unlock()
+
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+
+	select {
+	case <-exited:
+		_ = killGroup(cmd, syscall.SIGKILL)
+		return nil
+	default:
+	}
+
+	log.Printf("stopping google-chrome PID %d\n", cmd.Process.Pid)
+
+	if err := killGroup(cmd, syscall.SIGTERM); err == nil {
+		select {
+		case <-exited:
+			_ = killGroup(cmd, syscall.SIGKILL)
+			return nil
+		case <-time.After(p.terminateTimeout):
+		}
+	}
+
+	log.Printf("killing google-chrome PID %d\n", cmd.Process.Pid)
+
+	if err := killGroup(cmd, syscall.SIGKILL); err != nil {
+		return err
+	}
+
+	<-exited
+
+	return nil
+}
+
+// killGroup send signal to the process group of the browser, so it reaches chrome child processes.
+// Process itself is signaled if it doesn't lead its own group
+func killGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err == nil {
+		return nil
+	}
+
+	return cmd.Process.Signal(sig)
+}
+
+// waitStarted wait until browser devtools respond
+func (p *Pool) waitStarted(address string) error {
+	deadline := time.Now().Add(p.startupTimeout)
+
+	for time.Now().Before(deadline) {
+		if p.check(address) == nil {
+			return nil
+		}
+
+		time.Sleep(startupPollInterval)
+	}
+
+	return errors.New(errorMessageStartupTimeout)
+}
+
+// healthCheck periodically restart browsers which exited or stopped responding
+func (p *Pool) healthCheck() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.options.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, b := range p.browsers {
+			p.Lock()
+			skip := b.restarting || p.closed
+			exited := b.exited
+			p.Unlock()
+
+			if skip {
+				continue
+			}
+
+			alive := exited != nil
+			if alive {
+				select {
+				case <-exited:
+					alive = false
+				default:
+				}
+			}
+
+			if alive && p.check(b.Address()) == nil {
+				continue
+			}
+
+			log.Printf("google-chrome on port %d is not responding, restarting\n", b.port)
+
+			p.Lock()
+			b.restarting = true
+			p.Unlock()
+
+			if err := p.restart(b); err != nil {
+				log.Printf("browser restart failed. error: %s\n", err)
+			}
+		}
+	}
+}
+
+// launchChrome run google chrome headless process
+func (p *Pool) launchChrome(port int) (*exec.Cmd, error) {
+	args := []string{
+		"--addr=localhost",
+		"--port=" + strconv.Itoa(port),
+		"--remote-debugging-port=" + strconv.Itoa(port),
+		"--remote-debugging-address=" + p.options.DevToolsAddress,
+		"--disable-extensions",
+		"--disable-gpu",
+		"--headless",
+		"--hide-scrollbars",
+		"--no-first-run",
+		"--no-sandbox",
+		// every browser needs its own profile, otherwise processes are merged into one
+		"--user-data-dir=" + fmt.Sprintf("%s/redirective-chrome-%d", os.TempDir(), port),
+	}
+
+	if p.options.UserAgent != "" {
+		args = append(args, "--user-agent="+p.options.UserAgent)
+	}
+
+	cmd := exec.Command(p.options.ChromePath, args...)
+	cmd.Stdout = os.Stdout
+	// chrome runs in its own process group, so its child processes are stopped together with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// checkDevTools check if browser devtools respond on `/json/version`
+func checkDevTools(address string) error {
+	client := &http.Client{Timeout: healthCheckTimeout}
+
+	resp, err := client.Get("http://" + address + "/json/version")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %d", errorMessageInvalidStatus, resp.StatusCode)
+	}
+
+	return nil
+}
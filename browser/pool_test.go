@@ -0,0 +1,393 @@
+package browser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeLauncher runs `sleep` instead of chrome and counts launches per port
+type fakeLauncher struct {
+	sync.Mutex
+	launches map[int]int
+	healthy  bool
+}
+
+func (fl *fakeLauncher) launch(port int) (*exec.Cmd, error) {
+	fl.Lock()
+	fl.launches[port]++
+	fl.Unlock()
+
+	cmd := exec.Command("sleep", "60")
+
+	return cmd, cmd.Start()
+}
+
+func (fl *fakeLauncher) check(string) error {
+	fl.Lock()
+	defer fl.Unlock()
+
+	if !fl.healthy {
+		return errors.New("not responding")
+	}
+
+	return nil
+}
+
+func (fl *fakeLauncher) count(port int) int {
+	fl.Lock()
+	defer fl.Unlock()
+
+	return fl.launches[port]
+}
+
+func (fl *fakeLauncher) setHealthy(healthy bool) {
+	fl.Lock()
+	fl.healthy = healthy
+	fl.Unlock()
+}
+
+func newTestPool(t *testing.T, options Options) (*Pool, *fakeLauncher) {
+	fl := &fakeLauncher{launches: make(map[int]int), healthy: true}
+
+	p := NewPool(options)
+	p.launch = fl.launch
+	p.check = fl.check
+	p.startupTimeout = time.Second
+
+	if err := p.Start(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	return p, fl
+}
+
+func TestNewPool_Defaults(t *testing.T) {
+	p := NewPool(Options{})
+
+	if p.options.Size != 1 || p.options.MaxInFlight != 1 || p.options.BasePort != defaultBasePort {
+		t.Errorf("invalid default options %+v", p.options)
+	}
+
+	if p.options.ChromePath != defaultChromePath {
+		t.Errorf("expect default chrome path %s but get %s", defaultChromePath, p.options.ChromePath)
+	}
+}
+
+func TestPool_Start(t *testing.T) {
+	p, fl := newTestPool(t, Options{Size: 3, BasePort: 9300})
+	defer p.Close()
+
+	for port := 9300; port < 9303; port++ {
+		if fl.count(port) != 1 {
+			t.Errorf("expect browser on port %d to be launched once but get %d", port, fl.count(port))
+		}
+	}
+}
+
+func TestPool_Acquire_MaxInFlight(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, MaxInFlight: 2})
+	defer p.Close()
+
+	b1, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	b2, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err = p.Acquire(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect acquire to block until context deadline but get %v", err)
+	}
+
+	acquired := make(chan *Browser)
+
+	go func() {
+		b, _ := p.Acquire(context.Background())
+		acquired <- b
+	}()
+
+	p.Release(b1)
+
+	select {
+	case b := <-acquired:
+		if b != b2 {
+			t.Error("expect the same browser to be acquired")
+		}
+	case <-time.After(time.Second):
+		t.Error("expect waiting acquire to succeed after release")
+	}
+}
+
+func TestPool_Acquire_LeastLoaded(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 2, MaxInFlight: 2, BasePort: 9400})
+	defer p.Close()
+
+	b1, _ := p.Acquire(context.Background())
+	b2, _ := p.Acquire(context.Background())
+
+	if b1 == b2 {
+		t.Error("expect traces to be spread across browsers")
+	}
+}
+
+func TestPool_Release_Recycle(t *testing.T) {
+	p, fl := newTestPool(t, Options{Size: 1, RecycleAfter: 2, BasePort: 9500})
+	defer p.Close()
+
+	for i := 0; i < 2; i++ {
+		b, err := p.Acquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		p.Release(b)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	b, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+	defer p.Release(b)
+
+	if fl.count(9500) != 2 {
+		t.Errorf("expect browser to be relaunched after 2 traces, launches: %d", fl.count(9500))
+	}
+}
+
+func TestPool_HealthCheck_Restart(t *testing.T) {
+	p, fl := newTestPool(t, Options{Size: 1, BasePort: 9600, HealthCheckInterval: 50 * time.Millisecond})
+	defer p.Close()
+
+	// kill the process, health checker should notice and launch a new one
+	p.Lock()
+	_ = p.browsers[0].cmd.Process.Kill()
+	p.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fl.count(9600) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if fl.count(9600) < 2 {
+		t.Error("expect crashed browser to be restarted")
+	}
+
+	// stop responding, health checker should restart the browser again
+	fl.setHealthy(false)
+	time.Sleep(100 * time.Millisecond)
+	fl.setHealthy(true)
+
+	deadline = time.Now().Add(2 * time.Second)
+	for fl.count(9600) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if fl.count(9600) < 3 {
+		t.Error("expect unresponsive browser to be restarted")
+	}
+}
+
+func TestPool_Close(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, BasePort: 9700})
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if _, err := p.Acquire(context.Background()); err == nil || err.Error() != errorMessagePoolClosed {
+		t.Errorf("expect error: %s but got %v", errorMessagePoolClosed, err)
+	}
+}
+
+// fakeConn is a devtools connection which records Close call
+type fakeConn struct {
+	closed chan struct{}
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, BasePort: 9800})
+
+	b, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	conn := &fakeConn{closed: make(chan struct{})}
+	untrack := p.Track(conn)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		untrack()
+		p.Release(b)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	select {
+	case <-conn.closed:
+		t.Error("expect connection of finished trace not to be closed")
+	default:
+	}
+
+	if b.cmd != nil {
+		t.Error("expect browser to be stopped")
+	}
+}
+
+func TestPool_Shutdown_Deadline(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, BasePort: 9900})
+
+	if _, err := p.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	conn := &fakeConn{closed: make(chan struct{})}
+	p.Track(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := p.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded error but get %v", err)
+	}
+
+	select {
+	case <-conn.closed:
+	default:
+		t.Error("expect connection of running trace to be closed")
+	}
+}
+
+func TestPool_terminate_Kill(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, BasePort: 10000})
+	p.terminateTimeout = 50 * time.Millisecond
+
+	// replace browser process with the one ignoring SIGTERM
+	_ = p.terminate(p.browsers[0])
+
+	cmd := exec.Command("sh", "-c", `trap "" TERM; exec sleep 60`)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	exited := make(chan struct{})
+
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	p.browsers[0].cmd, p.browsers[0].exited = cmd, exited
+	// give shell time to set the trap
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if time.Since(start) < p.terminateTimeout {
+		t.Error("expect browser to be given time to exit after SIGTERM")
+	}
+
+	select {
+	case <-exited:
+	default:
+		t.Error("expect browser to be killed")
+	}
+}
+
+func TestPool_terminate_ProcessGroup(t *testing.T) {
+	p, _ := newTestPool(t, Options{Size: 1, BasePort: 10000})
+
+	// replace browser process with the one running a child process, like chrome renderers
+	_ = p.terminate(p.browsers[0])
+
+	cmd := exec.Command("sh", "-c", `sleep 60 & echo $!; wait`)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	child, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	exited := make(chan struct{})
+
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	p.browsers[0].cmd, p.browsers[0].exited = cmd, exited
+
+	if err := p.Close(); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		// killed child is either gone or a zombie waiting for init to reap it
+		stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(child) + "/stat")
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = syscall.Kill(child, syscall.SIGKILL)
+
+	t.Error("expect child process to be stopped with the browser")
+}
+
+func TestBrowser_Address(t *testing.T) {
+	b := &Browser{port: 9222}
+
+	if b.Address() != "localhost:9222" {
+		t.Errorf("expect address localhost:9222 but get %s", b.Address())
+	}
+}
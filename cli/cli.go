@@ -0,0 +1,518 @@
+// Package cli implements command line mode to run one-off traces and screenshots
+// without http server and mongodb
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lroman242/redirective/browser"
+	"github.com/lroman242/redirective/tracer"
+	"github.com/raff/godet"
+)
+
+// Commands supported by command line mode
+const (
+	CommandTrace      = "trace"
+	CommandScreenshot = "screenshot"
+)
+
+// Output formats of trace command
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+const (
+	defaultPort           = 9322
+	defaultScreenWidth    = 1920
+	defaultScreenHeight   = 1080
+	browserAcquireTimeout = 30 * time.Second
+	httpTracerTimeout     = 30 * time.Second
+)
+
+const usage = `Usage:
+  redirective trace [flags] <url>
+  redirective screenshot [flags] <url>
+
+Flags:
+`
+
+// IsCommand check if provided argument is a command line mode command
+func IsCommand(arg string) bool {
+	return arg == CommandTrace || arg == CommandScreenshot
+}
+
+// config contains parsed command line flags
+type config struct {
+	command      string
+	url          *url.URL
+	tracer       string
+	format       string
+	output       string
+	har          string
+	remote       string
+	chromePath   string
+	port         int
+	size         *tracer.ScreenSize
+	waitStrategy *tracer.WaitStrategy
+	networkLog   bool
+	frameTree    bool
+	hopScreens   bool
+	device       *tracer.Device
+	request      *tracer.RequestOptions
+	proxy        *url.URL
+	geo          *tracer.Geo
+	maxHops      int
+}
+
+// stringsFlag collect values of repeated flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// Run execute command with provided arguments (command name is the first one) and return exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	cfg, err := parseArgs(args, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 2
+	}
+
+	if cfg.command == CommandScreenshot {
+		err = screenshot(cfg, stdout)
+	} else {
+		err = trace(cfg, stdout)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "%s failed. %s\n", cfg.command, err)
+		return 1
+	}
+
+	return 0
+}
+
+// parseArgs parse and validate command line arguments
+func parseArgs(args []string, stderr io.Writer) (*config, error) {
+	if len(args) == 0 || !IsCommand(args[0]) {
+		return nil, fmt.Errorf("unknown command. expect `%s` or `%s`", CommandTrace, CommandScreenshot)
+	}
+
+	cfg := &config{command: args[0]}
+
+	flags := flag.NewFlagSet(cfg.command, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	flags.StringVar(&cfg.tracer, "tracer", "chrome", "Tracer to use: chrome or http (trace command only)")
+	flags.StringVar(&cfg.format, "format", FormatTable, "Output format: table, json or jsonl (trace command only)")
+	flags.StringVar(&cfg.output, "o", "", "Path to the screenshot file. Trace command saves final page screenshot only if it is set")
+	flags.StringVar(&cfg.har, "har", "", "Path to the HAR file to export traced chain to (trace command only)")
+	flags.StringVar(&cfg.remote, "remote", "", "Address of already running chrome devtools (for example localhost:9222). New headless chrome is launched if empty")
+	flags.StringVar(&cfg.chromePath, "chrome", "", "Path to google chrome executable")
+	flags.IntVar(&cfg.port, "port", defaultPort, "Devtools port of launched chrome")
+	width := flags.Int("width", defaultScreenWidth, "Screen width")
+	height := flags.Int("height", defaultScreenHeight, "Screen height")
+	deviceName := flags.String("device", "", "Emulated device: "+strings.Join(tracer.DeviceNames(), ", ")+". Width and height flags override device viewport")
+	waitType := flags.String("wait", tracer.WaitTimeout, "Page settle strategy: timeout, load, idle or selector")
+	waitTimeout := flags.Duration("wait-timeout", 0, "Max time to wait for the page to settle")
+	idleTime := flags.Duration("idle-time", 0, "Network quiet period for idle strategy")
+	selector := flags.String("selector", "", "CSS selector for selector strategy")
+	flags.BoolVar(&cfg.networkLog, "network-log", false, "Capture sub-resources requests of every page (chrome tracer only)")
+	flags.BoolVar(&cfg.frameTree, "frames", false, "Trace redirects inside iframes (chrome tracer only)")
+	flags.IntVar(&cfg.maxHops, "max-hops", tracer.DefaultMaxHops, "Max number of followed hops, the chain is truncated after it (0 means no limit)")
+	userAgent := flags.String("user-agent", "", "Custom user agent (overrides device one)")
+	referer := flags.String("referer", "", "Referer of the traced url request")
+	acceptLanguage := flags.String("accept-language", "", "Accept-Language header value")
+	proxy := flags.String("proxy", "", "Proxy url: http://[user:password@]host:port or socks5://host:port")
+	geolocation := flags.String("geolocation", "", "Emulated position `latitude,longitude[,accuracy]` (chrome tracer only)")
+	timezone := flags.String("timezone", "", "Emulated IANA timezone, for example Europe/Berlin (chrome tracer only)")
+	locale := flags.String("locale", "", "Emulated locale, for example de_DE (chrome tracer only)")
+	var headers, cookies stringsFlag
+	flags.Var(&headers, "header", "Extra request header `Name: value` (could be repeated)")
+	flags.Var(&cookies, "cookie", "Cookies set before navigation `name=value; name2=value2` (could be repeated)")
+	flags.BoolVar(&cfg.hopScreens, "hop-screenshots", false, "Capture screenshot of every page before client side redirect, saved next to -o file (chrome tracer only)")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return nil, errors.New("exactly one url is required")
+	}
+
+	u, err := url.ParseRequestURI(flags.Arg(0))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s", err)
+	}
+
+	cfg.url = u
+
+	if cfg.maxHops < 0 {
+		return nil, errors.New("max-hops should not be negative")
+	}
+
+	if cfg.tracer != "chrome" && cfg.tracer != "http" {
+		return nil, fmt.Errorf("unknown tracer `%s`", cfg.tracer)
+	}
+
+	if cfg.format != FormatTable && cfg.format != FormatJSON && cfg.format != FormatJSONL {
+		return nil, fmt.Errorf("unknown format `%s`", cfg.format)
+	}
+
+	if cfg.hopScreens && cfg.output == "" {
+		return nil, errors.New("-hop-screenshots requires -o screenshot path")
+	}
+
+	if cfg.command == CommandScreenshot && cfg.output == "" {
+		cfg.output = "screenshot.png"
+	}
+
+	cfg.size = tracer.NewScreenSize(*width, *height)
+
+	if *deviceName != "" {
+		cfg.device, err = tracer.DeviceByName(*deviceName)
+		if err != nil {
+			return nil, err
+		}
+
+		// explicitly provided width and height override device viewport
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "width":
+				cfg.device.Width = *width
+			case "height":
+				cfg.device.Height = *height
+			}
+		})
+
+		cfg.size = cfg.device.ScreenSize()
+	}
+
+	cfg.request, err = parseRequestOptions(*userAgent, *referer, *acceptLanguage, headers, cookies)
+	if err != nil {
+		return nil, err
+	}
+
+	if *proxy != "" {
+		cfg.proxy, err = tracer.ParseProxy(*proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.geo = &tracer.Geo{Timezone: *timezone, Locale: *locale}
+
+	if *geolocation != "" {
+		cfg.geo.Location, err = tracer.ParseGeoLocation(*geolocation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.waitStrategy, err = tracer.NewWaitStrategy(*waitType, *waitTimeout, *idleTime, *selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wait strategy. %s", err)
+	}
+
+	return cfg, nil
+}
+
+// parseRequestOptions create custom settings of the traced url request from flags values
+func parseRequestOptions(userAgent, referer, acceptLanguage string, headers, cookies []string) (*tracer.RequestOptions, error) {
+	request := &tracer.RequestOptions{
+		UserAgent:      userAgent,
+		Referer:        referer,
+		AcceptLanguage: acceptLanguage,
+		Headers:        http.Header{},
+	}
+
+	if referer != "" {
+		if _, err := url.ParseRequestURI(referer); err != nil {
+			return nil, fmt.Errorf("invalid referer %s", err)
+		}
+	}
+
+	for _, line := range headers {
+		name, value, err := tracer.ParseHeader(line)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Headers.Add(name, value)
+	}
+
+	for _, line := range cookies {
+		parsed, err := tracer.ParseRequestCookies(line)
+		if err != nil {
+			return nil, err
+		}
+
+		request.Cookies = append(request.Cookies, parsed...)
+	}
+
+	return request, nil
+}
+
+// trace run the tracer and print redirects chain. Looped chain is printed as well, the loop error is returned then
+func trace(cfg *config, stdout io.Writer) error {
+	var redirects []*tracer.Redirect
+	var err error
+
+	startedAt := time.Now().UTC()
+
+	if cfg.tracer == "http" {
+		ht := tracer.NewHTTPTracer(nil)
+		ht.SetRequestOptions(cfg.request)
+		ht.SetProxy(cfg.proxy)
+		ht.SetMaxHops(cfg.maxHops)
+
+		redirects, err = ht.Trace(cfg.url, "")
+	} else {
+		err = withChrome(cfg, func(chr *tracer.ChromeTracer, fileName string) error {
+			var err error
+			redirects, err = chr.Trace(cfg.url, fileName)
+
+			return err
+		})
+	}
+
+	if err != nil && !errors.Is(err, tracer.ErrRedirectLoop) {
+		return err
+	}
+	// keep loop error to fail the command when the chain is printed
+	loopErr := err
+
+	jsonRedirects := tracer.NewJSONRedirects(redirects)
+
+	if cfg.har != "" {
+		if err := writeHAR(cfg.har, tracer.NewHAR(startedAt, jsonRedirects)); err != nil {
+			return fmt.Errorf("cannot save HAR. %s", err)
+		}
+	}
+
+	if err := printRedirects(stdout, cfg.format, jsonRedirects); err != nil {
+		return err
+	}
+
+	// json output is the list of hops only, truncated hop is marked there
+	if cfg.format == FormatTable {
+		printChainStatus(stdout, redirects)
+	}
+
+	return loopErr
+}
+
+// printChainStatus write a note if the chain is truncated or looped
+func printChainStatus(w io.Writer, redirects []*tracer.Redirect) {
+	if loop := tracer.DetectLoop(redirects); loop != nil {
+		fmt.Fprintf(w, "loop detected from hop %d: %s\n", loop.Start+1, strings.Join(loop.URLs, " -> "))
+	} else if tracer.IsTruncated(redirects) {
+		fmt.Fprintln(w, "chain is truncated: hops limit is exceeded")
+	}
+}
+
+// writeHAR save HAR document to the file
+func writeHAR(path string, har *tracer.HAR) error {
+	data, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func screenshot(cfg *config, stdout io.Writer) error {
+	err := withChrome(cfg, func(chr *tracer.ChromeTracer, fileName string) error {
+		return chr.Screenshot(cfg.url, cfg.size, fileName)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, cfg.output)
+
+	return nil
+}
+
+// withChrome connect to chrome (launch new one if remote address isn't provided),
+// create configured chrome tracer and pass it to the callback with screenshot file name
+func withChrome(cfg *config, callback func(chr *tracer.ChromeTracer, fileName string) error) error {
+	address := cfg.remote
+
+	if address == "" {
+		pool := browser.NewPool(browser.Options{
+			ChromePath:  cfg.chromePath,
+			UserAgent:   browser.DefaultUserAgent,
+			Size:        1,
+			BasePort:    cfg.port,
+			MaxInFlight: 1,
+		})
+
+		if err := pool.Start(); err != nil {
+			return fmt.Errorf("cannot launch chrome. %s", err)
+		}
+		defer pool.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), browserAcquireTimeout)
+		defer cancel()
+
+		b, err := pool.Acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("no browser available: %s", err)
+		}
+		defer pool.Release(b)
+
+		address = b.Address()
+	}
+
+	remote, err := godet.Connect(address, false)
+	if err != nil {
+		return fmt.Errorf("cannot connect to Chrome instance: %s", err)
+	}
+	defer remote.Close()
+
+	dir, fileName := "", ""
+	if cfg.output != "" {
+		dir, fileName = filepath.Split(cfg.output)
+	}
+
+	chr := tracer.NewChromeTracer(remote, cfg.size, dir)
+	chr.SetWaitStrategy(cfg.waitStrategy)
+
+	if cfg.device != nil {
+		chr.SetDevice(cfg.device)
+	}
+	chr.SetRequestOptions(cfg.request)
+	chr.SetGeo(cfg.geo)
+	chr.SetNetworkLog(cfg.networkLog)
+	chr.SetFrameTree(cfg.frameTree)
+	chr.SetHopScreenshots(cfg.hopScreens)
+	chr.SetMaxHops(cfg.maxHops)
+
+	if err := chr.SetProxy(cfg.proxy); err != nil {
+		return err
+	}
+
+	return callback(chr, fileName)
+}
+
+// printRedirects write redirects chain in provided format
+func printRedirects(w io.Writer, format string, redirects []*tracer.JSONRedirect) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(redirects)
+	case FormatJSONL:
+		encoder := json.NewEncoder(w)
+
+		for _, r := range redirects {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return printTable(w, redirects)
+	}
+}
+
+// printTable write redirects chain as human readable table
+func printTable(w io.Writer, redirects []*tracer.JSONRedirect) error {
+	if len(redirects) == 0 {
+		_, err := fmt.Fprintln(w, "no redirects")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tSTATUS\tTYPE\tINITIATOR\tDELAY\tTIME\tFROM\tTO")
+
+	printTableRows(tw, "", redirects)
+
+	return tw.Flush()
+}
+
+// printTableRows write hops of the chain, child frames chains are numbered after the hop (`2.1.1`)
+func printTableRows(w io.Writer, prefix string, redirects []*tracer.JSONRedirect) {
+	for i, r := range redirects {
+		number := fmt.Sprintf("%s%d", prefix, i+1)
+
+		from, to := r.From, r.To
+		// hop screenshot shows `From` page, final response one shows `To` page
+		if r.ScreenshotFileName != "" {
+			if i == len(redirects)-1 {
+				to = strings.TrimSpace(to + " (screenshot: " + r.ScreenshotFileName + ")")
+			} else {
+				from = strings.TrimSpace(from + " (screenshot: " + r.ScreenshotFileName + ")")
+			}
+		}
+
+		if r.Truncated {
+			to = strings.TrimSpace(to + " (not followed)")
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%dms\t%s\t%s\t%s\n", number, r.Status, dash(r.Type), dash(r.Initiator), r.Delay, hopTime(r.Timing), dash(from), dash(to))
+
+		// network log resources are printed below the hop
+		for _, res := range r.Resources {
+			resourceURL := res.URL
+			if res.ThirdParty {
+				resourceURL += " (third party)"
+			}
+
+			fmt.Fprintf(w, "\t%d\t%s\t%s\t\t\t\t%s\n", res.Status, strings.ToLower(res.Type), dash(res.Initiator), resourceURL)
+		}
+
+		for j, f := range r.Frames {
+			printTableRows(w, fmt.Sprintf("%s.%d.", number, j+1), f.Redirects)
+		}
+	}
+}
+
+// hopTime format hop latency
+func hopTime(t *tracer.JSONTiming) string {
+	if t == nil {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.0fms", t.Total)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,264 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lroman242/redirective/tracer"
+)
+
+func newRedirectTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/step0", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/step1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/step1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("final"))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestIsCommand(t *testing.T) {
+	if !IsCommand(CommandTrace) || !IsCommand(CommandScreenshot) {
+		t.Error("expect trace and screenshot to be commands")
+	}
+
+	if IsCommand("-screenshotsPath") {
+		t.Error("expect server flags not to be commands")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	cfg, err := parseArgs([]string{"trace", "-tracer", "http", "-format", "json", "-width", "375", "-height", "812", "-wait", "load", "-network-log", "http://example.com"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if cfg.url.String() != "http://example.com" {
+		t.Errorf("invalid url. expect http://example.com but get %s", cfg.url)
+	}
+
+	if cfg.tracer != "http" || cfg.format != FormatJSON {
+		t.Errorf("invalid tracer or format. expect http, json but get %s, %s", cfg.tracer, cfg.format)
+	}
+
+	if cfg.size.Width != 375 || cfg.size.Height != 812 {
+		t.Errorf("invalid screen size. expect 375x812 but get %dx%d", cfg.size.Width, cfg.size.Height)
+	}
+
+	if cfg.waitStrategy.Type != tracer.WaitLoad {
+		t.Errorf("invalid wait strategy. expect %s but get %s", tracer.WaitLoad, cfg.waitStrategy.Type)
+	}
+
+	if !cfg.networkLog {
+		t.Error("expect network log to be enabled")
+	}
+
+	cfg, err = parseArgs([]string{"screenshot", "http://example.com"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if cfg.output != "screenshot.png" {
+		t.Errorf("invalid default screenshot output. expect screenshot.png but get %s", cfg.output)
+	}
+
+	cfg, err = parseArgs([]string{"trace", "-device", "iphone", "-height", "700", "http://example.com"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if cfg.device == nil || !cfg.device.Mobile || cfg.size.Width != 375 || cfg.size.Height != 700 {
+		t.Errorf("expect iphone device with overridden height but get %+v", cfg.device)
+	}
+
+	cfg, err = parseArgs([]string{"trace", "-user-agent", "test-agent", "-referer", "http://referer.test", "-header", "X-A: 1", "-header", "X-B: 2", "-cookie", "a=1; b=2", "http://example.com"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if cfg.request.UserAgent != "test-agent" || cfg.request.Referer != "http://referer.test" || len(cfg.request.Headers) != 2 || len(cfg.request.Cookies) != 2 {
+		t.Errorf("invalid request options %+v", cfg.request)
+	}
+
+	cfg, err = parseArgs([]string{"trace", "-proxy", "socks5://proxy.test:1080", "-geolocation", "52.52,13.405", "-timezone", "Europe/Berlin", "http://example.com"}, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if cfg.proxy.Scheme != "socks5" || cfg.geo.Location.Latitude != 52.52 || cfg.geo.Timezone != "Europe/Berlin" {
+		t.Errorf("invalid proxy %s or geo %+v", cfg.proxy, cfg.geo)
+	}
+}
+
+func TestParseArgs_Invalid(t *testing.T) {
+	invalid := [][]string{
+		{},
+		{"unknown", "http://example.com"},
+		{"trace"},
+		{"trace", "http://example.com", "http://example.org"},
+		{"trace", "example"},
+		{"trace", "-tracer", "curl", "http://example.com"},
+		{"trace", "-format", "xml", "http://example.com"},
+		{"trace", "-wait", "forever", "http://example.com"},
+		{"trace", "-hop-screenshots", "http://example.com"},
+		{"trace", "-device", "nokia", "http://example.com"},
+		{"trace", "-header", "X-A", "http://example.com"},
+		{"trace", "-cookie", "a", "http://example.com"},
+		{"trace", "-referer", "referer", "http://example.com"},
+		{"trace", "-proxy", "proxy.test", "http://example.com"},
+		{"trace", "-geolocation", "52.52", "http://example.com"},
+		{"trace", "-max-hops", "-1", "http://example.com"},
+	}
+
+	for _, args := range invalid {
+		if _, err := parseArgs(args, ioutil.Discard); err == nil {
+			t.Errorf("expect error for arguments %v", args)
+		}
+	}
+}
+
+func TestRun_HTTPTrace(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	code := Run([]string{"trace", "-tracer", "http", "-format", "jsonl", server.URL + "/step0"}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("expect exit code 0 but get %d. %s", code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expect 3 lines but get %d", len(lines))
+	}
+
+	redirect := new(tracer.JSONRedirect)
+	if err := json.Unmarshal([]byte(lines[0]), redirect); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if redirect.Status != http.StatusMovedPermanently || redirect.To != server.URL+"/step1" {
+		t.Errorf("invalid first redirect %+v", redirect)
+	}
+}
+
+func TestRun_HTTPTraceTruncated(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	code := Run([]string{"trace", "-tracer", "http", "-max-hops", "1", server.URL + "/step0"}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("expect exit code 0 but get %d. %s", code, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), server.URL+"/final (not followed)") || !strings.Contains(stdout.String(), "chain is truncated") {
+		t.Errorf("expect truncated chain to be reported. %s", stdout.String())
+	}
+
+	stdout.Reset()
+
+	// looped chain is printed and the command fails
+	code = Run([]string{"trace", "-tracer", "http", server.URL + "/loop"}, stdout, stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "redirect loop") {
+		t.Fatalf("expect exit code 1 with loop error but get %d. %s", code, stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), "loop detected from hop 1: "+server.URL+"/loop") {
+		t.Errorf("expect loop to be reported. %s", stdout.String())
+	}
+}
+
+func TestRun_HTTPTraceHAR(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	harPath := filepath.Join(t.TempDir(), "trace.har")
+
+	code := Run([]string{"trace", "-tracer", "http", "-har", harPath, server.URL + "/step0"}, ioutil.Discard, ioutil.Discard)
+	if code != 0 {
+		t.Fatalf("expect exit code 0 but get %d", code)
+	}
+
+	data, err := ioutil.ReadFile(harPath)
+	if err != nil {
+		t.Fatalf("expect HAR file to be saved. error: %s", err)
+	}
+
+	har := new(tracer.HAR)
+	if err := json.Unmarshal(data, har); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(har.Log.Entries) != 3 || har.Log.Entries[0].Request.URL != server.URL+"/step0" {
+		t.Errorf("invalid HAR entries %+v", har.Log.Entries)
+	}
+}
+
+func TestRun_InvalidArgs(t *testing.T) {
+	if code := Run([]string{"trace"}, ioutil.Discard, ioutil.Discard); code != 2 {
+		t.Errorf("expect exit code 2 but get %d", code)
+	}
+}
+
+func TestPrintRedirects(t *testing.T) {
+	redirects := []*tracer.JSONRedirect{
+		{From: "http://step0.test", To: "http://step1.test", Status: 302, Type: tracer.RedirectTypeHTTP, Initiator: "server", Timing: &tracer.JSONTiming{Total: 120.4}},
+		{To: "http://step1.test", Status: 200, ScreenshotFileName: "final.png", Frames: []*tracer.JSONFrame{
+			{ID: "frame", Redirects: []*tracer.JSONRedirect{{To: "http://offer.test", Status: 200}}},
+		}},
+	}
+
+	out := new(bytes.Buffer)
+	if err := printRedirects(out, FormatTable, redirects); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "#") {
+		t.Fatalf("expect header and 3 rows but get:\n%s", out.String())
+	}
+
+	if !strings.Contains(lines[1], "http://step0.test") || !strings.Contains(lines[1], "302") || !strings.Contains(lines[1], "120ms") {
+		t.Errorf("invalid first row `%s`", lines[1])
+	}
+
+	if !strings.Contains(lines[2], "screenshot: final.png") {
+		t.Errorf("expect screenshot to be printed in the final response row `%s`", lines[2])
+	}
+
+	if !strings.HasPrefix(lines[3], "2.1.1 ") || !strings.Contains(lines[3], "http://offer.test") {
+		t.Errorf("expect frame hop to be numbered after the parent hop `%s`", lines[3])
+	}
+
+	out.Reset()
+	if err := printRedirects(out, FormatJSON, redirects); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	var decoded []*tracer.JSONRedirect
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || len(decoded) != 2 {
+		t.Errorf("expect valid json array of 2 redirects")
+	}
+
+	out.Reset()
+	if err := printRedirects(out, FormatTable, nil); err != nil || strings.TrimSpace(out.String()) != "no redirects" {
+		t.Errorf("expect `no redirects` but get `%s`", out.String())
+	}
+}
@@ -0,0 +1,261 @@
+// Package config implements web server configuration. Settings are loaded from defaults,
+// YAML config file, environment variables and command line flags (the latter takes precedence)
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lroman242/redirective/browser"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+	"gopkg.in/yaml.v2"
+)
+
+// redactedPassword replaces password of storage dsn and admin token in printed config
+const redactedPassword = "xxxxx"
+
+// Config contains all web server settings
+type Config struct {
+	Server          Server    `yaml:"server"`
+	Chrome          Chrome    `yaml:"chrome"`
+	Storage         Storage   `yaml:"storage"`
+	Jobs            Jobs      `yaml:"jobs"`
+	Auth            Auth      `yaml:"auth"`
+	URLPolicy       URLPolicy `yaml:"url_policy"`
+	ScreenshotsPath string    `yaml:"screenshots_path"`
+	LogPath         string    `yaml:"log_path"`
+	// ProxyPools is a path to json file with named proxy pools, pools are disabled if empty
+	ProxyPools string `yaml:"proxy_pools"`
+}
+
+// Server contains http(s) listeners settings. Zero timeouts mean there is no timeout
+type Server struct {
+	HTTPAddr  string `yaml:"http_addr"`
+	HTTPSAddr string `yaml:"https_addr"`
+	// https server is started only if both certificate and key are set
+	CertPath     string        `yaml:"cert_path"`
+	KeyPath      string        `yaml:"key_path"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout limits how long running requests and traces are waited for on exit
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	CORS            CORS          `yaml:"cors"`
+}
+
+// CORS contains cross origin requests settings
+type CORS struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	Debug            bool     `yaml:"debug"`
+}
+
+// Chrome contains browsers pool settings
+type Chrome struct {
+	Path      string `yaml:"path"`
+	UserAgent string `yaml:"user_agent"`
+	// DevToolsAddress is an interface devtools listen on, BasePort is a devtools port of the first browser
+	DevToolsAddress     string        `yaml:"devtools_address"`
+	BasePort            int           `yaml:"base_port"`
+	Browsers            int           `yaml:"browsers"`
+	MaxInFlight         int           `yaml:"max_in_flight"`
+	RecycleAfter        int           `yaml:"recycle_after"`
+	StartupTimeout      time.Duration `yaml:"startup_timeout"`
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+}
+
+// Storage contains trace results storage settings
+type Storage struct {
+	Driver string `yaml:"driver"`
+	// DSN is a mongodb uri, sqlite database path or directory for file storage, default one is used if empty
+	DSN string `yaml:"dsn"`
+}
+
+// Jobs contains asynchronous traces settings
+type Jobs struct {
+	Workers          int `yaml:"workers"`
+	QueueSize        int `yaml:"queue_size"`
+	BatchConcurrency int `yaml:"batch_concurrency"`
+}
+
+// Auth contains api keys settings. RateLimit (requests per minute) and DailyQuota are defaults of issued keys,
+// 0 - unlimited. Admin endpoints are disabled if AdminToken is empty
+type Auth struct {
+	Enabled    bool   `yaml:"enabled"`
+	AdminToken string `yaml:"admin_token"`
+	RateLimit  int    `yaml:"rate_limit"`
+	DailyQuota int    `yaml:"daily_quota"`
+}
+
+// URLPolicy contains rules of urls which could be traced. Private, loopback and link-local addresses are blocked
+// unless AllowPrivate is set. Rules are domains (subdomains match too), ip addresses or networks in CIDR notation
+type URLPolicy struct {
+	AllowPrivate bool     `yaml:"allow_private"`
+	Allow        []string `yaml:"allow"`
+	Deny         []string `yaml:"deny"`
+}
+
+// Default return config with default settings
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			HTTPAddr:        ":8080",
+			HTTPSAddr:       ":8083",
+			ShutdownTimeout: 30 * time.Second,
+			CORS: CORS{
+				AllowedOrigins:   []string{"*"},
+				AllowCredentials: true,
+				Debug:            true,
+			},
+		},
+		Chrome: Chrome{
+			Path:                "/usr/bin/google-chrome",
+			UserAgent:           browser.DefaultUserAgent,
+			DevToolsAddress:     "0.0.0.0",
+			BasePort:            9222,
+			Browsers:            1,
+			MaxInFlight:         4,
+			RecycleAfter:        500,
+			StartupTimeout:      10 * time.Second,
+			HealthCheckInterval: 10 * time.Second,
+		},
+		Storage: Storage{
+			Driver: storage.DriverMongo,
+		},
+		Jobs: Jobs{
+			Workers:          4,
+			QueueSize:        1000,
+			BatchConcurrency: 4,
+		},
+		Auth: Auth{
+			RateLimit:  60,
+			DailyQuota: 1000,
+		},
+		ScreenshotsPath: "assets/screenshots",
+		LogPath:         "log/redirective.log",
+	}
+}
+
+// TLS check if https server should be started
+func (c *Config) TLS() bool {
+	return c.Server.CertPath != "" && c.Server.KeyPath != ""
+}
+
+// Validate check all settings, every found problem is reported
+func (c *Config) Validate() error {
+	var problems []string
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(isAddress(c.Server.HTTPAddr), "server.http_addr `%s` should be `[host]:port`", c.Server.HTTPAddr)
+	check((c.Server.CertPath == "") == (c.Server.KeyPath == ""), "server.cert_path and server.key_path should be set together")
+
+	if c.TLS() {
+		check(isAddress(c.Server.HTTPSAddr), "server.https_addr `%s` should be `[host]:port`", c.Server.HTTPSAddr)
+		check(isFile(c.Server.CertPath), "server.cert_path `%s` file not found", c.Server.CertPath)
+		check(isFile(c.Server.KeyPath), "server.key_path `%s` file not found", c.Server.KeyPath)
+	}
+
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout should not be negative")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout should not be negative")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout should not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout should be positive")
+
+	check(isFile(c.Chrome.Path), "chrome.path `%s` file not found", c.Chrome.Path)
+	check(net.ParseIP(c.Chrome.DevToolsAddress) != nil, "chrome.devtools_address `%s` should be an ip address", c.Chrome.DevToolsAddress)
+	check(c.Chrome.Browsers > 0, "chrome.browsers should be positive")
+	check(c.Chrome.BasePort > 0 && c.Chrome.BasePort+c.Chrome.Browsers-1 <= 65535, "chrome.base_port %d is out of range", c.Chrome.BasePort)
+	check(c.Chrome.MaxInFlight > 0, "chrome.max_in_flight should be positive")
+	check(c.Chrome.RecycleAfter >= 0, "chrome.recycle_after should not be negative")
+	check(c.Chrome.StartupTimeout > 0, "chrome.startup_timeout should be positive")
+	check(c.Chrome.HealthCheckInterval > 0, "chrome.health_check_interval should be positive")
+
+	check(isDriver(c.Storage.Driver), "unknown storage.driver `%s`", c.Storage.Driver)
+
+	check(c.Jobs.Workers > 0, "jobs.workers should be positive")
+	check(c.Jobs.QueueSize > 0, "jobs.queue_size should be positive")
+	check(c.Jobs.BatchConcurrency > 0, "jobs.batch_concurrency should be positive")
+
+	check(!c.Auth.Enabled || c.Auth.AdminToken != "", "auth.admin_token is required if auth is enabled")
+	check(c.Auth.RateLimit >= 0, "auth.rate_limit should not be negative")
+	check(c.Auth.DailyQuota >= 0, "auth.daily_quota should not be negative")
+
+	if _, err := tracer.NewURLPolicy(c.URLPolicy.Allow, c.URLPolicy.Deny, c.URLPolicy.AllowPrivate); err != nil {
+		check(false, "url_policy: %s", err)
+	}
+
+	check(c.ScreenshotsPath != "", "screenshots_path is required")
+	check(c.LogPath != "", "log_path is required")
+	check(c.ProxyPools == "" || isFile(c.ProxyPools), "proxy_pools `%s` file not found", c.ProxyPools)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Print write effective config in YAML format, storage dsn password and admin token are redacted
+func (c *Config) Print(w io.Writer) error {
+	printed := *c
+	printed.Storage.DSN = redactDSN(c.Storage.DSN)
+
+	if printed.Auth.AdminToken != "" {
+		printed.Auth.AdminToken = redactedPassword
+	}
+
+	data, err := yaml.Marshal(&printed)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// redactDSN replace password of url dsn (mongodb uri), other dsn are returned as is
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.User == nil {
+		return dsn
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redactedPassword)
+	}
+
+	return u.String()
+}
+
+func isAddress(address string) bool {
+	_, port, err := net.SplitHostPort(address)
+
+	return err == nil && port != ""
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && !info.IsDir()
+}
+
+func isDriver(driver string) bool {
+	switch driver {
+	case storage.DriverMemory, storage.DriverFile, storage.DriverSQLite, storage.DriverMongo:
+		return true
+	}
+
+	return false
+}
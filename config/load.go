@@ -0,0 +1,189 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// configPathEnv and configPathFlag set path to YAML config file
+const (
+	configPathEnv  = "CONFIG_PATH"
+	configPathFlag = "config"
+)
+
+// setting bind config field to command line flag and environment variable
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	field func(c *Config) interface{}
+}
+
+var settings = []setting{
+	{"httpAddr", "HTTP_ADDR", "Address of http server", func(c *Config) interface{} { return &c.Server.HTTPAddr }},
+	{"httpsAddr", "HTTPS_ADDR", "Address of https server", func(c *Config) interface{} { return &c.Server.HTTPSAddr }},
+	{"certPath", "CERT_PATH", "Path to the certificate file", func(c *Config) interface{} { return &c.Server.CertPath }},
+	{"keyPath", "KEY_PATH", "Path to the key file", func(c *Config) interface{} { return &c.Server.KeyPath }},
+	{"readTimeout", "READ_TIMEOUT", "Max duration of reading request, 0 - no timeout", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"writeTimeout", "WRITE_TIMEOUT", "Max duration of writing response, 0 - no timeout", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"idleTimeout", "IDLE_TIMEOUT", "Max duration of keep-alive connection idle time, 0 - no timeout", func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"shutdownTimeout", "SHUTDOWN_TIMEOUT", "Max duration of waiting for running requests and traces on exit", func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+	{"corsOrigins", "CORS_ORIGINS", "Comma separated list of allowed origins", func(c *Config) interface{} { return &c.Server.CORS.AllowedOrigins }},
+	{"corsCredentials", "CORS_CREDENTIALS", "Allow cross origin requests with credentials", func(c *Config) interface{} { return &c.Server.CORS.AllowCredentials }},
+	{"corsDebug", "CORS_DEBUG", "Log CORS decisions", func(c *Config) interface{} { return &c.Server.CORS.Debug }},
+	{"chromePath", "CHROME_PATH", "Path to google chrome executable", func(c *Config) interface{} { return &c.Chrome.Path }},
+	{"chromeUserAgent", "CHROME_USER_AGENT", "User agent of browsers", func(c *Config) interface{} { return &c.Chrome.UserAgent }},
+	{"devtoolsAddress", "DEVTOOLS_ADDRESS", "Address browsers devtools listen on", func(c *Config) interface{} { return &c.Chrome.DevToolsAddress }},
+	{"devtoolsPort", "DEVTOOLS_PORT", "Devtools port of the first browser, next ones use the following ports", func(c *Config) interface{} { return &c.Chrome.BasePort }},
+	{"browsers", "BROWSERS", "Amount of google chrome processes", func(c *Config) interface{} { return &c.Chrome.Browsers }},
+	{"browserMaxInFlight", "BROWSER_MAX_IN_FLIGHT", "Max amount of simultaneous traces per browser", func(c *Config) interface{} { return &c.Chrome.MaxInFlight }},
+	{"browserRecycleAfter", "BROWSER_RECYCLE_AFTER", "Restart browser after provided amount of traces, 0 - never", func(c *Config) interface{} { return &c.Chrome.RecycleAfter }},
+	{"browserStartupTimeout", "BROWSER_STARTUP_TIMEOUT", "Max time to wait for browser devtools after launch", func(c *Config) interface{} { return &c.Chrome.StartupTimeout }},
+	{"browserHealthCheckInterval", "BROWSER_HEALTH_CHECK_INTERVAL", "Period of browsers health checks", func(c *Config) interface{} { return &c.Chrome.HealthCheckInterval }},
+	{"storage", "STORAGE", "Trace results storage: mongo, sqlite, file or memory", func(c *Config) interface{} { return &c.Storage.Driver }},
+	{"storageDSN", "STORAGE_DSN", "Mongodb uri, sqlite database path or directory for file storage, default one is used if empty", func(c *Config) interface{} { return &c.Storage.DSN }},
+	{"jobWorkers", "JOB_WORKERS", "Amount of asynchronous trace workers", func(c *Config) interface{} { return &c.Jobs.Workers }},
+	{"jobQueueSize", "JOB_QUEUE_SIZE", "Max amount of queued asynchronous traces", func(c *Config) interface{} { return &c.Jobs.QueueSize }},
+	{"batchConcurrency", "BATCH_CONCURRENCY", "Max amount of simultaneous traces per batch request", func(c *Config) interface{} { return &c.Jobs.BatchConcurrency }},
+	{"authEnabled", "AUTH_ENABLED", "Require api key for every api request", func(c *Config) interface{} { return &c.Auth.Enabled }},
+	{"adminToken", "ADMIN_TOKEN", "Token of api keys admin endpoints, they are disabled if empty", func(c *Config) interface{} { return &c.Auth.AdminToken }},
+	{"keyRateLimit", "KEY_RATE_LIMIT", "Default requests per minute of issued api keys, 0 - unlimited", func(c *Config) interface{} { return &c.Auth.RateLimit }},
+	{"keyDailyQuota", "KEY_DAILY_QUOTA", "Default requests per day of issued api keys, 0 - unlimited", func(c *Config) interface{} { return &c.Auth.DailyQuota }},
+	{"allowPrivateURLs", "ALLOW_PRIVATE_URLS", "Allow tracing of private, loopback and link-local addresses", func(c *Config) interface{} { return &c.URLPolicy.AllowPrivate }},
+	{"urlAllow", "URL_ALLOW", "Comma separated list of allowed domains, ips and networks, everything is allowed if empty", func(c *Config) interface{} { return &c.URLPolicy.Allow }},
+	{"urlDeny", "URL_DENY", "Comma separated list of denied domains, ips and networks", func(c *Config) interface{} { return &c.URLPolicy.Deny }},
+	{"screenshotsPath", "SCREENSHOTS_PATH", "Path to directory where screenshots would be stored", func(c *Config) interface{} { return &c.ScreenshotsPath }},
+	{"logPath", "LOG_PATH", "Path to the log file", func(c *Config) interface{} { return &c.LogPath }},
+	{"proxyPools", "PROXY_POOLS", "Path to json file with named proxy pools, pools are disabled if empty", func(c *Config) interface{} { return &c.ProxyPools }},
+}
+
+// flagValue keep raw value of the flag, it is applied after config file and environment variables
+type flagValue struct {
+	value string
+	isSet bool
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	v.value, v.isSet = value, true
+	return nil
+}
+
+// Load create config from defaults, YAML file (`-config` flag or CONFIG_PATH env), environment variables
+// and command line flags. Every next source overrides the previous ones. Loaded config is validated.
+// flag.ErrHelp is returned if help is requested
+func Load(args []string, getenv func(string) string, output io.Writer) (*Config, error) {
+	cfg := Default()
+
+	flags := flag.NewFlagSet("redirective", flag.ContinueOnError)
+	flags.SetOutput(output)
+
+	configPath := flags.String(configPathFlag, getenv(configPathEnv), "Path to YAML config file | set this flag or env "+configPathEnv)
+
+	values := make([]*flagValue, len(settings))
+	for i, s := range settings {
+		values[i] = &flagValue{value: formatValue(s.field(cfg))}
+		flags.Var(values[i], s.flag, fmt.Sprintf("%s | set this flag or env %s", s.usage, s.env))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(cfg, *configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if env := getenv(s.env); env != "" {
+			if err := parseValue(s.field(cfg), env); err != nil {
+				return nil, fmt.Errorf("invalid env %s. %s", s.env, err)
+			}
+		}
+	}
+
+	for i, s := range settings {
+		if values[i].isSet {
+			if err := parseValue(s.field(cfg), values[i].value); err != nil {
+				return nil, fmt.Errorf("invalid flag -%s. %s", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config. %s", err)
+	}
+
+	return cfg, nil
+}
+
+// loadFile override config settings with the ones from YAML file, unknown keys are rejected
+func loadFile(cfg *Config, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read config file. %s", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("cannot parse config file %s. %s", path, err)
+	}
+
+	return nil
+}
+
+// parseValue set config field from flag or environment variable value
+func parseValue(field interface{}, value string) error {
+	var err error
+
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		*f, err = strconv.Atoi(value)
+	case *bool:
+		*f, err = strconv.ParseBool(value)
+	case *time.Duration:
+		*f, err = time.ParseDuration(value)
+	case *[]string:
+		*f = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*f = append(*f, item)
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported setting type %T", field)
+	}
+
+	return err
+}
+
+// formatValue convert config field to flag value, it is used as default value in usage message
+func formatValue(field interface{}) string {
+	switch f := field.(type) {
+	case *string:
+		return *f
+	case *int:
+		return strconv.Itoa(*f)
+	case *bool:
+		return strconv.FormatBool(*f)
+	case *time.Duration:
+		return f.String()
+	case *[]string:
+		return strings.Join(*f, ",")
+	}
+
+	return ""
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// testEnv return getenv function with provided variables
+func testEnv(vars map[string]string) func(string) string {
+	return func(key string) string {
+		return vars[key]
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	chromePath := writeTestFile(t, "google-chrome", "")
+	configPath := writeTestFile(t, "config.yaml", `
+server:
+  http_addr: ":9000"
+  read_timeout: 15s
+  cors:
+    allowed_origins: ["https://redirective.net"]
+chrome:
+  path: `+chromePath+`
+  browsers: 2
+jobs:
+  workers: 8
+  queue_size: 10
+storage:
+  driver: memory
+`)
+
+	env := testEnv(map[string]string{
+		"CONFIG_PATH": configPath,
+		"BROWSERS":    "3",
+		"JOB_WORKERS": "16",
+	})
+
+	cfg, err := Load([]string{"-jobWorkers", "32", "-corsDebug=false"}, env, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	// file overrides defaults
+	if cfg.Server.HTTPAddr != ":9000" || cfg.Server.ReadTimeout != 15*time.Second || cfg.Jobs.QueueSize != 10 || cfg.Storage.Driver != "memory" {
+		t.Errorf("expect file settings to be loaded %+v", cfg)
+	}
+
+	if len(cfg.Server.CORS.AllowedOrigins) != 1 || cfg.Server.CORS.AllowedOrigins[0] != "https://redirective.net" {
+		t.Errorf("invalid allowed origins %v", cfg.Server.CORS.AllowedOrigins)
+	}
+
+	// env overrides file
+	if cfg.Chrome.Browsers != 3 {
+		t.Errorf("expect browsers 3 but get %d", cfg.Chrome.Browsers)
+	}
+
+	// flag overrides env
+	if cfg.Jobs.Workers != 32 || cfg.Server.CORS.Debug {
+		t.Errorf("expect flags to override settings %+v", cfg.Jobs)
+	}
+
+	// default values are kept
+	if cfg.Chrome.BasePort != 9222 || cfg.Server.HTTPSAddr != ":8083" || !cfg.Server.CORS.AllowCredentials {
+		t.Errorf("expect defaults to be kept %+v", cfg.Server)
+	}
+}
+
+func TestLoad_ConfigFlag(t *testing.T) {
+	chromePath := writeTestFile(t, "google-chrome", "")
+	configPath := writeTestFile(t, "config.yaml", "chrome:\n  path: "+chromePath+"\n")
+
+	cfg, err := Load([]string{"-config", configPath, "-corsOrigins", "https://a.test, https://b.test"}, testEnv(nil), ioutil.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(cfg.Server.CORS.AllowedOrigins) != 2 || cfg.Server.CORS.AllowedOrigins[1] != "https://b.test" {
+		t.Errorf("invalid allowed origins %v", cfg.Server.CORS.AllowedOrigins)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	chromePath := writeTestFile(t, "google-chrome", "")
+	unknownKey := writeTestFile(t, "unknown.yaml", "chrome:\n  path: "+chromePath+"\n  tabs: 3\n")
+
+	cases := []struct {
+		args []string
+		env  map[string]string
+	}{
+		{[]string{"-chromePath", chromePath, "-browsers", "many"}, nil},
+		{[]string{"-chromePath", chromePath}, map[string]string{"READ_TIMEOUT": "15"}},
+		{[]string{"-chromePath", chromePath, "-unknown"}, nil},
+		{[]string{"-config", unknownKey}, nil},
+		{[]string{"-config", "/not/exists/config.yaml"}, nil},
+		// chrome executable doesn't exist
+		{[]string{"-chromePath", "/not/exists/chrome"}, nil},
+	}
+
+	for _, c := range cases {
+		if _, err := Load(c.args, testEnv(c.env), ioutil.Discard); err == nil {
+			t.Errorf("expect error for args %v and env %v", c.args, c.env)
+		}
+	}
+
+	if _, err := Load([]string{"-h"}, testEnv(nil), ioutil.Discard); err != flag.ErrHelp {
+		t.Errorf("expect help error but get `%v`", err)
+	}
+}
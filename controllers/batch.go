@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/lroman242/redirective/auth"
+	"github.com/lroman242/redirective/jobs"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+)
+
+const (
+	maxBatchSize              = 1000
+	maxBatchBodySize          = 10 << 20
+	contentTypeJSONL          = "application/x-ndjson"
+	errorMessageBatchCanceled = "trace canceled"
+)
+
+// batchItem is a single batch entry: trace job request object or plain url string
+type batchItem struct {
+	traceJobRequest
+}
+
+// UnmarshalJSON accept both `"http://..."` and `{"url": "http://...", ...}` items
+func (bi *batchItem) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &bi.URL)
+	}
+
+	return json.Unmarshal(data, &bi.traceJobRequest)
+}
+
+// batchResult describe single batch entry result
+type batchResult struct {
+	Index int `json:"index"`
+	*storage.Trace
+}
+
+// BatchTrace trace many urls in one request. Body could be a json array or jsonl (one item per line),
+// each item is an url string or an object with url, tracer and options.
+// Results are returned in the same order as urls, or streamed as jsonl in order of completion
+// when `stream=true` query param is provided (or `Accept: application/x-ndjson` header).
+// Every item is charged as a separate request of the api key
+func BatchTrace(w http.ResponseWriter, r *http.Request, queue *jobs.Queue, concurrency int, authenticator *auth.Authenticator) {
+	items, err := parseBatchItems(http.MaxBytesReader(w, r.Body, maxBatchBodySize))
+	if err != nil {
+		failed(w, "", invalidRequest(err))
+
+		return
+	}
+
+	// the first item is charged with the request itself
+	if !authenticator.Charge(w, r, len(items)-1) {
+		return
+	}
+
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make(chan *batchResult)
+
+	go runBatch(r, queue, items, concurrency, results)
+
+	if r.URL.Query().Get("stream") == "true" || strings.Contains(r.Header.Get("Accept"), contentTypeJSONL) {
+		streamBatchResults(w, results)
+
+		return
+	}
+
+	data := make([]*batchResult, len(items))
+	for result := range results {
+		data[result.Index] = result
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    fmt.Sprintf("%d urls traced", len(items)),
+		StatusCode: 200,
+		Data:       data}).Success(w)
+}
+
+// runBatch trace batch items using limited amount of workers and send results into the channel
+func runBatch(r *http.Request, queue *jobs.Queue, items []*batchItem, concurrency int, results chan<- *batchResult) {
+	defer close(results)
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < concurrency && i < len(items); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				results <- runBatchItem(r, queue, index, items[index])
+			}
+		}()
+	}
+
+	ctx := r.Context()
+
+	for index := range items {
+		if ctx.Err() != nil {
+			results <- failedBatchResult(index, items[index], errors.New(errorMessageBatchCanceled))
+			continue
+		}
+
+		indexes <- index
+	}
+
+	close(indexes)
+	wg.Wait()
+}
+
+func runBatchItem(r *http.Request, queue *jobs.Queue, index int, item *batchItem) *batchResult {
+	job, err := newTraceJob(&item.traceJobRequest, requester(r))
+	if err != nil {
+		return failedBatchResult(index, item, err)
+	}
+
+	err = queue.Run(r.Context(), job)
+	if err != nil {
+		return failedBatchResult(index, item, err)
+	}
+
+	return &batchResult{Index: index, Trace: job}
+}
+
+func failedBatchResult(index int, item *batchItem, err error) *batchResult {
+	options, _ := redactOptions(item.Options)
+
+	job := storage.NewTrace(item.URL, item.Tracer, options, item.CallbackURL)
+	job.Status = storage.StatusFailed
+	job.Error = err.Error()
+	job.ErrorCode = errorCode(err)
+
+	return &batchResult{Index: index, Trace: job}
+}
+
+// streamBatchResults write every result as a separate json line as soon as it is ready
+func streamBatchResults(w http.ResponseWriter, results <-chan *batchResult) {
+	w.Header().Set("Content-Type", contentTypeJSONL)
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	for result := range results {
+		// keep reading results even if client is gone, so batch workers are not blocked
+		_ = encoder.Encode(result)
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// parseBatchItems read json array or jsonl batch body
+func parseBatchItems(body io.Reader) ([]*batchItem, error) {
+	reader := bufio.NewReader(body)
+
+	first, err := peekFirstNonSpace(reader)
+	if err == io.EOF {
+		return nil, errors.New("batch is empty")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body. %s", err)
+	}
+
+	var items []*batchItem
+
+	decoder := json.NewDecoder(reader)
+
+	if first == '[' {
+		if err := decoder.Decode(&items); err != nil {
+			return nil, fmt.Errorf("invalid request body. %s", err)
+		}
+	} else {
+		for {
+			item := new(batchItem)
+
+			err := decoder.Decode(item)
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid request body. line %d: %s", len(items)+1, err)
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, errors.New("batch is empty")
+	}
+
+	if len(items) > maxBatchSize {
+		return nil, fmt.Errorf("too many urls in batch, max %d allowed", maxBatchSize)
+	}
+
+	for i, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("invalid request body. item %d is null", i)
+		}
+	}
+
+	return items, nil
+}
+
+func peekFirstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, reader.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lroman242/redirective/browser"
+	"github.com/lroman242/redirective/tracer"
+	"github.com/raff/godet"
+)
+
+// browserAcquireTimeout limits how long request waits in the queue for a free browser
+const browserAcquireTimeout = 30 * time.Second
+
+// connectToBrowser acquire browser from the pool and connect to its devtools.
+// Returned release function closes the connection and frees the pool slot
+func connectToBrowser(ctx context.Context, pool *browser.Pool) (*godet.RemoteDebugger, func(), error) {
+	ctx, cancel := context.WithTimeout(ctx, browserAcquireTimeout)
+	defer cancel()
+
+	b, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w. no browser available: %s", tracer.ErrBrowserUnavailable, err)
+	}
+
+	remote, err := godet.Connect(b.Address(), false)
+	if err != nil {
+		pool.Release(b)
+
+		return nil, nil, fmt.Errorf("%w. cannot connect to Chrome instance: %s", tracer.ErrBrowserUnavailable, err)
+	}
+
+	// the connection is closed by the pool if shutdown deadline is exceeded
+	untrack := pool.Track(remote)
+
+	release := func() {
+		untrack()
+
+		if err := remote.Close(); err != nil {
+			log.Printf("remote.Close error: %s \n", err)
+		}
+
+		pool.Release(b)
+	}
+
+	return remote, release, nil
+}
@@ -3,81 +3,95 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"errors"
 	"fmt"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"log"
-	"math/rand"
 	"net/http"
 	"net/url"
-	"strconv"
 	"time"
 
+	"github.com/lroman242/redirective/browser"
+	"github.com/lroman242/redirective/proxy"
 	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
 	"github.com/lroman242/redirective/tracer"
-	"github.com/raff/godet"
 )
 
-const defaultScreenWidth = 1920
-const defaultScreenHeight = 1080
-
 const charset = "abcdefghijklmnopqrstuvwxyz" +
 	"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 // ChromeScreenshot function create image (screenshot) of active browser tab
-func ChromeScreenshot(w http.ResponseWriter, r *http.Request, screenshotsStoragePath string) {
-	remote, err := godet.Connect("localhost:9222", false)
-	if err != nil {
+func ChromeScreenshot(w http.ResponseWriter, r *http.Request, screenshotsStoragePath string, pool *browser.Pool, proxies *proxy.Pools, policy *tracer.URLPolicy) {
+	urlToTrace := r.URL.Query().Get("url")
+	if urlToTrace == "" {
 		(&response.Response{
 			Status:     false,
-			Message:    fmt.Sprintf("cannot connect to Chrome instance: %s", err),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
+			Message:    "url parameter is required",
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
 
 		return
 	}
+	// convert raw url string to url.URL
+	targetURL, err := url.ParseRequestURI(urlToTrace)
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    fmt.Sprintf("invalid url %s", err),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
 
-	defer func() {
-		err = remote.Close()
-		if err != nil {
-			log.Printf("remote.Close error: %s", err)
-		}
-	}()
+		return
+	}
 
-	chr := tracer.NewChromeTracer(remote, parseScreenSizeFromRequest(r), screenshotsStoragePath)
+	options, err := parseTraceOptions(r.URL.Query())
+	if err == nil {
+		err = options.resolveProxy(proxies)
+	}
 
-	urlToTrace := r.URL.Query().Get("url")
-	if urlToTrace == "" {
+	if err != nil {
 		(&response.Response{
 			Status:     false,
-			Message:    "url parameter is required",
+			Message:    err.Error(),
 			StatusCode: 400,
-			Data:       nil}).Failed(w)
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
 
 		return
 	}
-	// convert raw url string to url.URL
-	targetURL, err := url.ParseRequestURI(urlToTrace)
+	// user proxy could point to internal network, so it is checked with url policy
+	err = options.checkProxy(policy)
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+
+	remote, release, err := connectToBrowser(r.Context(), pool)
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+
+	defer release()
+
+	chr, err := options.newChromeTracer(remote, screenshotsStoragePath, policy)
 	if err != nil {
 		(&response.Response{
 			Status:     false,
-			Message:    fmt.Sprintf("invalid url %s", err),
+			Message:    err.Error(),
 			StatusCode: 400,
-			Data:       nil}).Failed(w)
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
 
 		return
 	}
 
 	screenShotFileName := randomScreenshotFileName()
 
-	err = chr.Screenshot(targetURL, parseScreenSizeFromRequest(r), screenShotFileName)
+	err = chr.Screenshot(targetURL, options.size, screenShotFileName)
 	if err != nil {
-		(&response.Response{
-			Status:     false,
-			Message:    fmt.Sprintf("an error occurred. %s", err),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
+		failed(w, "an error occurred. ", err)
 
 		return
 	}
@@ -90,27 +104,8 @@ func ChromeScreenshot(w http.ResponseWriter, r *http.Request, screenshotsStorage
 }
 
 // ChromeTrace parse a trace path for provided url
-func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath string, col *mongo.Collection) {
+func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath string, repo storage.TraceRepository, pool *browser.Pool, proxies *proxy.Pools, policy *tracer.URLPolicy) {
 	screenShotFileName := randomScreenshotFileName()
-	// connect to Chrome instance
-	remote, err := godet.Connect("localhost:9222", false)
-	if err != nil {
-		(&response.Response{
-			Status:     false,
-			Message:    fmt.Sprintf("cannot connect to Chrome instance: %s", err),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
-
-		return
-	}
-	// close connection
-	defer func() {
-		if err = remote.Close(); err != nil {
-			log.Printf("remote.Close error: %s \n", err)
-		}
-	}()
-	// create new tracer instance
-	chr := tracer.NewChromeTracer(remote, parseScreenSizeFromRequest(r), screenshotsStoragePath)
 	// check url
 	urlToTrace := r.URL.Query().Get("url")
 	if urlToTrace == "" {
@@ -118,7 +113,7 @@ func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath
 			Status:     false,
 			Message:    "url parameter is required",
 			StatusCode: 400,
-			Data:       nil}).Failed(w)
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
 
 		return
 	}
@@ -129,27 +124,83 @@ func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath
 			Status:     false,
 			Message:    fmt.Sprintf("invalid url %s", err),
 			StatusCode: 400,
-			Data:       nil}).Failed(w)
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
 
 		return
 	}
+	// parse tracer options (screen size, page settle strategy, proxy...)
+	options, err := parseTraceOptions(r.URL.Query())
+	if err == nil {
+		err = options.resolveProxy(proxies)
+	}
 
-	// process tracing
-	redirects, err := chr.Trace(targetURL, screenShotFileName)
 	if err != nil {
 		(&response.Response{
 			Status:     false,
-			Message:    fmt.Sprintf("sorry, an error occurred. %s", err),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
+			Message:    err.Error(),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+	// user proxy could point to internal network, so it is checked with url policy
+	err = options.checkProxy(policy)
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+	// connect to Chrome instance from the pool
+	remote, release, err := connectToBrowser(r.Context(), pool)
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+	// close connection and release the browser
+	defer release()
+	// create new tracer instance
+	chr, err := options.newChromeTracer(remote, screenshotsStoragePath, policy)
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    err.Error(),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+
+	trace := storage.NewTrace(urlToTrace, tracerNameChrome, queryOptions(r.URL.Query()), "")
+	trace.Requester = requester(r)
+	trace.ScreenSize = options.size
+	trace.Proxy = tracer.RedactProxy(options.proxy)
+	trace.Geo = options.geo
+	trace.Start()
+
+	// process tracing
+	redirects, err := chr.Trace(targetURL, screenShotFileName)
+	if errors.Is(err, tracer.ErrRedirectLoop) {
+		trace.SetRedirects(redirects)
+		trace.Screenshot = screenShotFileName
+		trace.UserAgent = userAgent(trace.Redirects)
+		failedLoop(w, repo, trace, err)
 
 		return
 	}
 
-	jsonRedirects := tracer.NewJSONRedirects(redirects)
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	res, err := col.InsertOne(ctx, bson.M{"redirects": jsonRedirects, "screenshot": screenShotFileName})
+	trace.SetRedirects(redirects)
+	trace.Screenshot = screenShotFileName
+	trace.UserAgent = userAgent(trace.Redirects)
+	trace.Finish(nil)
+
+	err = saveTrace(repo, trace)
 	if err != nil {
 		log.Printf("error occurred during saving trace results. error: %s \n", err)
 
@@ -158,11 +209,19 @@ func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath
 			Message:    "url successfully traced",
 			StatusCode: 200,
 			Data: struct {
-				Redirects  []*tracer.JSONRedirect `json:"redirects"`
-				Screenshot string                 `json:"screenshot"`
+				Redirects    []*tracer.JSONRedirect  `json:"redirects"`
+				Timing       *tracer.JSONChainTiming `json:"timing,omitempty"`
+				Truncated    bool                    `json:"truncated"`
+				LoopDetected bool                    `json:"loop_detected"`
+				Loop         *tracer.Loop            `json:"loop,omitempty"`
+				Screenshot   string                  `json:"screenshot"`
 			}{
-				Redirects:  jsonRedirects,
-				Screenshot: screenShotFileName,
+				Redirects:    trace.Redirects,
+				Timing:       trace.Timing,
+				Truncated:    trace.Truncated,
+				LoopDetected: trace.LoopDetected,
+				Loop:         trace.Loop,
+				Screenshot:   screenShotFileName,
 			}}).Success(w)
 
 		return
@@ -173,92 +232,72 @@ func ChromeTrace(w http.ResponseWriter, r *http.Request, screenshotsStoragePath
 		Message:    "url successfully traced",
 		StatusCode: 200,
 		Data: struct {
-			Redirects  []*tracer.JSONRedirect `json:"redirects"`
-			Screenshot string                 `json:"screenshot"`
-			ID         interface{}            `json:"id"`
+			Redirects    []*tracer.JSONRedirect  `json:"redirects"`
+			Timing       *tracer.JSONChainTiming `json:"timing,omitempty"`
+			Truncated    bool                    `json:"truncated"`
+			LoopDetected bool                    `json:"loop_detected"`
+			Loop         *tracer.Loop            `json:"loop,omitempty"`
+			Screenshot   string                  `json:"screenshot"`
+			ID           string                  `json:"id"`
 		}{
-			Redirects:  jsonRedirects,
-			Screenshot: screenShotFileName,
-			ID:         res.InsertedID,
+			Redirects:    trace.Redirects,
+			Timing:       trace.Timing,
+			Truncated:    trace.Truncated,
+			LoopDetected: trace.LoopDetected,
+			Loop:         trace.Loop,
+			Screenshot:   screenShotFileName,
+			ID:           trace.ID,
 		}}).Success(w)
 }
 
-// parseScreenSizeFromRequest - parse screen width and height from request or use default values
-func parseScreenSizeFromRequest(r *http.Request) *tracer.ScreenSize {
-	var width int
-
-	widthStr := r.URL.Query().Get("width")
-	if widthStr == "" {
-		widthStr = strconv.Itoa(defaultScreenWidth)
-	}
-
-	width, err := strconv.Atoi(widthStr)
-	if err != nil {
-		width = defaultScreenWidth
-	}
-
-	var height int
+// randomScreenshotFileName return random file name of the screenshot, crypto/rand is used so names of concurrent
+// traces don't collide
+func randomScreenshotFileName() string {
+	b := make([]byte, 16)
 
-	heightStr := r.URL.Query().Get("height")
-	if widthStr == "" {
-		heightStr = strconv.Itoa(defaultScreenHeight)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms
+		return fmt.Sprintf("%d.png", time.Now().UnixNano())
 	}
 
-	height, err = strconv.Atoi(heightStr)
-	if err != nil {
-		height = defaultScreenHeight
+	for i := range b {
+		b[i] = charset[int(b[i])%len(charset)]
 	}
 
-	return tracer.NewScreenSize(width, height)
+	return string(b) + `.png`
 }
 
-func randomScreenshotFileName() string {
-	b := make([]byte, 16)
-
-	for i := range b {
-		b[i] = charset[rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(charset))]
+// LoadTraceResults return saved trace results by id
+func LoadTraceResults(w http.ResponseWriter, r *http.Request, repo storage.TraceRepository, id string) {
+	trace, ok := loadTrace(w, r, repo, id)
+	if !ok {
+		return
 	}
 
-	return string(b) + `.png`
+	(&response.Response{
+		Status:     true,
+		Message:    "url successfully traced",
+		StatusCode: 200,
+		Data:       trace}).Success(w)
 }
 
-func LoadTraceResults(w http.ResponseWriter, r *http.Request, col *mongo.Collection, id string) {
-
-	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	ID, err := primitive.ObjectIDFromHex(id)
-	if err != nil {
-		log.Printf("invalid ObjectID. error: %s \n", err)
-		(&response.Response{
-			Status:     false,
-			Message:    fmt.Sprint("sorry, an error occurred. invalid id"),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
+// loadTrace load saved trace by id. Failed response is sent if trace cannot be loaded
+func loadTrace(w http.ResponseWriter, r *http.Request, repo storage.TraceRepository, id string) (*storage.Trace, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
 
-		return
+	trace, err := repo.Get(ctx, id)
+	if err != nil && err != storage.ErrInvalidID && err != storage.ErrNotFound {
+		log.Printf("trace loading failed. error: %s \n", err)
+		// storage error details aren't exposed
+		err = errors.New("trace cannot be loaded")
 	}
 
-	trace := new(struct {
-		Redirects  []*tracer.JSONRedirect `json:"redirects"`
-		Screenshot string                 `json:"screenshot"`
-	})
-
-	err = col.FindOne(ctx, bson.M{"_id": ID}).Decode(trace)
 	if err != nil {
-		log.Printf("mongodb reulsts decode failed. error: %s \n", err)
-		(&response.Response{
-			Status:     false,
-			Message:    fmt.Sprint("sorry, an error occurred. trace not found"),
-			StatusCode: 500,
-			Data:       nil}).Failed(w)
+		failed(w, "sorry, an error occurred. ", err)
 
-		return
+		return nil, false
 	}
 
-	log.Printf("%+v\n", trace)
-
-	(&response.Response{
-		Status:     true,
-		Message:    "url successfully traced",
-		StatusCode: 200,
-		Data:       trace}).Success(w)
+	return trace, true
 }
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+func TestLoadTraceResults(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	trace := storage.NewTrace("http://step0.test", tracerNameChrome, nil, "")
+	trace.Status = storage.StatusDone
+	trace.Redirects = []*tracer.JSONRedirect{{From: "http://step0.test", To: "http://step1.test", Status: 302}}
+	trace.Screenshot = "test.png"
+	_ = repo.Save(context.Background(), trace)
+
+	rec := httptest.NewRecorder()
+	LoadTraceResults(rec, httptest.NewRequest(http.MethodGet, "/api/find/"+trace.ID, nil), repo, trace.ID)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	loaded := new(storage.Trace)
+	decodeTestResponse(t, rec, loaded)
+
+	if loaded.ID != trace.ID || loaded.Screenshot != "test.png" || len(loaded.Redirects) != 1 {
+		t.Errorf("invalid loaded trace %+v", loaded)
+	}
+}
+
+func TestLoadTraceResults_Errors(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	for id, code := range map[string]int{
+		"invalid":                  http.StatusBadRequest,
+		"5e99fa77ec255a4dbcb9b904": http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		LoadTraceResults(rec, httptest.NewRequest(http.MethodGet, "/api/find/"+id, nil), repo, id)
+
+		if rec.Code != code {
+			t.Errorf("expect status code %d but get %d for id %s", code, rec.Code, id)
+		}
+	}
+}
+
+func TestRandomScreenshotFileName(t *testing.T) {
+	const count = 1000
+
+	names := make(chan string, count)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			names <- randomScreenshotFileName()
+		}()
+	}
+
+	wg.Wait()
+	close(names)
+
+	unique := make(map[string]bool, count)
+
+	for name := range names {
+		if len(name) != 20 || !strings.HasSuffix(name, ".png") || strings.Trim(strings.TrimSuffix(name, ".png"), charset) != "" {
+			t.Errorf("invalid screenshot file name %s", name)
+		}
+
+		unique[name] = true
+	}
+
+	if len(unique) != count {
+		t.Errorf("expect %d unique names of concurrent traces but get %d", count, len(unique))
+	}
+}
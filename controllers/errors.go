@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/lroman242/redirective/jobs"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+// Error codes of request failures which aren't trace failures
+const (
+	// errorCodeInvalidRequest is reported when request params or body are invalid
+	errorCodeInvalidRequest = "invalid_request"
+	// errorCodeNotFound is reported when requested trace doesn't exist
+	errorCodeNotFound = "not_found"
+	// errorCodeQueueFull is reported when trace job cannot be queued
+	errorCodeQueueFull = "queue_full"
+)
+
+// errorStatuses map error codes of trace failures to http statuses, other errors are internal ones
+var errorStatuses = map[string]int{
+	errorCodeInvalidRequest:            http.StatusBadRequest,
+	errorCodeNotFound:                  http.StatusNotFound,
+	errorCodeQueueFull:                 http.StatusServiceUnavailable,
+	tracer.ErrorCodeInvalidURL:         http.StatusBadRequest,
+	tracer.ErrorCodeBlocked:            http.StatusForbidden,
+	tracer.ErrorCodeDNS:                http.StatusBadGateway,
+	tracer.ErrorCodeTLS:                http.StatusBadGateway,
+	tracer.ErrorCodeConnection:         http.StatusBadGateway,
+	tracer.ErrorCodeBrowserUnavailable: http.StatusServiceUnavailable,
+	tracer.ErrorCodeTimeout:            http.StatusGatewayTimeout,
+	tracer.ErrorCodeRedirectLoop:       http.StatusLoopDetected,
+}
+
+// requestError is a request validation error
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// invalidRequest mark err as request validation error
+func invalidRequest(err error) error {
+	return &requestError{err: err}
+}
+
+// errorCode return machine readable code of the error
+func errorCode(err error) string {
+	code := tracer.ErrorCode(err)
+
+	if code != tracer.ErrorCodeInternal {
+		return code
+	}
+
+	var reqErr *requestError
+
+	switch {
+	case errors.As(err, &reqErr), errors.Is(err, storage.ErrInvalidID):
+		return errorCodeInvalidRequest
+	case errors.Is(err, storage.ErrNotFound):
+		return errorCodeNotFound
+	case errors.Is(err, jobs.ErrQueueFull):
+		return errorCodeQueueFull
+	}
+
+	return code
+}
+
+// errorStatus return http status of the error code
+func errorStatus(code string) int {
+	if status, ok := errorStatuses[code]; ok {
+		return status
+	}
+
+	return http.StatusInternalServerError
+}
+
+// failed send error response with http status and error code matching the error
+func failed(w http.ResponseWriter, message string, err error) {
+	failedWithData(w, message, err, nil)
+}
+
+// failedWithData send error response with http status and error code matching the error and response data
+func failedWithData(w http.ResponseWriter, message string, err error, data interface{}) {
+	code := errorCode(err)
+
+	(&response.Response{
+		Status:     false,
+		Message:    fmt.Sprintf("%s%s", message, err),
+		StatusCode: errorStatus(code),
+		Data:       data}).FailedWithCode(w, code)
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lroman242/redirective/jobs"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+func Test_errorCode(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   string
+		status int
+	}{
+		{errors.New("unknown"), tracer.ErrorCodeInternal, http.StatusInternalServerError},
+		{invalidRequest(errors.New("invalid param")), errorCodeInvalidRequest, http.StatusBadRequest},
+		{invalidRequest(fmt.Errorf("%w. empty", tracer.ErrInvalidURL)), tracer.ErrorCodeInvalidURL, http.StatusBadRequest},
+		{fmt.Errorf("%w. no browser", tracer.ErrBrowserUnavailable), tracer.ErrorCodeBrowserUnavailable, http.StatusServiceUnavailable},
+		{fmt.Errorf("%w. loop", tracer.ErrRedirectLoop), tracer.ErrorCodeRedirectLoop, http.StatusLoopDetected},
+		{storage.ErrNotFound, errorCodeNotFound, http.StatusNotFound},
+		{storage.ErrInvalidID, errorCodeInvalidRequest, http.StatusBadRequest},
+		{jobs.ErrQueueFull, errorCodeQueueFull, http.StatusServiceUnavailable},
+	}
+
+	for _, c := range cases {
+		code := errorCode(c.err)
+		if code != c.code {
+			t.Errorf("expect error code `%s` but get `%s`", c.code, code)
+		}
+
+		if status := errorStatus(code); status != c.status {
+			t.Errorf("expect status %d but get %d for `%s`", c.status, status, code)
+		}
+	}
+}
+
+// decodeTestErrorCode decode error code of failed response
+func decodeTestErrorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	resp := struct {
+		ErrorCode string `json:"error_code"`
+	}{}
+
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	return resp.ErrorCode
+}
+
+func TestHTTPTrace_ErrorCode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+	defer server.Close()
+
+	cases := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"", http.StatusBadRequest, tracer.ErrorCodeInvalidURL},
+		{"?url=" + url.QueryEscape(server.URL) + "&header=invalid", http.StatusBadRequest, errorCodeInvalidRequest},
+		{"?url=" + url.QueryEscape(server.URL+"/loop"), http.StatusLoopDetected, tracer.ErrorCodeRedirectLoop},
+		{"?url=" + url.QueryEscape(closedServer.URL), http.StatusBadGateway, tracer.ErrorCodeConnection},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		HTTPTrace(rec, httptest.NewRequest(http.MethodGet, "/api/trace/http"+c.query, nil), storage.NewMemoryRepository(), nil, nil)
+
+		if rec.Code != c.status {
+			t.Errorf("expect status code %d but get %d for query `%s`", c.status, rec.Code, c.query)
+		}
+
+		if code := decodeTestErrorCode(t, rec); code != c.code {
+			t.Errorf("expect error code `%s` but get `%s` for query `%s`", c.code, code, c.query)
+		}
+	}
+}
+
+func TestFailed_ErrorCode(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	// queue isn't started, so the second job overflows it
+	queue := jobs.NewQueue(repo, func(ctx context.Context, job *storage.Trace) error {
+		return nil
+	}, 1, 1)
+	defer queue.Close()
+
+	jobBody := `{"url": "http://step0.test", "tracer": "http"}`
+
+	cases := []struct {
+		name    string
+		handler func(w http.ResponseWriter)
+		status  int
+		code    string
+	}{
+		{"trace invalid id", func(w http.ResponseWriter) {
+			LoadTraceResults(w, httptest.NewRequest(http.MethodGet, "/api/find/invalid", nil), repo, "invalid")
+		}, http.StatusBadRequest, errorCodeInvalidRequest},
+		{"trace not found", func(w http.ResponseWriter) {
+			LoadTraceResults(w, httptest.NewRequest(http.MethodGet, "/api/find/5e99fa77ec255a4dbcb9b904", nil), repo, "5e99fa77ec255a4dbcb9b904")
+		}, http.StatusNotFound, errorCodeNotFound},
+		{"job not found", func(w http.ResponseWriter) {
+			LoadTraceJob(w, httptest.NewRequest(http.MethodGet, "/api/traces/5e99fa77ec255a4dbcb9b904", nil), queue, "5e99fa77ec255a4dbcb9b904")
+		}, http.StatusNotFound, errorCodeNotFound},
+		{"job invalid id", func(w http.ResponseWriter) {
+			LoadTraceJob(w, httptest.NewRequest(http.MethodGet, "/api/traces/invalid", nil), queue, "invalid")
+		}, http.StatusBadRequest, errorCodeInvalidRequest},
+		{"list invalid filter", func(w http.ResponseWriter) {
+			ListTraces(w, httptest.NewRequest(http.MethodGet, "/api/traces?limit=invalid", nil), repo)
+		}, http.StatusBadRequest, errorCodeInvalidRequest},
+		{"batch empty", func(w http.ResponseWriter) {
+			BatchTrace(w, httptest.NewRequest(http.MethodPost, "/api/batch", strings.NewReader("[]")), queue, 1, nil)
+		}, http.StatusBadRequest, errorCodeInvalidRequest},
+		{"job invalid body", func(w http.ResponseWriter) {
+			CreateTraceJob(w, httptest.NewRequest(http.MethodPost, "/api/traces", strings.NewReader("{")), queue)
+		}, http.StatusBadRequest, errorCodeInvalidRequest},
+		{"job queued", func(w http.ResponseWriter) {
+			CreateTraceJob(w, httptest.NewRequest(http.MethodPost, "/api/traces", strings.NewReader(jobBody)), queue)
+		}, http.StatusAccepted, ""},
+		{"queue full", func(w http.ResponseWriter) {
+			CreateTraceJob(w, httptest.NewRequest(http.MethodPost, "/api/traces", strings.NewReader(jobBody)), queue)
+		}, http.StatusServiceUnavailable, errorCodeQueueFull},
+	}
+
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		c.handler(rec)
+
+		if rec.Code != c.status {
+			t.Errorf("expect status code %d but get %d for %s", c.status, rec.Code, c.name)
+		}
+
+		if code := decodeTestErrorCode(t, rec); code != c.code {
+			t.Errorf("expect error code `%s` but get `%s` for %s", c.code, code, c.name)
+		}
+	}
+}
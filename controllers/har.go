@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+const contentTypeHAR = "application/json"
+
+// LoadTraceHAR export saved trace as HAR 1.2 document, so it could be opened by HAR viewers.
+// `download=true` query param makes browser save the document as `<id>.har` file
+func LoadTraceHAR(w http.ResponseWriter, r *http.Request, repo storage.TraceRepository, id string) {
+	trace, ok := loadTrace(w, r, repo, id)
+	if !ok {
+		return
+	}
+
+	if !trace.Finished() {
+		(&response.Response{
+			Status:     false,
+			Message:    fmt.Sprintf("trace is %s. HAR is available for finished traces only", trace.Status),
+			StatusCode: 409,
+			Data:       nil}).Failed(w)
+
+		return
+	}
+
+	har, err := json.Marshal(tracer.NewHAR(traceStartedAt(trace), trace.Redirects))
+	if err != nil {
+		log.Printf("HAR encoding failed. error: %s \n", err)
+		(&response.Response{
+			Status:     false,
+			Message:    "sorry, an error occurred. HAR cannot be created",
+			StatusCode: 500,
+			Data:       nil}).Failed(w)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeHAR)
+
+	if r.URL.Query().Get("download") == "true" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", trace.ID+".har"))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(har)
+}
+
+// traceStartedAt return time the trace was started (creation time for legacy traces)
+func traceStartedAt(trace *storage.Trace) time.Time {
+	if trace.StartedAt != nil {
+		return *trace.StartedAt
+	}
+
+	return trace.CreatedAt
+}
@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+func TestLoadTraceHAR(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	trace := storage.NewTrace("http://step0.test", tracerNameHTTP, nil, "")
+	trace.Start()
+	trace.Redirects = []*tracer.JSONRedirect{
+		{From: "http://step0.test", To: "http://step1.test", Status: 302},
+		{To: "http://step1.test", Status: 200},
+	}
+	trace.Finish(nil)
+	_ = repo.Save(context.Background(), trace)
+
+	rec := httptest.NewRecorder()
+	LoadTraceHAR(rec, httptest.NewRequest(http.MethodGet, "/api/traces/"+trace.ID+"/har?download=true", nil), repo, trace.ID)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), trace.ID+".har") {
+		t.Errorf("invalid content disposition `%s`", rec.Header().Get("Content-Disposition"))
+	}
+
+	har := new(tracer.HAR)
+	if err := json.Unmarshal(rec.Body.Bytes(), har); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if har.Log == nil || len(har.Log.Entries) != 2 || har.Log.Entries[0].Request.URL != "http://step0.test" {
+		t.Errorf("invalid HAR %s", rec.Body.String())
+	}
+}
+
+func TestLoadTraceHAR_Errors(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	queued := storage.NewTrace("http://step0.test", tracerNameHTTP, nil, "")
+	_ = repo.Save(context.Background(), queued)
+
+	for id, code := range map[string]int{
+		"invalid":                  http.StatusBadRequest,
+		"5e99fa77ec255a4dbcb9b904": http.StatusNotFound,
+		queued.ID:                  http.StatusConflict,
+	} {
+		rec := httptest.NewRecorder()
+		LoadTraceHAR(rec, httptest.NewRequest(http.MethodGet, "/api/traces/"+id+"/har", nil), repo, id)
+
+		if rec.Code != code {
+			t.Errorf("expect status code %d but get %d for id %s", code, rec.Code, id)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+	dateLayout       = "2006-01-02"
+)
+
+// ListTraces return saved traces filtered by query params:
+// url, final_url, domain, status_code, status, from, to (RFC3339 or YYYY-MM-DD), q (free text),
+// sort (newest|oldest), cursor (next_cursor of the previous page) and limit
+func ListTraces(w http.ResponseWriter, r *http.Request, repo storage.TraceRepository) {
+	filter, err := parseListFilter(r.URL.Query())
+	if err != nil {
+		failed(w, "", invalidRequest(err))
+
+		return
+	}
+
+	limit := filter.Limit
+	// load one more trace to know if there is a next page
+	filter.Limit++
+
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	traces, err := repo.List(ctx, filter)
+	if err == storage.ErrInvalidID {
+		failed(w, "", invalidRequest(errors.New("invalid cursor")))
+
+		return
+	}
+
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	nextCursor := ""
+
+	if len(traces) > limit {
+		traces = traces[:limit]
+		nextCursor = traces[limit-1].ID
+	}
+
+	// only names of headers and cookies are returned, traces saved before they were redacted keep values
+	for _, trace := range traces {
+		trace.Options, _ = redactOptions(trace.Options)
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    fmt.Sprintf("%d traces found", len(traces)),
+		StatusCode: 200,
+		Data: struct {
+			Traces     []*storage.Trace `json:"traces"`
+			NextCursor string           `json:"next_cursor,omitempty"`
+		}{
+			Traces:     traces,
+			NextCursor: nextCursor,
+		}}).Success(w)
+}
+
+// parseListFilter parse and validate history query params
+func parseListFilter(query url.Values) (*storage.ListFilter, error) {
+	filter := &storage.ListFilter{
+		URL:      query.Get("url"),
+		FinalURL: query.Get("final_url"),
+		Domain:   query.Get("domain"),
+		Query:    query.Get("q"),
+		Cursor:   query.Get("cursor"),
+		Sort:     query.Get("sort"),
+		Limit:    defaultListLimit,
+	}
+
+	if status := query.Get("status"); status != "" {
+		filter.Status = []string{status}
+	}
+
+	if filter.Sort != "" && filter.Sort != storage.SortNewest && filter.Sort != storage.SortOldest {
+		return nil, fmt.Errorf("invalid sort. expect %s or %s", storage.SortNewest, storage.SortOldest)
+	}
+
+	if value := query.Get("status_code"); value != "" {
+		code, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status_code. %s", err)
+		}
+
+		filter.StatusCode = code
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return nil, fmt.Errorf("invalid limit. expect number from 1 to %d", maxListLimit)
+		}
+
+		filter.Limit = limit
+	}
+
+	var err error
+
+	if filter.CreatedFrom, err = parseDateParam(query.Get("from"), false); err != nil {
+		return nil, fmt.Errorf("invalid from. %s", err)
+	}
+
+	if filter.CreatedTo, err = parseDateParam(query.Get("to"), true); err != nil {
+		return nil, fmt.Errorf("invalid to. %s", err)
+	}
+
+	return filter, nil
+}
+
+// parseDateParam parse RFC3339 time or date. Date means start of the day
+// or the end of the day if endOfDay is true
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+
+	t, err := time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expect RFC3339 time or %s date", dateLayout)
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+func TestListTraces(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	for _, u := range []string{"http://step0.test", "http://step1.test", "http://step2.test"} {
+		trace := storage.NewTrace(u, tracerNameHTTP, nil, "")
+		trace.Status = storage.StatusDone
+		trace.Redirects = []*tracer.JSONRedirect{{From: u, To: "http://final.test", Status: 302}}
+		_ = repo.Save(context.Background(), trace)
+	}
+
+	data := struct {
+		Traces     []*storage.Trace `json:"traces"`
+		NextCursor string           `json:"next_cursor"`
+	}{}
+
+	rec := httptest.NewRecorder()
+	ListTraces(rec, httptest.NewRequest(http.MethodGet, "/api/traces?limit=2&domain=final.test", nil), repo)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	decodeTestResponse(t, rec, &data)
+
+	if len(data.Traces) != 2 || data.Traces[0].URL != "http://step2.test" || data.NextCursor == "" {
+		t.Fatalf("invalid first page %+v", data)
+	}
+
+	rec = httptest.NewRecorder()
+	ListTraces(rec, httptest.NewRequest(http.MethodGet, "/api/traces?limit=2&cursor="+data.NextCursor, nil), repo)
+
+	data.NextCursor = ""
+	decodeTestResponse(t, rec, &data)
+
+	if len(data.Traces) != 1 || data.Traces[0].URL != "http://step0.test" || data.NextCursor != "" {
+		t.Errorf("invalid last page %+v", data)
+	}
+}
+
+func TestListTraces_RedactOptions(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+
+	// trace saved with header and cookie values
+	options := map[string]string{
+		"header":  "Authorization: Bearer secret",
+		"cookie":  "session=secret; lang=en",
+		"referer": "http://referer.test/",
+	}
+	_ = repo.Save(context.Background(), storage.NewTrace("http://step0.test", tracerNameHTTP, options, ""))
+
+	data := struct {
+		Traces []*storage.Trace `json:"traces"`
+	}{}
+
+	rec := httptest.NewRecorder()
+	ListTraces(rec, httptest.NewRequest(http.MethodGet, "/api/traces", nil), repo)
+
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("expect header and cookie values not to be returned but get %s", rec.Body.String())
+	}
+
+	decodeTestResponse(t, rec, &data)
+
+	saved := data.Traces[0].Options
+	if saved["header"] != "Authorization" || saved["cookie"] != "session\nlang" || saved["referer"] != "http://referer.test/" {
+		t.Errorf("expect only header and cookie names but get %v", saved)
+	}
+}
+
+func TestListTraces_InvalidParams(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=1000", "status_code=abc", "from=yesterday", "sort=random", "cursor=invalid"} {
+		rec := httptest.NewRecorder()
+		ListTraces(rec, httptest.NewRequest(http.MethodGet, "/api/traces?"+query, nil), storage.NewMemoryRepository())
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expect status code %d but get %d for query `%s`", http.StatusBadRequest, rec.Code, query)
+		}
+	}
+}
+
+func TestParseDateParam(t *testing.T) {
+	from, _ := parseDateParam("2020-04-17", false)
+	to, _ := parseDateParam("2020-04-17", true)
+
+	if !from.Equal(time.Date(2020, 4, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid start of the day %s", from)
+	}
+
+	if to.Day() != 17 || to.Hour() != 23 {
+		t.Errorf("invalid end of the day %s", to)
+	}
+}
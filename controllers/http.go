@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lroman242/redirective/proxy"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+const httpTracerTimeout = 30 * time.Second
+
+// storageTimeout limits trace results saving and loading
+const storageTimeout = 5 * time.Second
+
+// HTTPTrace parse a trace path for provided url using plain http client (server side redirects only)
+func HTTPTrace(w http.ResponseWriter, r *http.Request, repo storage.TraceRepository, proxies *proxy.Pools, policy *tracer.URLPolicy) {
+	// check url
+	urlToTrace := r.URL.Query().Get("url")
+	if urlToTrace == "" {
+		(&response.Response{
+			Status:     false,
+			Message:    "url parameter is required",
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
+
+		return
+	}
+	// convert raw url string to url.URL
+	targetURL, err := url.ParseRequestURI(urlToTrace)
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    fmt.Sprintf("invalid url %s", err),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, tracer.ErrorCodeInvalidURL)
+
+		return
+	}
+
+	// parse custom request settings (user agent, referer, headers, cookies)
+	request, err := parseRequestOptions(r.URL.Query())
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    err.Error(),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+	// parse proxy url or pick the next proxy of the pool
+	proxyURL, proxyPool, err := parseProxy(r.URL.Query())
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    err.Error(),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+
+	// parse hops limit, tracer.DefaultMaxHops is used if it isn't set
+	maxHops, err := parseMaxHops(r.URL.Query().Get("max_hops"))
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    fmt.Sprintf("invalid max_hops. %s", err),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+
+	options := &traceOptions{request: request, proxy: proxyURL, proxyPool: proxyPool, maxHops: maxHops}
+
+	err = options.resolveProxy(proxies)
+	if err != nil {
+		(&response.Response{
+			Status:     false,
+			Message:    err.Error(),
+			StatusCode: 400,
+			Data:       nil}).FailedWithCode(w, errorCodeInvalidRequest)
+
+		return
+	}
+	// user proxy could point to internal network, so it is checked with url policy
+	err = options.checkProxy(policy)
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+	// create new tracer instance
+	ht := options.newHTTPTracer(policy)
+
+	trace := storage.NewTrace(urlToTrace, tracerNameHTTP, queryOptions(r.URL.Query()), "")
+	trace.Requester = requester(r)
+	trace.Proxy = tracer.RedactProxy(options.proxy)
+	trace.Start()
+
+	// process tracing
+	redirects, err := ht.Trace(targetURL, "")
+	if errors.Is(err, tracer.ErrRedirectLoop) {
+		trace.SetRedirects(redirects)
+		trace.UserAgent = userAgent(trace.Redirects)
+		failedLoop(w, repo, trace, err)
+
+		return
+	}
+
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	trace.SetRedirects(redirects)
+	trace.UserAgent = userAgent(trace.Redirects)
+	trace.Finish(nil)
+
+	err = saveTrace(repo, trace)
+	if err != nil {
+		log.Printf("error occurred during saving trace results. error: %s \n", err)
+
+		(&response.Response{
+			Status:     true,
+			Message:    "url successfully traced",
+			StatusCode: 200,
+			Data: struct {
+				Redirects    []*tracer.JSONRedirect  `json:"redirects"`
+				Timing       *tracer.JSONChainTiming `json:"timing,omitempty"`
+				Truncated    bool                    `json:"truncated"`
+				LoopDetected bool                    `json:"loop_detected"`
+				Loop         *tracer.Loop            `json:"loop,omitempty"`
+			}{
+				Redirects:    trace.Redirects,
+				Timing:       trace.Timing,
+				Truncated:    trace.Truncated,
+				LoopDetected: trace.LoopDetected,
+				Loop:         trace.Loop,
+			}}).Success(w)
+
+		return
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    "url successfully traced",
+		StatusCode: 200,
+		Data: struct {
+			Redirects    []*tracer.JSONRedirect  `json:"redirects"`
+			Timing       *tracer.JSONChainTiming `json:"timing,omitempty"`
+			Truncated    bool                    `json:"truncated"`
+			LoopDetected bool                    `json:"loop_detected"`
+			Loop         *tracer.Loop            `json:"loop,omitempty"`
+			ID           string                  `json:"id"`
+		}{
+			Redirects:    trace.Redirects,
+			Timing:       trace.Timing,
+			Truncated:    trace.Truncated,
+			LoopDetected: trace.LoopDetected,
+			Loop:         trace.Loop,
+			ID:           trace.ID,
+		}}).Success(w)
+}
+
+// failedLoop save looped chain as failed trace and send it with failed response
+func failedLoop(w http.ResponseWriter, repo storage.TraceRepository, trace *storage.Trace, err error) {
+	trace.Finish(err)
+
+	if saveErr := saveTrace(repo, trace); saveErr != nil {
+		log.Printf("error occurred during saving trace results. error: %s \n", saveErr)
+	}
+
+	failedWithData(w, "sorry, an error occurred. ", err, trace)
+}
+
+// saveTrace save trace results to the repository
+func saveTrace(repo storage.TraceRepository, trace *storage.Trace) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	return repo.Save(ctx, trace)
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+// decodeTestResponse decode common response with provided data type
+func decodeTestResponse(t *testing.T, rec *httptest.ResponseRecorder, data interface{}) {
+	t.Helper()
+
+	resp := struct {
+		Status bool        `json:"status"`
+		Data   interface{} `json:"data"`
+	}{Data: data}
+
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+}
+
+func TestHTTPTrace(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/step0", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("final"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repo := storage.NewMemoryRepository()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/trace/http?url="+url.QueryEscape(server.URL+"/step0"), nil)
+
+	HTTPTrace(rec, req, repo, nil, nil)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	data := struct {
+		Redirects []*tracer.JSONRedirect `json:"redirects"`
+		ID        string                 `json:"id"`
+	}{}
+	decodeTestResponse(t, rec, &data)
+
+	if len(data.Redirects) != 2 {
+		t.Fatalf("expect 2 redirects but get %d", len(data.Redirects))
+	}
+
+	trace, err := repo.Get(context.Background(), data.ID)
+	if err != nil {
+		t.Fatalf("expect trace to be saved. error: %s", err)
+	}
+
+	if trace.Status != storage.StatusDone || trace.Tracer != tracerNameHTTP || len(trace.Redirects) != 2 {
+		t.Errorf("invalid saved trace %+v", trace)
+	}
+
+	if trace.StartedAt == nil || trace.FinishedAt == nil || trace.Requester != "192.0.2.1" || trace.UserAgent == "" {
+		t.Errorf("expect trace metadata to be saved %+v", trace)
+	}
+
+	if data.Redirects[0].Timing == nil {
+		t.Error("expect hop timing to be returned")
+	}
+
+	if trace.Timing == nil || trace.Timing.Network <= 0 || trace.Timing.Total < trace.Timing.Network {
+		t.Errorf("expect chain timing summary to be saved %+v", trace.Timing)
+	}
+}
+
+func TestHTTPTrace_Loop(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/pong", http.StatusFound)
+	})
+	mux.HandleFunc("/pong", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ping", http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	repo := storage.NewMemoryRepository()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/trace/http?url="+url.QueryEscape(server.URL+"/ping"), nil)
+
+	HTTPTrace(rec, req, repo, nil, nil)
+
+	if rec.Code != http.StatusLoopDetected {
+		t.Fatalf("expect status code %d but get %d", http.StatusLoopDetected, rec.Code)
+	}
+
+	data := struct {
+		Redirects    []*tracer.JSONRedirect `json:"redirects"`
+		Truncated    bool                   `json:"truncated"`
+		LoopDetected bool                   `json:"loop_detected"`
+		Loop         *tracer.Loop           `json:"loop"`
+		ErrorCode    string                 `json:"error_code"`
+		ID           string                 `json:"id"`
+	}{}
+	decodeTestResponse(t, rec, &data)
+
+	if !data.Truncated || !data.LoopDetected || data.Loop == nil || len(data.Loop.URLs) != 2 || data.ErrorCode != tracer.ErrorCodeRedirectLoop {
+		t.Fatalf("expect looped chain to be reported %+v", data)
+	}
+
+	if len(data.Redirects) != 3 || !data.Redirects[2].Truncated {
+		t.Errorf("expect 3 redirects with the last truncated one but get %d", len(data.Redirects))
+	}
+
+	trace, err := repo.Get(context.Background(), data.ID)
+	if err != nil {
+		t.Fatalf("expect trace to be saved. error: %s", err)
+	}
+
+	if !trace.LoopDetected || !trace.Truncated || trace.Loop == nil || trace.Status != storage.StatusFailed {
+		t.Errorf("expect loop to be saved %+v", trace)
+	}
+}
+
+func TestHTTPTrace_MaxHops(t *testing.T) {
+	var requests int32
+
+	// endless chain of distinct urls
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hop := atomic.AddInt32(&requests, 1)
+		http.Redirect(w, r, "/hop"+strconv.Itoa(int(hop)), http.StatusFound)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		query   string
+		status  int
+		maxHops int
+	}{
+		{"", http.StatusOK, tracer.DefaultMaxHops},
+		{"&max_hops=5", http.StatusOK, 5},
+		{"&max_hops=0", http.StatusBadRequest, 0},
+		{"&max_hops=101", http.StatusBadRequest, 0},
+	}
+
+	for _, c := range cases {
+		atomic.StoreInt32(&requests, 0)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/trace/http?url="+url.QueryEscape(server.URL+"/hop0")+c.query, nil)
+
+		HTTPTrace(rec, req, storage.NewMemoryRepository(), nil, nil)
+
+		if rec.Code != c.status {
+			t.Fatalf("expect status code %d but get %d for query `%s`", c.status, rec.Code, c.query)
+		}
+
+		if c.status != http.StatusOK {
+			continue
+		}
+
+		data := struct {
+			Redirects []*tracer.JSONRedirect `json:"redirects"`
+			Truncated bool                   `json:"truncated"`
+		}{}
+		decodeTestResponse(t, rec, &data)
+
+		if !data.Truncated || len(data.Redirects) != c.maxHops+1 {
+			t.Errorf("expect chain truncated after %d hops but get %d redirects for query `%s`", c.maxHops, len(data.Redirects), c.query)
+		}
+
+		if hops := atomic.LoadInt32(&requests); int(hops) != c.maxHops+1 {
+			t.Errorf("expect %d requests but get %d for query `%s`", c.maxHops+1, hops, c.query)
+		}
+	}
+}
+
+func TestHTTPTrace_InvalidURL(t *testing.T) {
+	for _, query := range []string{"", "?url=example"} {
+		rec := httptest.NewRecorder()
+		HTTPTrace(rec, httptest.NewRequest(http.MethodGet, "/api/trace/http"+query, nil), storage.NewMemoryRepository(), nil, nil)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expect status code %d but get %d for query `%s`", http.StatusBadRequest, rec.Code, query)
+		}
+	}
+}
+
+func TestHTTPTrace_URLPolicy(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	policy, err := tracer.NewURLPolicy(nil, nil, false)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	for _, query := range []string{
+		"?url=" + url.QueryEscape(server.URL),
+		"?url=http://93.184.216.34&proxy=" + url.QueryEscape("http://127.0.0.1:3128"),
+	} {
+		rec := httptest.NewRecorder()
+		HTTPTrace(rec, httptest.NewRequest(http.MethodGet, "/api/trace/http"+query, nil), storage.NewMemoryRepository(), nil, policy)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("expect status code %d but get %d for query `%s`", http.StatusForbidden, rec.Code, query)
+		}
+
+		if code := decodeTestErrorCode(t, rec); code != tracer.ErrorCodeBlocked {
+			t.Errorf("expect error code `%s` but get `%s` for query `%s`", tracer.ErrorCodeBlocked, code, query)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/lroman242/redirective/browser"
+	"github.com/lroman242/redirective/jobs"
+	"github.com/lroman242/redirective/proxy"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+// Tracer names accepted by trace jobs
+const (
+	tracerNameChrome = "chrome"
+	tracerNameHTTP   = "http"
+)
+
+// traceJobRequest describe `POST /api/traces` request body
+type traceJobRequest struct {
+	URL         string            `json:"url"`
+	Tracer      string            `json:"tracer"`
+	CallbackURL string            `json:"callback_url"`
+	Options     map[string]string `json:"options"`
+}
+
+// CreateTraceJob enqueue asynchronous trace job and return its id immediately
+func CreateTraceJob(w http.ResponseWriter, r *http.Request, queue *jobs.Queue) {
+	jobRequest := new(traceJobRequest)
+
+	err := json.NewDecoder(r.Body).Decode(jobRequest)
+	if err != nil {
+		failed(w, "invalid request body. ", invalidRequest(err))
+
+		return
+	}
+
+	job, err := newTraceJob(jobRequest, requester(r))
+	if err != nil {
+		failed(w, "", err)
+
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	err = queue.Enqueue(ctx, job)
+	if err == jobs.ErrQueueFull {
+		failedWithData(w, "sorry, too many queued traces. try again later. ", err, struct {
+			ID string `json:"id"`
+		}{
+			ID: job.ID,
+		})
+
+		return
+	}
+
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    "trace queued",
+		StatusCode: 202,
+		Data: struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		}{
+			ID:     job.ID,
+			Status: storage.StatusQueued,
+		}}).Success(w)
+}
+
+// LoadTraceJob return trace job status and results
+func LoadTraceJob(w http.ResponseWriter, r *http.Request, queue *jobs.Queue, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	job, err := queue.Get(ctx, id)
+	if err == storage.ErrNotFound {
+		failed(w, "", err)
+
+		return
+	}
+
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    "trace " + job.Status,
+		StatusCode: 200,
+		Data:       job}).Success(w)
+}
+
+// TraceJobHandler process trace jobs with the tracer selected by the job
+func TraceJobHandler(screenshotsStoragePath string, pool *browser.Pool, proxies *proxy.Pools, policy *tracer.URLPolicy) jobs.Handler {
+	return func(ctx context.Context, job *storage.Trace) error {
+		targetURL, err := url.ParseRequestURI(job.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url %s", err)
+		}
+
+		query, err := jobQuery(job)
+		if err != nil {
+			return err
+		}
+
+		options, err := parseTraceOptions(query)
+		if err != nil {
+			return err
+		}
+
+		err = options.resolveProxy(proxies)
+		if err != nil {
+			return err
+		}
+
+		err = options.checkProxy(policy)
+		if err != nil {
+			return err
+		}
+
+		job.Proxy = tracer.RedactProxy(options.proxy)
+		job.Geo = options.geo
+
+		if job.Tracer == tracerNameHTTP {
+			redirects, err := options.newHTTPTracer(policy).Trace(targetURL, "")
+			job.SetRedirects(redirects)
+			job.UserAgent = userAgent(job.Redirects)
+
+			return err
+		}
+
+		remote, release, err := connectToBrowser(ctx, pool)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		screenShotFileName := randomScreenshotFileName()
+
+		chr, err := options.newChromeTracer(remote, screenshotsStoragePath, policy)
+		if err != nil {
+			return err
+		}
+
+		redirects, err := chr.Trace(targetURL, screenShotFileName)
+		job.SetRedirects(redirects)
+		job.Screenshot = screenShotFileName
+		job.ScreenSize = options.size
+		job.UserAgent = userAgent(job.Redirects)
+
+		return err
+	}
+}
+
+// newTraceJob validate job request and create new job
+func newTraceJob(jobRequest *traceJobRequest, requester string) (*storage.Trace, error) {
+	if jobRequest.URL == "" {
+		return nil, invalidRequest(fmt.Errorf("%w. url parameter is required", tracer.ErrInvalidURL))
+	}
+
+	if _, err := url.ParseRequestURI(jobRequest.URL); err != nil {
+		return nil, invalidRequest(fmt.Errorf("%w %s", tracer.ErrInvalidURL, err))
+	}
+
+	if jobRequest.Tracer == "" {
+		jobRequest.Tracer = tracerNameChrome
+	}
+
+	if jobRequest.Tracer != tracerNameChrome && jobRequest.Tracer != tracerNameHTTP {
+		return nil, invalidRequest(fmt.Errorf("unknown tracer `%s`", jobRequest.Tracer))
+	}
+
+	if jobRequest.CallbackURL != "" {
+		callbackURL, err := url.ParseRequestURI(jobRequest.CallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") {
+			return nil, invalidRequest(errors.New("invalid callback_url"))
+		}
+	}
+
+	if _, err := parseTraceOptions(optionsToQuery(jobRequest.Options)); err != nil {
+		return nil, invalidRequest(err)
+	}
+
+	options, rawOptions := redactOptions(jobRequest.Options)
+
+	job := storage.NewTrace(jobRequest.URL, jobRequest.Tracer, options, jobRequest.CallbackURL)
+	job.Requester = requester
+	job.RawOptions = rawOptions
+	job.RedactedOptions = len(rawOptions) > 0
+
+	return job, nil
+}
+
+// redactOptions return copy of job options without secrets (see redactOption) and original values of redacted options
+func redactOptions(options map[string]string) (map[string]string, map[string]string) {
+	if len(options) == 0 {
+		return options, nil
+	}
+
+	redacted := make(map[string]string, len(options))
+
+	var rawOptions map[string]string
+
+	for key, value := range options {
+		redactedValue := redactOption(key, value)
+		if redactedValue == value {
+			redacted[key] = value
+
+			continue
+		}
+
+		if rawOptions == nil {
+			rawOptions = make(map[string]string)
+		}
+
+		rawOptions[key] = value
+
+		if redactedValue != "" {
+			redacted[key] = redactedValue
+		}
+	}
+
+	return redacted, rawOptions
+}
+
+// jobQuery return job options as query params, redacted options are restored if the job wasn't reloaded from storage.
+// Job resumed from storage fails if its redacted options are lost
+func jobQuery(job *storage.Trace) (url.Values, error) {
+	if job.RedactedOptions && len(job.RawOptions) == 0 {
+		return nil, fmt.Errorf("%w. proxy password, header and cookie values aren't saved, the job cannot be resumed",
+			tracer.ErrCredentialsMissing)
+	}
+
+	query := optionsToQuery(job.Options)
+
+	for key, value := range job.RawOptions {
+		query.Set(key, value)
+	}
+
+	return query, nil
+}
+
+// optionsToQuery convert job options to query params accepted by parseTraceOptions
+func optionsToQuery(options map[string]string) url.Values {
+	query := url.Values{}
+
+	for key, value := range options {
+		query.Set(key, value)
+	}
+
+	return query
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lroman242/redirective/auth"
+	"github.com/lroman242/redirective/response"
+	"github.com/lroman242/redirective/storage"
+)
+
+// keyRequest describe `POST /api/keys` request body. Omitted limits are set to defaults, 0 - unlimited
+type keyRequest struct {
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	RateLimit  *int     `json:"rate_limit"`
+	DailyQuota *int     `json:"daily_quota"`
+}
+
+// keyData describe api key in responses. Secret is returned only once, when the key is issued
+type keyData struct {
+	ID         string     `json:"id"`
+	Key        string     `json:"key,omitempty"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	RateLimit  int        `json:"rate_limit"`
+	DailyQuota int        `json:"daily_quota"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateKey issue new api key
+func CreateKey(w http.ResponseWriter, r *http.Request, keys storage.KeyRepository, limits auth.Limits) {
+	keyReq := new(keyRequest)
+
+	err := json.NewDecoder(r.Body).Decode(keyReq)
+	if err != nil {
+		failed(w, "invalid request body. ", invalidRequest(err))
+
+		return
+	}
+
+	key, err := newKey(keyReq, limits)
+	if err != nil {
+		failed(w, "", invalidRequest(err))
+
+		return
+	}
+
+	secret, err := auth.GenerateKey()
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	key.Hash = auth.HashKey(secret)
+	key.Prefix = auth.KeyPrefix(secret)
+
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	if err := keys.SaveKey(ctx, key); err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	data := newKeyData(key)
+	data.Key = secret
+
+	(&response.Response{
+		Status:     true,
+		Message:    "api key issued",
+		StatusCode: 201,
+		Data:       data}).Success(w)
+}
+
+// ListKeys return all issued api keys (without secrets)
+func ListKeys(w http.ResponseWriter, r *http.Request, keys storage.KeyRepository) {
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	list, err := keys.ListKeys(ctx)
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	data := make([]*keyData, 0, len(list))
+	for _, key := range list {
+		data = append(data, newKeyData(key))
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    "success",
+		StatusCode: 200,
+		Data:       data}).Success(w)
+}
+
+// RevokeKey revoke api key, revoked key is kept in storage
+func RevokeKey(w http.ResponseWriter, r *http.Request, keys storage.KeyRepository, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), storageTimeout)
+	defer cancel()
+
+	key, err := keys.GetKey(ctx, id)
+	if err == storage.ErrKeyNotFound {
+		(&response.Response{
+			Status:     false,
+			Message:    "api key not found",
+			StatusCode: 404,
+			Data:       nil}).Failed(w)
+
+		return
+	}
+
+	if err == storage.ErrInvalidID {
+		failed(w, "", invalidRequest(errors.New("invalid id")))
+
+		return
+	}
+
+	if err != nil {
+		failed(w, "sorry, an error occurred. ", err)
+
+		return
+	}
+
+	if !key.Revoked() {
+		revokedAt := time.Now().UTC()
+		key.RevokedAt = &revokedAt
+
+		if err := keys.SaveKey(ctx, key); err != nil {
+			failed(w, "sorry, an error occurred. ", err)
+
+			return
+		}
+	}
+
+	(&response.Response{
+		Status:     true,
+		Message:    "api key revoked",
+		StatusCode: 200,
+		Data:       newKeyData(key)}).Success(w)
+}
+
+// newKey create api key from request, limits are set to defaults if omitted
+func newKey(keyReq *keyRequest, limits auth.Limits) (*storage.APIKey, error) {
+	name := strings.TrimSpace(keyReq.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	if len(keyReq.Scopes) == 0 {
+		return nil, fmt.Errorf("scopes are required, supported ones: %s", strings.Join(auth.Scopes, ", "))
+	}
+
+	for _, scope := range keyReq.Scopes {
+		if !auth.ValidScope(scope) {
+			return nil, fmt.Errorf("unknown scope `%s`, supported ones: %s", scope, strings.Join(auth.Scopes, ", "))
+		}
+	}
+
+	key := &storage.APIKey{
+		Name:       name,
+		Scopes:     keyReq.Scopes,
+		RateLimit:  limits.RateLimit,
+		DailyQuota: limits.DailyQuota,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	if keyReq.RateLimit != nil {
+		key.RateLimit = *keyReq.RateLimit
+	}
+
+	if keyReq.DailyQuota != nil {
+		key.DailyQuota = *keyReq.DailyQuota
+	}
+
+	if key.RateLimit < 0 || key.DailyQuota < 0 {
+		return nil, errors.New("rate_limit and daily_quota should not be negative")
+	}
+
+	return key, nil
+}
+
+func newKeyData(key *storage.APIKey) *keyData {
+	return &keyData{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		RateLimit:  key.RateLimit,
+		DailyQuota: key.DailyQuota,
+		CreatedAt:  key.CreatedAt,
+		RevokedAt:  key.RevokedAt,
+	}
+}
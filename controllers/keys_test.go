@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lroman242/redirective/auth"
+	"github.com/lroman242/redirective/storage"
+)
+
+func TestCreateKey(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	limits := auth.Limits{RateLimit: 60, DailyQuota: 1000}
+
+	rec := httptest.NewRecorder()
+	body := `{"name": "partner", "scopes": ["trace", "read"], "daily_quota": 0}`
+	CreateKey(rec, httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(body)), repo, limits)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expect status code %d but get %d", http.StatusCreated, rec.Code)
+	}
+
+	data := new(keyData)
+	decodeTestResponse(t, rec, data)
+
+	if data.Key == "" || data.Prefix != auth.KeyPrefix(data.Key) || data.RateLimit != 60 || data.DailyQuota != 0 {
+		t.Errorf("invalid issued key %+v", data)
+	}
+
+	key, err := repo.FindKey(context.Background(), auth.HashKey(data.Key))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if key.ID != data.ID || key.Name != "partner" || !key.HasScope(auth.ScopeRead) || key.HasScope(auth.ScopeScreenshot) {
+		t.Errorf("invalid saved key %+v", key)
+	}
+}
+
+func TestCreateKey_Invalid(t *testing.T) {
+	for _, body := range []string{
+		`{"scopes": ["trace"]}`,
+		`{"name": "partner"}`,
+		`{"name": "partner", "scopes": ["admin"]}`,
+		`{"name": "partner", "scopes": ["trace"], "rate_limit": -1}`,
+		`name=partner`,
+	} {
+		rec := httptest.NewRecorder()
+		CreateKey(rec, httptest.NewRequest(http.MethodPost, "/api/keys", strings.NewReader(body)), storage.NewMemoryRepository(), auth.Limits{})
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expect status code %d but get %d for body `%s`", http.StatusBadRequest, rec.Code, body)
+		}
+	}
+}
+
+func TestListKeys_RevokeKey(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	key := &storage.APIKey{Name: "partner", Hash: "hash", Scopes: []string{auth.ScopeTrace}}
+	_ = repo.SaveKey(context.Background(), key)
+
+	rec := httptest.NewRecorder()
+	RevokeKey(rec, httptest.NewRequest(http.MethodDelete, "/api/keys/"+key.ID, nil), repo, key.ID)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expect status code %d but get %d", http.StatusOK, rec.Code)
+	}
+
+	var keys []*keyData
+
+	rec = httptest.NewRecorder()
+	ListKeys(rec, httptest.NewRequest(http.MethodGet, "/api/keys", nil), repo)
+	decodeTestResponse(t, rec, &keys)
+
+	if len(keys) != 1 || keys[0].ID != key.ID || keys[0].RevokedAt == nil || keys[0].Key != "" {
+		t.Errorf("expect revoked key to be listed without secret but get %+v", keys)
+	}
+
+	rec = httptest.NewRecorder()
+	RevokeKey(rec, httptest.NewRequest(http.MethodDelete, "/api/keys/unknown", nil), repo, "507f1f77bcf86cd799439011")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expect status code %d but get %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,449 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lroman242/redirective/proxy"
+	"github.com/lroman242/redirective/tracer"
+	"github.com/raff/godet"
+)
+
+const defaultScreenWidth = 1920
+const defaultScreenHeight = 1080
+
+// maxWaitTimeout limits how long a single request may wait for the page to settle
+const maxWaitTimeout = 60 * time.Second
+
+// maxHopsLimit limits the number of hops a single request may follow
+const maxHopsLimit = 100
+
+// traceOptions contains tracer settings parsed from request params.
+// The same params are accepted by query string of sync endpoints and `options` of trace jobs
+type traceOptions struct {
+	size         *tracer.ScreenSize
+	waitStrategy *tracer.WaitStrategy
+	networkLog   bool
+	frameTree    bool
+	hopScreens   bool
+	device       *tracer.Device
+	request      *tracer.RequestOptions
+	proxy        *url.URL
+	proxyPool    string
+	geo          *tracer.Geo
+	maxHops      int
+}
+
+// parseTraceOptions parse and validate tracer settings
+func parseTraceOptions(query url.Values) (*traceOptions, error) {
+	waitStrategy, err := parseWaitStrategy(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wait strategy. %s", err)
+	}
+
+	networkLog, err := parseBoolParam(query.Get("network_log"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid network_log. %s", err)
+	}
+
+	frameTree, err := parseBoolParam(query.Get("frames"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid frames. %s", err)
+	}
+
+	hopScreens, err := parseBoolParam(query.Get("hop_screenshots"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hop_screenshots. %s", err)
+	}
+
+	maxHops, err := parseMaxHops(query.Get("max_hops"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_hops. %s", err)
+	}
+
+	size := parseScreenSize(query, tracer.NewScreenSize(defaultScreenWidth, defaultScreenHeight))
+
+	var device *tracer.Device
+
+	if name := query.Get("device"); name != "" {
+		device, err = tracer.DeviceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device. %s", err)
+		}
+
+		// width and height params override device viewport
+		size = parseScreenSize(query, device.ScreenSize())
+		device.Width, device.Height = size.Width, size.Height
+	}
+
+	request, err := parseRequestOptions(query)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyURL, proxyPool, err := parseProxy(query)
+	if err != nil {
+		return nil, err
+	}
+
+	geo, err := parseGeo(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &traceOptions{
+		size:         size,
+		device:       device,
+		request:      request,
+		proxy:        proxyURL,
+		proxyPool:    proxyPool,
+		geo:          geo,
+		waitStrategy: waitStrategy,
+		networkLog:   networkLog,
+		frameTree:    frameTree,
+		hopScreens:   hopScreens,
+		maxHops:      maxHops,
+	}, nil
+}
+
+// resolveProxy pick the next proxy of requested pool. Pool geo settings are used
+// if they aren't provided explicitly
+func (o *traceOptions) resolveProxy(proxies *proxy.Pools) error {
+	if o.proxyPool == "" {
+		return nil
+	}
+
+	proxyURL, geo, err := proxies.Next(o.proxyPool)
+	if err != nil {
+		return err
+	}
+
+	o.proxy = proxyURL
+	o.geo = o.geo.Merge(geo)
+
+	return nil
+}
+
+// checkProxy check proxy provided by request params with url policy, pool proxies are trusted
+func (o *traceOptions) checkProxy(policy *tracer.URLPolicy) error {
+	if o.proxy == nil || o.proxyPool != "" {
+		return nil
+	}
+
+	if err := policy.CheckHost(o.proxy.Hostname()); err != nil {
+		return fmt.Errorf("proxy is not allowed. %w", err)
+	}
+
+	return nil
+}
+
+// newChromeTracer create chrome tracer configured with options
+func (o *traceOptions) newChromeTracer(remote *godet.RemoteDebugger, screenshotsStoragePath string, policy *tracer.URLPolicy) (*tracer.ChromeTracer, error) {
+	chr := tracer.NewChromeTracer(remote, o.size, screenshotsStoragePath)
+	chr.SetWaitStrategy(o.waitStrategy)
+	chr.SetURLPolicy(policy)
+
+	if o.device != nil {
+		chr.SetDevice(o.device)
+	}
+
+	chr.SetRequestOptions(o.request)
+	chr.SetGeo(o.geo)
+	chr.SetNetworkLog(o.networkLog)
+	chr.SetFrameTree(o.frameTree)
+	chr.SetHopScreenshots(o.hopScreens)
+	chr.SetMaxHops(o.maxHops)
+
+	if err := chr.SetProxy(o.proxy); err != nil {
+		return nil, err
+	}
+
+	return chr, nil
+}
+
+// newHTTPTracer create http tracer configured with options (only request, proxy and hops limit options are supported)
+func (o *traceOptions) newHTTPTracer(policy *tracer.URLPolicy) *tracer.HTTPTracer {
+	ht := tracer.NewHTTPTracer(&http.Client{Timeout: httpTracerTimeout})
+	ht.SetURLPolicy(policy)
+	ht.SetRequestOptions(o.request)
+	ht.SetProxy(o.proxy)
+	ht.SetMaxHops(o.maxHops)
+
+	return ht
+}
+
+// parseProxy parse proxy url (`proxy` param) or proxy pool name (`proxy_pool` param)
+func parseProxy(query url.Values) (*url.URL, string, error) {
+	rawProxy, proxyPool := query.Get("proxy"), query.Get("proxy_pool")
+
+	if rawProxy != "" && proxyPool != "" {
+		return nil, "", errors.New("proxy and proxy_pool params could not be used together")
+	}
+
+	if rawProxy == "" {
+		return nil, proxyPool, nil
+	}
+
+	proxyURL, err := tracer.ParseProxy(rawProxy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return proxyURL, "", nil
+}
+
+// parseGeo parse emulated location settings. Supported params: geolocation (`latitude,longitude[,accuracy]`),
+// timezone (IANA timezone id) and locale
+func parseGeo(query url.Values) (*tracer.Geo, error) {
+	geo := &tracer.Geo{
+		Timezone: query.Get("timezone"),
+		Locale:   query.Get("locale"),
+	}
+
+	if rawLocation := query.Get("geolocation"); rawLocation != "" {
+		location, err := tracer.ParseGeoLocation(rawLocation)
+		if err != nil {
+			return nil, err
+		}
+
+		geo.Location = location
+	}
+
+	if geo.IsEmpty() {
+		return nil, nil
+	}
+
+	return geo, nil
+}
+
+// parseRequestOptions parse custom settings of the traced url request.
+// Supported params: user_agent, referer, accept_language, header (`Name: value`, could be repeated
+// or contain several lines) and cookie (`name=value; name2=value2`, could be repeated or contain several lines)
+func parseRequestOptions(query url.Values) (*tracer.RequestOptions, error) {
+	request := &tracer.RequestOptions{
+		UserAgent:      query.Get("user_agent"),
+		Referer:        query.Get("referer"),
+		AcceptLanguage: query.Get("accept_language"),
+		Headers:        http.Header{},
+	}
+
+	if request.Referer != "" {
+		if _, err := url.ParseRequestURI(request.Referer); err != nil {
+			return nil, fmt.Errorf("invalid referer. %s", err)
+		}
+	}
+
+	for _, value := range query["header"] {
+		for _, line := range strings.Split(value, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			name, headerValue, err := tracer.ParseHeader(line)
+			if err != nil {
+				return nil, err
+			}
+
+			request.Headers.Add(name, headerValue)
+		}
+	}
+
+	for _, value := range query["cookie"] {
+		for _, line := range strings.Split(value, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			cookies, err := tracer.ParseRequestCookies(line)
+			if err != nil {
+				return nil, err
+			}
+
+			request.Cookies = append(request.Cookies, cookies...)
+		}
+	}
+
+	return request, nil
+}
+
+// parseScreenSize - parse screen width and height from request or use default values
+func parseScreenSize(query url.Values, defaults *tracer.ScreenSize) *tracer.ScreenSize {
+	width, err := strconv.Atoi(query.Get("width"))
+	if err != nil {
+		width = defaults.Width
+	}
+
+	height, err := strconv.Atoi(query.Get("height"))
+	if err != nil {
+		height = defaults.Height
+	}
+
+	return tracer.NewScreenSize(width, height)
+}
+
+// parseWaitStrategy - parse page settle strategy from request or use default one.
+// Supported params: wait (timeout|load|idle|selector), wait_timeout (ms), idle_time (ms), selector
+func parseWaitStrategy(query url.Values) (*tracer.WaitStrategy, error) {
+	waitType := query.Get("wait")
+	if waitType == "" {
+		waitType = tracer.WaitTimeout
+	}
+
+	timeout, err := parseMillisecondsParam(query.Get("wait_timeout"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid wait_timeout. %s", err)
+	}
+
+	if timeout > maxWaitTimeout {
+		return nil, fmt.Errorf("wait_timeout should not exceed %d ms", maxWaitTimeout.Milliseconds())
+	}
+
+	idleTime, err := parseMillisecondsParam(query.Get("idle_time"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid idle_time. %s", err)
+	}
+
+	return tracer.NewWaitStrategy(waitType, timeout, idleTime, query.Get("selector"))
+}
+
+// parseMillisecondsParam convert milliseconds query param to time.Duration, empty value means 0
+func parseMillisecondsParam(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	ms, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// parseMaxHops convert hops limit param, empty value means tracer.DefaultMaxHops
+func parseMaxHops(value string) (int, error) {
+	if value == "" {
+		return tracer.DefaultMaxHops, nil
+	}
+
+	maxHops, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if maxHops < 1 || maxHops > maxHopsLimit {
+		return 0, fmt.Errorf("should be between 1 and %d", maxHopsLimit)
+	}
+
+	return maxHops, nil
+}
+
+// parseBoolParam convert boolean query param, empty value means false
+func parseBoolParam(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// queryOptions return request params used as tracer options (all except url).
+// Values of repeated params (header, cookie) are joined with new line, secrets are removed by redactOption
+func queryOptions(query url.Values) map[string]string {
+	options := make(map[string]string)
+
+	for key, values := range query {
+		value := query.Get(key)
+
+		switch key {
+		case "url":
+			continue
+		case "header", "cookie":
+			value = strings.Join(values, "\n")
+		}
+
+		if redacted := redactOption(key, value); redacted != "" || value == "" {
+			options[key] = redacted
+		}
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+
+	return options
+}
+
+// redactOption return option value which could be saved: proxy password is removed, only names of headers
+// and cookies are kept (separated by new line). Empty value is returned for invalid proxy
+func redactOption(key, value string) string {
+	switch key {
+	case "proxy":
+		proxyURL, err := tracer.ParseProxy(value)
+		if err != nil {
+			return ""
+		}
+
+		return tracer.RedactProxy(proxyURL)
+	case "header":
+		return optionNames(strings.Split(value, "\n"), ":")
+	case "cookie":
+		return optionNames(strings.FieldsFunc(value, func(r rune) bool { return r == '\n' || r == ';' }), "=")
+	}
+
+	return value
+}
+
+// optionNames return names of `name<separator>value` pairs separated by new line, pairs without value are
+// treated as names, so redacted value stays the same
+func optionNames(pairs []string, separator string) string {
+	names := make([]string, 0, len(pairs))
+
+	for _, pair := range pairs {
+		name := strings.TrimSpace(strings.SplitN(pair, separator, 2)[0])
+		if name == "" {
+			continue
+		}
+
+		if separator == ":" {
+			name = http.CanonicalHeaderKey(name)
+		}
+
+		names = append(names, name)
+	}
+
+	return strings.Join(names, "\n")
+}
+
+// requester return client address, first `X-Forwarded-For` address is preferred
+func requester(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// userAgent return user agent sent by tracer (from the first hop request headers)
+func userAgent(redirects []*tracer.JSONRedirect) string {
+	for _, r := range redirects {
+		for name, value := range r.RequestHeaders {
+			if strings.EqualFold(name, "User-Agent") && value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
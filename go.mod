@@ -8,9 +8,11 @@ require (
 	github.com/gobs/simplejson v0.0.0-20181106204727-c70e6bd5e26b // indirect
 	github.com/gorilla/websocket v1.4.1 // indirect
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/opentracing/opentracing-go v1.1.1-0.20190913142402-a7454ce5950e
 	github.com/raff/godet v0.0.0-20190830172613-29652e04000e
 	github.com/rs/cors v1.6.0
 	github.com/satori/go.uuid v1.2.0 // indirect
 	go.mongodb.org/mongo-driver v1.3.2
+	gopkg.in/yaml.v2 v2.4.0
 )
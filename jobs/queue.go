@@ -0,0 +1,385 @@
+// Package jobs implements asynchronous trace jobs queue
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lroman242/redirective/storage"
+)
+
+const (
+	storageTimeout       = 5 * time.Second
+	callbackTimeout      = 10 * time.Second
+	callbackAttempts     = 3
+	callbackRetryBackoff = 2 * time.Second
+)
+
+const (
+	errorMessageQueueFull   = "jobs queue is full"
+	errorMessageQueueClosed = "jobs queue is closed"
+)
+
+// ErrQueueFull is returned by Enqueue when there are too many queued jobs
+var ErrQueueFull = errors.New(errorMessageQueueFull)
+
+// Handler process the job and fill its results (redirects, screenshot)
+type Handler func(ctx context.Context, job *storage.Trace) error
+
+// Queue run jobs in background workers, keeps their state in the repository
+// and notifies `callback_url` when a job is finished
+type Queue struct {
+	sync.Mutex
+
+	repo    storage.TraceRepository
+	handler Handler
+	workers int
+	jobs    chan *storage.Trace
+	closed  bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	client  *http.Client
+	// ctx of running jobs, it is canceled if shutdown deadline is exceeded
+	ctx    context.Context
+	cancel context.CancelFunc
+	// queued state of running jobs, it is saved if the run is interrupted by shutdown
+	running map[string]*storage.Trace
+
+	callbackRetryBackoff time.Duration
+}
+
+// NewQueue create new queue with provided amount of workers and max amount of waiting jobs
+func NewQueue(repo storage.TraceRepository, handler Handler, workers, size int) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+
+	if size <= 0 {
+		size = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		ctx:                  ctx,
+		cancel:               cancel,
+		running:              make(map[string]*storage.Trace),
+		repo:                 repo,
+		handler:              handler,
+		workers:              workers,
+		jobs:                 make(chan *storage.Trace, size),
+		stop:                 make(chan struct{}),
+		client:               &http.Client{Timeout: callbackTimeout},
+		callbackRetryBackoff: callbackRetryBackoff,
+	}
+}
+
+// SetCallbackTransport set transport used to send job callbacks, e.g. the one restricted by url policy
+func (q *Queue) SetCallbackTransport(transport http.RoundTripper) {
+	q.client.Transport = transport
+}
+
+// Start run workers and resume jobs left queued or running by previous process.
+// Resumed jobs which don't fit the queue are pushed as soon as workers free the slots
+func (q *Queue) Start() {
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+
+		go q.work()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	pending, err := q.repo.List(ctx, &storage.ListFilter{Status: []string{storage.StatusQueued, storage.StatusRunning}})
+	if err != nil {
+		log.Printf("cannot load pending jobs. error: %s \n", err)
+		return
+	}
+
+	var overflow []*storage.Trace
+
+	for _, job := range pending {
+		job.Status = storage.StatusQueued
+
+		err := q.push(job)
+		if err == ErrQueueFull {
+			overflow = append(overflow, job)
+			continue
+		}
+
+		if err != nil {
+			log.Printf("cannot resume job %s. error: %s \n", job.ID, err)
+		}
+	}
+
+	if len(overflow) > 0 {
+		q.wg.Add(1)
+
+		go q.resume(overflow)
+	}
+}
+
+// resume push pending jobs which don't fit the queue as soon as workers free the slots.
+// Jobs left when the queue is closed stay pending in the repository
+func (q *Queue) resume(pending []*storage.Trace) {
+	defer q.wg.Done()
+
+	for _, job := range pending {
+		select {
+		case <-q.stop:
+			return
+		case q.jobs <- job:
+		}
+	}
+}
+
+// Enqueue save the job and schedule its processing
+func (q *Queue) Enqueue(ctx context.Context, job *storage.Trace) error {
+	job.Status = storage.StatusQueued
+
+	if err := q.repo.Save(ctx, job); err != nil {
+		return fmt.Errorf("cannot save job. %s", err)
+	}
+
+	if err := q.push(job); err != nil {
+		q.finish(job, err)
+
+		return err
+	}
+
+	return nil
+}
+
+// Run process the job synchronously, bypassing the queue. Job state is saved in the repository
+// the same way as for queued jobs, so results are available by job id
+func (q *Queue) Run(ctx context.Context, job *storage.Trace) error {
+	job.Start()
+
+	if err := q.repo.Save(ctx, job); err != nil {
+		return fmt.Errorf("cannot save job. %s", err)
+	}
+
+	q.finish(job, q.handler(ctx, job))
+
+	return nil
+}
+
+// Get load job state and results
+func (q *Queue) Get(ctx context.Context, id string) (*storage.Trace, error) {
+	return q.repo.Get(ctx, id)
+}
+
+// Close stop accepting new jobs and wait until workers finish running ones.
+// Jobs left in the queue stay `queued` in the repository and are resumed by the next Start
+func (q *Queue) Close() {
+	_ = q.Shutdown(context.Background())
+}
+
+// Shutdown stop accepting new jobs and wait until workers finish running ones or context is done.
+// Jobs interrupted by the deadline are canceled and saved as `queued` before Shutdown returns,
+// so they are resumed by the next Start
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.stop)
+	}
+	q.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.interrupt()
+		return ctx.Err()
+	}
+}
+
+// interrupt cancel running jobs and save their queued state
+func (q *Queue) interrupt() {
+	q.Lock()
+	q.cancel()
+	interrupted := q.running
+	q.running = make(map[string]*storage.Trace)
+	q.Unlock()
+
+	for _, job := range interrupted {
+		q.save(job)
+	}
+}
+
+// track keep queued state of the running job, false is returned if the queue is already interrupted
+func (q *Queue) track(queued *storage.Trace) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.ctx.Err() != nil {
+		return false
+	}
+
+	q.running[queued.ID] = queued
+
+	return true
+}
+
+// untrack forget queued state of the job which is about to finish, false is returned
+// if the job is interrupted (its queued state is saved by interrupt then)
+func (q *Queue) untrack(job *storage.Trace) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.ctx.Err() != nil {
+		return false
+	}
+
+	delete(q.running, job.ID)
+
+	return true
+}
+
+func (q *Queue) push(job *storage.Trace) error {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.closed {
+		return errors.New(errorMessageQueueClosed)
+	}
+
+	select {
+	case q.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case job := <-q.jobs:
+			// stop has priority over queued jobs
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+
+			q.run(job)
+		}
+	}
+}
+
+func (q *Queue) run(job *storage.Trace) {
+	queued := *job
+	queued.Status = storage.StatusQueued
+
+	job.Start()
+	q.save(job)
+
+	if !q.track(&queued) {
+		// shutdown deadline is exceeded before the job is started
+		q.save(&queued)
+		return
+	}
+
+	err := q.handler(q.ctx, job)
+
+	if !q.untrack(job) {
+		log.Printf("job %s is interrupted by shutdown, it will be resumed by the next start \n", job.ID)
+		return
+	}
+
+	q.finish(job, err)
+}
+
+// finish set final job status, save it and notify callback url
+func (q *Queue) finish(job *storage.Trace, err error) {
+	job.Finish(err)
+	q.save(job)
+
+	if job.CallbackURL != "" {
+		q.callback(job)
+	}
+}
+
+func (q *Queue) save(job *storage.Trace) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	if err := q.repo.Save(ctx, job); err != nil {
+		log.Printf("cannot save job %s. error: %s \n", job.ID, err)
+	}
+}
+
+// callback POST finished job (with redirects list) as json to the job callback url
+func (q *Queue) callback(job *storage.Trace) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		log.Printf("cannot encode job %s. error: %s \n", job.ID, err)
+		return
+	}
+
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		// the attempt is saved before it is sent, so the one interrupted by shutdown is kept as well
+		job.CallbackAttempts++
+		q.save(job)
+
+		err = q.sendCallback(job.CallbackURL, body)
+		if err == nil {
+			return
+		}
+
+		log.Printf("job %s callback attempt %d failed. error: %s \n", job.ID, attempt, err)
+
+		if attempt == callbackAttempts {
+			return
+		}
+
+		select {
+		case <-q.ctx.Done():
+			log.Printf("job %s callback is interrupted by shutdown \n", job.ID)
+			return
+		case <-time.After(q.callbackRetryBackoff * time.Duration(attempt)):
+		}
+	}
+}
+
+func (q *Queue) sendCallback(callbackURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(q.ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,378 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lroman242/redirective/storage"
+	"github.com/lroman242/redirective/tracer"
+)
+
+func waitFinished(t *testing.T, q *Queue, id string) *storage.Trace {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for time.Now().Before(deadline) {
+		job, err := q.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		if job.Finished() {
+			return job
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s isn't finished in time", id)
+
+	return nil
+}
+
+func TestQueue_Enqueue(t *testing.T) {
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		job.Redirects = []*tracer.JSONRedirect{{From: job.URL, To: "http://step1.test", Status: 302}}
+		job.Screenshot = "test.png"
+
+		return nil
+	}
+
+	q := NewQueue(storage.NewMemoryRepository(), handler, 2, 10)
+	q.Start()
+	defer q.Close()
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, "")
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if job.ID == "" {
+		t.Fatal("expect job id to be set")
+	}
+
+	finished := waitFinished(t, q, job.ID)
+
+	if finished.Status != storage.StatusDone {
+		t.Errorf("expect status %s but get %s", storage.StatusDone, finished.Status)
+	}
+
+	if len(finished.Redirects) != 1 || finished.Redirects[0].To != "http://step1.test" {
+		t.Error("expect job redirects to be saved")
+	}
+
+	if finished.Screenshot != "test.png" {
+		t.Errorf("expect screenshot test.png but get %s", finished.Screenshot)
+	}
+}
+
+func TestQueue_Enqueue_Failed(t *testing.T) {
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		return errors.New("navigation failed")
+	}
+
+	q := NewQueue(storage.NewMemoryRepository(), handler, 1, 10)
+	q.Start()
+	defer q.Close()
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, "")
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	finished := waitFinished(t, q, job.ID)
+
+	if finished.Status != storage.StatusFailed {
+		t.Errorf("expect status %s but get %s", storage.StatusFailed, finished.Status)
+	}
+
+	if finished.Error != "navigation failed" {
+		t.Errorf("expect error `navigation failed` but get `%s`", finished.Error)
+	}
+}
+
+func TestQueue_Enqueue_Full(t *testing.T) {
+	block := make(chan struct{})
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		<-block
+		return nil
+	}
+
+	store := storage.NewMemoryRepository()
+	q := NewQueue(store, handler, 1, 1)
+	q.Start()
+
+	defer func() {
+		close(block)
+		q.Close()
+	}()
+
+	// first job is taken by the worker, second one waits in the queue
+	_ = q.Enqueue(context.Background(), storage.NewTrace("http://step0.test", "chrome", nil, ""))
+	time.Sleep(50 * time.Millisecond)
+	_ = q.Enqueue(context.Background(), storage.NewTrace("http://step1.test", "chrome", nil, ""))
+
+	job := storage.NewTrace("http://step2.test", "chrome", nil, "")
+	if err := q.Enqueue(context.Background(), job); err != ErrQueueFull {
+		t.Fatalf("expect error: %s but got %v", ErrQueueFull, err)
+	}
+
+	saved, _ := store.Get(context.Background(), job.ID)
+	if saved.Status != storage.StatusFailed {
+		t.Errorf("expect rejected job status %s but get %s", storage.StatusFailed, saved.Status)
+	}
+}
+
+func TestQueue_Callback(t *testing.T) {
+	received := make(chan *storage.Trace, 1)
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		job := new(storage.Trace)
+		if err := json.NewDecoder(r.Body).Decode(job); err != nil {
+			t.Errorf("unexpected error `%s`", err)
+		}
+
+		received <- job
+	}))
+	defer server.Close()
+
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		job.Redirects = []*tracer.JSONRedirect{{From: job.URL, To: "http://step1.test", Status: 302}}
+
+		return nil
+	}
+
+	q := NewQueue(storage.NewMemoryRepository(), handler, 1, 10)
+	q.callbackRetryBackoff = 10 * time.Millisecond
+	q.Start()
+	defer q.Close()
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, server.URL)
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	select {
+	case cbJob := <-received:
+		if cbJob.ID != job.ID || cbJob.Status != storage.StatusDone {
+			t.Errorf("invalid callback job %+v", cbJob)
+		}
+
+		if len(cbJob.Redirects) != 1 || cbJob.Redirects[0].To != "http://step1.test" {
+			t.Error("expect callback to contain redirects")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback wasn't received")
+	}
+}
+
+func TestQueue_Start_ResumePending(t *testing.T) {
+	store := storage.NewMemoryRepository()
+	pending := storage.NewTrace("http://step0.test", "chrome", nil, "")
+	pending.Status = storage.StatusRunning
+	_ = store.Save(context.Background(), pending)
+
+	done := storage.NewTrace("http://step1.test", "chrome", nil, "")
+	done.Status = storage.StatusDone
+	_ = store.Save(context.Background(), done)
+
+	var calls int32
+
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+
+	q := NewQueue(store, handler, 1, 10)
+	q.Start()
+	defer q.Close()
+
+	finished := waitFinished(t, q, pending.ID)
+	if finished.Status != storage.StatusDone {
+		t.Errorf("expect resumed job status %s but get %s", storage.StatusDone, finished.Status)
+	}
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expect only pending job to be resumed but handler called %d times", calls)
+	}
+}
+
+func TestQueue_Start_ResumeOverflow(t *testing.T) {
+	store := storage.NewMemoryRepository()
+
+	var pending []*storage.Trace
+
+	for i := 0; i < 5; i++ {
+		job := storage.NewTrace("http://step0.test", "chrome", nil, "")
+		job.Status = storage.StatusQueued
+		_ = store.Save(context.Background(), job)
+
+		pending = append(pending, job)
+	}
+
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	// pending jobs don't fit the queue, they are pushed when workers free the slots
+	q := NewQueue(store, handler, 1, 1)
+	q.Start()
+	defer q.Close()
+
+	for _, job := range pending {
+		finished := waitFinished(t, q, job.ID)
+		if finished.Status != storage.StatusDone {
+			t.Errorf("expect resumed job status %s but get %s", storage.StatusDone, finished.Status)
+		}
+	}
+}
+
+func TestQueue_Run(t *testing.T) {
+	handler := func(ctx context.Context, job *storage.Trace) error {
+		if job.URL == "http://fail.test" {
+			return errors.New("navigation failed")
+		}
+
+		job.Redirects = []*tracer.JSONRedirect{{From: job.URL, To: "http://step1.test", Status: 302}}
+
+		return nil
+	}
+
+	store := storage.NewMemoryRepository()
+	q := NewQueue(store, handler, 1, 1)
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, "")
+	if err := q.Run(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if job.Status != storage.StatusDone || len(job.Redirects) != 1 {
+		t.Errorf("expect job to be done with 1 redirect but get %s with %d", job.Status, len(job.Redirects))
+	}
+
+	saved, err := store.Get(context.Background(), job.ID)
+	if err != nil || saved.Status != storage.StatusDone {
+		t.Error("expect job to be saved in the store")
+	}
+
+	failed := storage.NewTrace("http://fail.test", "chrome", nil, "")
+	if err := q.Run(context.Background(), failed); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if failed.Status != storage.StatusFailed || failed.Error != "navigation failed" {
+		t.Errorf("expect job to fail with `navigation failed` but get %s `%s`", failed.Status, failed.Error)
+	}
+}
+
+func TestQueue_Close(t *testing.T) {
+	q := NewQueue(storage.NewMemoryRepository(), func(ctx context.Context, job *storage.Trace) error { return nil }, 1, 10)
+	q.Start()
+	q.Close()
+
+	if err := q.Enqueue(context.Background(), storage.NewTrace("http://step0.test", "chrome", nil, "")); err == nil || err.Error() != errorMessageQueueClosed {
+		t.Errorf("expect error: %s but got %v", errorMessageQueueClosed, err)
+	}
+}
+
+func TestQueue_Shutdown_Deadline(t *testing.T) {
+	repo := storage.NewMemoryRepository()
+	started := make(chan struct{})
+
+	q := NewQueue(repo, func(ctx context.Context, job *storage.Trace) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	}, 1, 10)
+	q.Start()
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, "")
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded error but get %v", err)
+	}
+
+	// queued state is saved before Shutdown returns
+	saved, err := repo.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if saved.Status != storage.StatusQueued {
+		t.Errorf("expect interrupted job to be %s but get %s", storage.StatusQueued, saved.Status)
+	}
+
+	// wait until the worker exits, the interrupted job isn't finished by it
+	q.Close()
+
+	saved, err = repo.Get(context.Background(), job.ID)
+	if err != nil || saved.Status != storage.StatusQueued {
+		t.Errorf("expect interrupted job to stay %s but get %+v", storage.StatusQueued, saved)
+	}
+}
+
+func TestQueue_Shutdown_DeadlineCallback(t *testing.T) {
+	called := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(called)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	repo := storage.NewMemoryRepository()
+	q := NewQueue(repo, func(ctx context.Context, job *storage.Trace) error { return nil }, 1, 10)
+	q.Start()
+
+	job := storage.NewTrace("http://step0.test", "chrome", nil, server.URL)
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	<-called
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := q.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expect deadline exceeded error but get %v", err)
+	}
+
+	saved, err := repo.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if saved.Status != storage.StatusDone || saved.CallbackAttempts != 1 {
+		t.Errorf("expect done job with 1 callback attempt but get %s with %d", saved.Status, saved.CallbackAttempts)
+	}
+
+	// interrupted callback isn't retried
+	q.Close()
+}
@@ -0,0 +1,103 @@
+// Package proxy implements named pools of proxies which traces could be routed through
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sync"
+
+	"github.com/lroman242/redirective/tracer"
+)
+
+const (
+	errorMessagePoolsNotConfigured = "proxy pools are not configured"
+	errorMessageEmptyPool          = "proxy pool `%s` has no proxies"
+	errorMessageUnknownPool        = "unknown proxy pool `%s`"
+)
+
+// PoolConfig describe proxy pool in configuration file.
+// Geo settings are emulated by traces routed through the pool unless they are provided explicitly
+type PoolConfig struct {
+	Proxies []string    `json:"proxies"`
+	Geo     *tracer.Geo `json:"geo,omitempty"`
+}
+
+// Pool is a list of proxies used in round robin manner
+type Pool struct {
+	sync.Mutex
+
+	proxies []*url.URL
+	geo     *tracer.Geo
+	next    int
+}
+
+// Pools contains proxy pools by name (for example country code)
+type Pools struct {
+	pools map[string]*Pool
+}
+
+// NewPools validate pools configuration and create pools
+func NewPools(config map[string]*PoolConfig) (*Pools, error) {
+	pools := &Pools{pools: make(map[string]*Pool, len(config))}
+
+	for name, c := range config {
+		if c == nil || len(c.Proxies) == 0 {
+			return nil, fmt.Errorf(errorMessageEmptyPool, name)
+		}
+
+		pool := &Pool{geo: c.Geo}
+
+		for _, raw := range c.Proxies {
+			proxy, err := tracer.ParseProxy(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy pool `%s`. %s", name, err)
+			}
+
+			pool.proxies = append(pool.proxies, proxy)
+		}
+
+		pools.pools[name] = pool
+	}
+
+	return pools, nil
+}
+
+// LoadPools read pools configuration from json file (pool name => pool config)
+func LoadPools(path string) (*Pools, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read proxy pools file. %s", err)
+	}
+
+	config := make(map[string]*PoolConfig)
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy pools file. %s", err)
+	}
+
+	return NewPools(config)
+}
+
+// Next return the next proxy of the named pool and pool geo settings
+func (p *Pools) Next(name string) (*url.URL, *tracer.Geo, error) {
+	if p == nil {
+		return nil, nil, errors.New(errorMessagePoolsNotConfigured)
+	}
+
+	pool, ok := p.pools[name]
+	if !ok {
+		return nil, nil, fmt.Errorf(errorMessageUnknownPool, name)
+	}
+
+	pool.Lock()
+	defer pool.Unlock()
+
+	proxy := pool.proxies[pool.next%len(pool.proxies)]
+	pool.next++
+
+	return proxy, pool.geo, nil
+}
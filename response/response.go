@@ -17,6 +17,12 @@ type Response struct {
 	Data       interface{} `json:"data"`
 }
 
+// codedResponse is a failed response with machine readable failure reason
+type codedResponse struct {
+	*Response
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
 // Success should be used to send success response (200 http status code)
 func (r *Response) Success(w http.ResponseWriter) {
 	r.Status = true
@@ -44,20 +50,25 @@ func (r *Response) Success(w http.ResponseWriter) {
 
 // Failed should be used to send error response (40x or 50x http status code)
 func (r *Response) Failed(w http.ResponseWriter) {
+	r.FailedWithCode(w, "")
+}
+
+// FailedWithCode should be used to send error response with machine readable failure reason (`error_code`)
+func (r *Response) FailedWithCode(w http.ResponseWriter, code string) {
 	r.Status = false
 
 	if r.StatusCode == 0 {
 		r.StatusCode = http.StatusBadRequest
 	}
 
-	jsonResponse, err := json.Marshal(r)
+	jsonResponse, err := json.Marshal(&codedResponse{Response: r, ErrorCode: code})
 	if err != nil {
 		log.Printf("Error while json encode: %q", err.Error())
 
 		r.StatusCode = http.StatusInternalServerError
 		r.Message = notConvertedToJSONErrorMessage
 		r.Data = nil
-		r.Failed(w)
+		r.FailedWithCode(w, code)
 
 		return
 	}
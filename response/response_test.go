@@ -296,3 +296,51 @@ func TestResponse_Failed_With_Invalid_Data(t *testing.T) {
 		t.Error("expect Response.Data equal to nil")
 	}
 }
+
+func TestResponse_FailedWithCode(t *testing.T) {
+	responseWriter := httptest.NewRecorder()
+	response := &Response{true, stringTestMessage, http.StatusNotFound, nil}
+
+	response.FailedWithCode(responseWriter, "not_found")
+
+	if responseWriter.Code != http.StatusNotFound {
+		t.Errorf("wrong response status code. expect %d but get %d", http.StatusNotFound, responseWriter.Code)
+	}
+
+	unmarshalResponse := struct {
+		Status    bool   `json:"status"`
+		Message   string `json:"message"`
+		ErrorCode string `json:"error_code"`
+	}{}
+
+	err := json.NewDecoder(responseWriter.Body).Decode(&unmarshalResponse)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if unmarshalResponse.Status != false {
+		t.Error("expect Response.Status equal to false")
+	}
+
+	if unmarshalResponse.Message != stringTestMessage {
+		t.Errorf("expect Response.Message equal to `%s`", stringTestMessage)
+	}
+
+	if unmarshalResponse.ErrorCode != "not_found" {
+		t.Errorf("expect error_code equal to `not_found` but get `%s`", unmarshalResponse.ErrorCode)
+	}
+
+	responseWriter = httptest.NewRecorder()
+	(&Response{true, stringTestMessage, http.StatusNotFound, nil}).Failed(responseWriter)
+
+	fields := make(map[string]interface{})
+
+	err = json.NewDecoder(responseWriter.Body).Decode(&fields)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := fields["error_code"]; ok {
+		t.Error("unexpected error_code in response without code")
+	}
+}
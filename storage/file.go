@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	traceFileExt = ".json"
+	usageFileExt = ".usage"
+	keysDir      = "keys"
+)
+
+// FileRepository keeps every trace as a separate json file in the directory.
+// Api keys and their usage are kept in `keys` sub-directory
+type FileRepository struct {
+	sync.RWMutex
+	dir string
+}
+
+// NewFileRepository create repository in provided directory (it is created if not exists)
+func NewFileRepository(dir string) (*FileRepository, error) {
+	if err := os.MkdirAll(filepath.Join(dir, keysDir), 0755); err != nil {
+		return nil, fmt.Errorf("cannot create storage directory. %s", err)
+	}
+
+	return &FileRepository{dir: dir}, nil
+}
+
+// Save insert new trace or replace existing one
+func (fr *FileRepository) Save(ctx context.Context, trace *Trace) error {
+	if trace.ID == "" {
+		trace.ID = newID()
+	} else if err := validateID(trace.ID); err != nil {
+		return err
+	}
+
+	fr.Lock()
+	defer fr.Unlock()
+
+	return fr.write(fr.path(trace.ID), trace)
+}
+
+// Get load trace by id
+func (fr *FileRepository) Get(ctx context.Context, id string) (*Trace, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	fr.RLock()
+	defer fr.RUnlock()
+
+	return fr.read(fr.path(id))
+}
+
+// List return traces matching the filter, newest first
+func (fr *FileRepository) List(ctx context.Context, filter *ListFilter) ([]*Trace, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	fr.RLock()
+	defer fr.RUnlock()
+
+	files, err := ioutil.ReadDir(fr.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	traces := make([]*Trace, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), traceFileExt) {
+			continue
+		}
+
+		trace, err := fr.read(filepath.Join(fr.dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		traces = append(traces, trace)
+	}
+
+	return filter.apply(traces), nil
+}
+
+// Delete remove trace by id
+func (fr *FileRepository) Delete(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	fr.Lock()
+	defer fr.Unlock()
+
+	err := os.Remove(fr.path(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+// Close do nothing, implemented to satisfy TraceRepository interface
+func (fr *FileRepository) Close() error {
+	return nil
+}
+
+func (fr *FileRepository) path(id string) string {
+	return filepath.Join(fr.dir, id+traceFileExt)
+}
+
+// write encode value to json file. Data is written to temporary file which is renamed then,
+// so readers never see partially written file
+func (fr *FileRepository) write(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (fr *FileRepository) read(path string) (*Trace, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	trace := new(Trace)
+	if err := json.Unmarshal(data, trace); err != nil {
+		return nil, fmt.Errorf("cannot decode trace file %s. %s", path, err)
+	}
+
+	return trace, nil
+}
+
+// dailyUsage is api key requests counter of the day
+type dailyUsage struct {
+	Day   string `json:"day"`
+	Count int    `json:"count"`
+}
+
+// SaveKey insert new api key or replace existing one
+func (fr *FileRepository) SaveKey(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = newID()
+	} else if err := validateID(key.ID); err != nil {
+		return err
+	}
+
+	fr.Lock()
+	defer fr.Unlock()
+
+	return fr.write(fr.keyPath(key.ID, traceFileExt), key)
+}
+
+// GetKey load api key by id
+func (fr *FileRepository) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	fr.RLock()
+	defer fr.RUnlock()
+
+	return fr.readKey(fr.keyPath(id, traceFileExt))
+}
+
+// FindKey load api key by secret hash
+func (fr *FileRepository) FindKey(ctx context.Context, hash string) (*APIKey, error) {
+	keys, err := fr.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// ListKeys return all api keys, oldest first
+func (fr *FileRepository) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	fr.RLock()
+	defer fr.RUnlock()
+
+	files, err := ioutil.ReadDir(filepath.Join(fr.dir, keysDir))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*APIKey, 0, len(files))
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), traceFileExt) {
+			continue
+		}
+
+		key, err := fr.readKey(filepath.Join(fr.dir, keysDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// IncrementUsage increase api key requests counter of the day by n, only the last day counter is kept
+func (fr *FileRepository) IncrementUsage(ctx context.Context, id string, day time.Time, n int) (int, error) {
+	if err := validateID(id); err != nil {
+		return 0, err
+	}
+
+	fr.Lock()
+	defer fr.Unlock()
+
+	path := fr.keyPath(id, usageFileExt)
+	usage := dailyUsage{}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return 0, fmt.Errorf("cannot decode usage file %s. %s", path, err)
+		}
+	}
+
+	if usage.Day != usageDay(day) {
+		usage = dailyUsage{Day: usageDay(day)}
+	}
+
+	usage.Count += n
+
+	if err := fr.write(path, &usage); err != nil {
+		return 0, err
+	}
+
+	return usage.Count, nil
+}
+
+func (fr *FileRepository) keyPath(id, ext string) string {
+	return filepath.Join(fr.dir, keysDir, id+ext)
+}
+
+func (fr *FileRepository) readKey(path string) (*APIKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrKeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := new(APIKey)
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, fmt.Errorf("cannot decode api key file %s. %s", path, err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sort orders supported by List. Traces are ordered by id which grows with creation time
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+)
+
+// ListFilter describe which traces should be returned by List
+type ListFilter struct {
+	// Status limits results to traces with one of provided statuses (all if empty)
+	Status []string
+	// URL is an exact start url
+	URL string
+	// FinalURL is an exact url of the last document in the chain
+	FinalURL string
+	// Domain should be a host (or its parent domain) of any url in the chain
+	Domain string
+	// StatusCode should be a status code of any hop in the chain
+	StatusCode int
+	// CreatedFrom and CreatedTo limit trace creation time (inclusive, ignored if zero)
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Query is a case insensitive text searched in urls of the chain and error message
+	Query string
+	// Sort is SortNewest (default) or SortOldest
+	Sort string
+	// Cursor is an id of the last trace of the previous page
+	Cursor string
+	// Limit is a max amount of returned traces (all if 0)
+	Limit int
+}
+
+// validate check cursor format
+func (f *ListFilter) validate() error {
+	if f == nil || f.Cursor == "" {
+		return nil
+	}
+
+	return validateID(f.Cursor)
+}
+
+// oldestFirst check sort order
+func (f *ListFilter) oldestFirst() bool {
+	return f != nil && f.Sort == SortOldest
+}
+
+// afterCursor check if trace with provided id goes after the cursor in the filter sort order
+func (f *ListFilter) afterCursor(id string) bool {
+	if f == nil || f.Cursor == "" {
+		return true
+	}
+
+	if f.oldestFirst() {
+		return id > f.Cursor
+	}
+
+	return id < f.Cursor
+}
+
+// domainRegexp match urls which host is the domain or its subdomain, used by drivers with regexp queries
+func domainRegexp(domain string) string {
+	return `^[a-zA-Z][a-zA-Z0-9+.-]*://([^/?#@]*@)?([^/?#]*\.)?` + regexp.QuoteMeta(strings.ToLower(domain)) + `(:[0-9]+)?([/?#]|$)`
+}
+
+// Match check if trace satisfies all filter conditions except cursor and limit
+func (f *ListFilter) Match(trace *Trace) bool {
+	if f == nil {
+		return true
+	}
+
+	return f.matchStatus(trace) &&
+		(f.URL == "" || trace.URL == f.URL) &&
+		(f.FinalURL == "" || trace.FinalURL() == f.FinalURL) &&
+		(f.CreatedFrom.IsZero() || !trace.CreatedAt.Before(f.CreatedFrom)) &&
+		(f.CreatedTo.IsZero() || !trace.CreatedAt.After(f.CreatedTo)) &&
+		f.matchStatusCode(trace) &&
+		f.matchDomain(trace) &&
+		f.matchQuery(trace)
+}
+
+func (f *ListFilter) matchStatus(trace *Trace) bool {
+	if len(f.Status) == 0 {
+		return true
+	}
+
+	for _, s := range f.Status {
+		if s == trace.Status {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *ListFilter) matchStatusCode(trace *Trace) bool {
+	if f.StatusCode == 0 {
+		return true
+	}
+
+	for _, r := range trace.Redirects {
+		if r.Status == f.StatusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *ListFilter) matchDomain(trace *Trace) bool {
+	if f.Domain == "" {
+		return true
+	}
+
+	domain := strings.ToLower(f.Domain)
+
+	for _, rawURL := range trace.urls() {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+
+		host := strings.ToLower(u.Hostname())
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *ListFilter) matchQuery(trace *Trace) bool {
+	if f.Query == "" {
+		return true
+	}
+
+	query := strings.ToLower(f.Query)
+
+	for _, text := range append(trace.urls(), trace.Error) {
+		if strings.Contains(strings.ToLower(text), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apply filter, sort, cursor and limit to traces loaded by drivers without query support
+func (f *ListFilter) apply(traces []*Trace) []*Trace {
+	filtered := make([]*Trace, 0, len(traces))
+
+	for _, trace := range traces {
+		if f.afterCursor(trace.ID) && f.Match(trace) {
+			filtered = append(filtered, trace)
+		}
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		if f.oldestFirst() {
+			return filtered[i].ID < filtered[j].ID
+		}
+
+		return filtered[i].ID > filtered[j].ID
+	})
+
+	if f != nil && f.Limit > 0 && len(filtered) > f.Limit {
+		filtered = filtered[:f.Limit]
+	}
+
+	return filtered
+}
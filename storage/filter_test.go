@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lroman242/redirective/tracer"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func makeTestTrace(url string, createdAt time.Time, redirects ...*tracer.JSONRedirect) *Trace {
+	trace := NewTrace(url, "chrome", nil, "")
+	trace.Status = StatusDone
+	trace.CreatedAt = createdAt
+	trace.Redirects = redirects
+
+	return trace
+}
+
+func TestListFilter_Match(t *testing.T) {
+	now := time.Now().UTC()
+	trace := makeTestTrace("http://step0.test/campaign?id=1", now,
+		&tracer.JSONRedirect{From: "http://step0.test/campaign?id=1", To: "https://www.landing.test/offer", Status: 302},
+		&tracer.JSONRedirect{To: "https://www.landing.test/offer", Status: 200},
+	)
+
+	matching := []*ListFilter{
+		nil,
+		{},
+		{Status: []string{StatusDone}},
+		{URL: "http://step0.test/campaign?id=1"},
+		{FinalURL: "https://www.landing.test/offer"},
+		{Domain: "landing.test"},
+		{Domain: "WWW.LANDING.TEST"},
+		{StatusCode: 302},
+		{CreatedFrom: now.Add(-time.Hour), CreatedTo: now},
+		{Query: "OFFER"},
+	}
+
+	for i, filter := range matching {
+		if !filter.Match(trace) {
+			t.Errorf("expect filter %d to match the trace", i)
+		}
+	}
+
+	notMatching := []*ListFilter{
+		{Status: []string{StatusQueued}},
+		{URL: "http://step0.test"},
+		{FinalURL: "http://step0.test/campaign?id=1"},
+		{Domain: "anding.test"},
+		{StatusCode: 301},
+		{CreatedFrom: now.Add(time.Second)},
+		{CreatedTo: now.Add(-time.Second)},
+		{Query: "missing"},
+	}
+
+	for i, filter := range notMatching {
+		if filter.Match(trace) {
+			t.Errorf("expect filter %d not to match the trace", i)
+		}
+	}
+}
+
+func TestTrace_FinalURL(t *testing.T) {
+	trace := makeTestTrace("http://step0.test", time.Now())
+	if trace.FinalURL() != "http://step0.test" {
+		t.Errorf("expect start url to be final one if there are no redirects but get %s", trace.FinalURL())
+	}
+
+	trace.Redirects = []*tracer.JSONRedirect{{From: "http://step0.test", To: "http://step1.test", Status: 302}}
+	if trace.FinalURL() != "http://step1.test" {
+		t.Errorf("expect final url http://step1.test but get %s", trace.FinalURL())
+	}
+}
+
+// testRepositoryList check filtering, sorting and pagination of the repository
+func testRepositoryList(t *testing.T, repo TraceRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour)
+
+	var ids []string
+
+	for i := 0; i < 5; i++ {
+		status := 302
+		if i%2 == 0 {
+			status = 301
+		}
+
+		trace := makeTestTrace("http://step0.test", start.Add(time.Duration(i)*time.Minute),
+			&tracer.JSONRedirect{From: "http://step0.test", To: "http://final.test", Status: status},
+			&tracer.JSONRedirect{To: "http://final.test", Status: 200},
+		)
+
+		if err := repo.Save(ctx, trace); err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		ids = append(ids, trace.ID)
+	}
+
+	// newest first, two pages
+	page, err := repo.List(ctx, &ListFilter{Limit: 3})
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(page) != 3 || page[0].ID != ids[4] || page[2].ID != ids[2] {
+		t.Fatal("invalid first page")
+	}
+
+	page, _ = repo.List(ctx, &ListFilter{Limit: 3, Cursor: page[2].ID})
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[0] {
+		t.Fatal("invalid second page")
+	}
+
+	// oldest first
+	page, _ = repo.List(ctx, &ListFilter{Limit: 2, Sort: SortOldest, Cursor: ids[0]})
+	if len(page) != 2 || page[0].ID != ids[1] || page[1].ID != ids[2] {
+		t.Error("invalid oldest first page")
+	}
+
+	page, _ = repo.List(ctx, &ListFilter{StatusCode: 301, Domain: "final.test", FinalURL: "http://final.test"})
+	if len(page) != 3 {
+		t.Errorf("expect 3 traces with 301 redirect but get %d", len(page))
+	}
+
+	page, _ = repo.List(ctx, &ListFilter{CreatedFrom: start.Add(time.Minute), CreatedTo: start.Add(3 * time.Minute), Limit: 2})
+	if len(page) != 2 || page[0].ID != ids[3] {
+		t.Error("invalid date range page")
+	}
+
+	if _, err := repo.List(ctx, &ListFilter{Cursor: "invalid"}); err != ErrInvalidID {
+		t.Errorf("expect error: %s but got %v", ErrInvalidID, err)
+	}
+}
+
+func TestMemoryRepository_List(t *testing.T) {
+	testRepositoryList(t, NewMemoryRepository())
+}
+
+func TestFileRepository_List(t *testing.T) {
+	repo, err := NewFileRepository(filepath.Join(tempDir(t), "traces"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	testRepositoryList(t, repo)
+}
+
+func TestSQLiteRepository_List(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(tempDir(t), "redirective.db"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+	defer repo.Close()
+
+	testRepositoryList(t, repo)
+}
+
+func TestMongoListQuery(t *testing.T) {
+	query, err := mongoListQuery(&ListFilter{})
+	if err != nil || len(query) != 0 {
+		t.Errorf("expect empty query for empty filter")
+	}
+
+	query, err = mongoListQuery(&ListFilter{URL: "http://step0.test", StatusCode: 302, Domain: "step0.test", Cursor: "5e99fa77ec255a4dbcb9b904"})
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if conditions, ok := query["$and"]; !ok || len(conditions.(bson.A)) != 4 {
+		t.Errorf("expect 4 query conditions but get %v", query)
+	}
+
+	if _, err := mongoListQuery(&ListFilter{Cursor: "invalid"}); err != ErrInvalidID {
+		t.Errorf("expect error: %s but got %v", ErrInvalidID, err)
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const errorMessageKeyNotFound = "api key not found"
+
+// ErrKeyNotFound is returned when api key doesn't exist
+var ErrKeyNotFound = errors.New(errorMessageKeyNotFound)
+
+// usageDayLayout is a format of the day usage is counted for (UTC)
+const usageDayLayout = "2006-01-02"
+
+// APIKey is an api access key. The secret itself isn't stored, keys are looked up by its sha256 hash
+type APIKey struct {
+	ID     string   `json:"id" bson:"-"`
+	Name   string   `json:"name" bson:"name"`
+	Hash   string   `json:"hash" bson:"hash"`
+	Prefix string   `json:"prefix" bson:"prefix"` // first characters of the secret to recognize the key
+	Scopes []string `json:"scopes" bson:"scopes"`
+	// RateLimit is an amount of requests per minute, DailyQuota is an amount of requests per day (0 - unlimited)
+	RateLimit  int        `json:"rate_limit" bson:"rate_limit"`
+	DailyQuota int        `json:"daily_quota" bson:"daily_quota"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// KeyRepository persist api keys and their daily usage
+type KeyRepository interface {
+	// SaveKey insert new key (new ID is set if it is empty) or replace existing one
+	SaveKey(ctx context.Context, key *APIKey) error
+	// GetKey load key by id
+	GetKey(ctx context.Context, id string) (*APIKey, error)
+	// FindKey load key by secret hash
+	FindKey(ctx context.Context, hash string) (*APIKey, error)
+	// ListKeys return all keys (revoked ones too), ordered by id (creation order)
+	ListKeys(ctx context.Context) ([]*APIKey, error)
+	// IncrementUsage increase key requests counter of the day by n and return the new value,
+	// negative n decrease the counter
+	IncrementUsage(ctx context.Context, id string, day time.Time, n int) (int, error)
+}
+
+// Repository persist traces and api keys
+type Repository interface {
+	TraceRepository
+	KeyRepository
+}
+
+// HasScope check if key grants the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Revoked check if key was revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// usageDay return the day usage is counted for
+func usageDay(day time.Time) string {
+	return day.UTC().Format(usageDayLayout)
+}
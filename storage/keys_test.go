@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testKeyRepository run the same api keys checks against every driver
+func testKeyRepository(t *testing.T, repo KeyRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+	createdAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	key := &APIKey{Name: "partner", Hash: "hash1", Prefix: "rdv_1234", Scopes: []string{"trace", "read"}, RateLimit: 10, DailyQuota: 100, CreatedAt: createdAt}
+	if err := repo.SaveKey(ctx, key); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if key.ID == "" {
+		t.Fatal("expect key id to be set")
+	}
+
+	other := &APIKey{Name: "other", Hash: "hash2", Scopes: []string{"screenshot"}, CreatedAt: createdAt}
+	if err := repo.SaveKey(ctx, other); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	saved, err := repo.FindKey(ctx, "hash1")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if saved.ID != key.ID || saved.Name != "partner" || saved.RateLimit != 10 || saved.DailyQuota != 100 || !saved.CreatedAt.Equal(createdAt) {
+		t.Errorf("invalid saved key %+v", saved)
+	}
+
+	if !saved.HasScope("trace") || saved.HasScope("screenshot") || saved.Revoked() {
+		t.Errorf("invalid saved key scopes %v", saved.Scopes)
+	}
+
+	if _, err := repo.FindKey(ctx, "unknown"); err != ErrKeyNotFound {
+		t.Errorf("expect error: %s but got %v", ErrKeyNotFound, err)
+	}
+
+	// revoke
+	revokedAt := createdAt.Add(time.Hour)
+	key.RevokedAt = &revokedAt
+
+	if err := repo.SaveKey(ctx, key); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	saved, err = repo.GetKey(ctx, key.ID)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if !saved.Revoked() || !saved.RevokedAt.Equal(revokedAt) {
+		t.Errorf("expect key to be revoked at %s but get %v", revokedAt, saved.RevokedAt)
+	}
+
+	if _, err := repo.GetKey(ctx, newID()); err != ErrKeyNotFound {
+		t.Errorf("expect error: %s but got %v", ErrKeyNotFound, err)
+	}
+
+	keys, err := repo.ListKeys(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(keys) != 2 || keys[0].ID != key.ID || keys[1].ID != other.ID {
+		t.Errorf("expect 2 keys, oldest first")
+	}
+
+	// usage
+	for i := 1; i <= 3; i++ {
+		count, err := repo.IncrementUsage(ctx, key.ID, createdAt, 1)
+		if err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		if count != i {
+			t.Errorf("expect usage %d but get %d", i, count)
+		}
+	}
+
+	if count, _ := repo.IncrementUsage(ctx, key.ID, createdAt, 5); count != 8 {
+		t.Errorf("expect usage 8 but get %d", count)
+	}
+
+	if count, _ := repo.IncrementUsage(ctx, key.ID, createdAt, -5); count != 3 {
+		t.Errorf("expect usage 3 but get %d", count)
+	}
+
+	if count, _ := repo.IncrementUsage(ctx, other.ID, createdAt, 1); count != 1 {
+		t.Errorf("expect usage of other key 1 but get %d", count)
+	}
+
+	if count, _ := repo.IncrementUsage(ctx, key.ID, createdAt.Add(24*time.Hour), 1); count != 1 {
+		t.Errorf("expect usage of the next day 1 but get %d", count)
+	}
+}
+
+func TestMemoryRepository_Keys(t *testing.T) {
+	testKeyRepository(t, NewMemoryRepository())
+}
+
+func TestFileRepository_Keys(t *testing.T) {
+	repo, err := NewFileRepository(filepath.Join(tempDir(t), "traces"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	testKeyRepository(t, repo)
+
+	// keys aren't listed as traces
+	traces, err := repo.List(context.Background(), &ListFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(traces) != 0 {
+		t.Errorf("expect no traces but get %d", len(traces))
+	}
+}
+
+func TestSQLiteRepository_Keys(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(tempDir(t), "redirective.db"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	testKeyRepository(t, repo)
+}
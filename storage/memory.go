@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps traces and api keys in memory. Results are lost on restart,
+// it is intended for local runs and tests
+type MemoryRepository struct {
+	sync.RWMutex
+	traces map[string]Trace
+	keys   map[string]APIKey
+	usage  map[string]int
+}
+
+// NewMemoryRepository create empty in-memory repository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		traces: make(map[string]Trace),
+		keys:   make(map[string]APIKey),
+		usage:  make(map[string]int),
+	}
+}
+
+// Save insert new trace or replace existing one
+func (mr *MemoryRepository) Save(ctx context.Context, trace *Trace) error {
+	if trace.ID == "" {
+		trace.ID = newID()
+	} else if err := validateID(trace.ID); err != nil {
+		return err
+	}
+
+	mr.Lock()
+	defer mr.Unlock()
+
+	mr.traces[trace.ID] = *trace
+
+	return nil
+}
+
+// Get load trace by id
+func (mr *MemoryRepository) Get(ctx context.Context, id string) (*Trace, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	mr.RLock()
+	defer mr.RUnlock()
+
+	trace, ok := mr.traces[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &trace, nil
+}
+
+// List return traces matching the filter, newest first
+func (mr *MemoryRepository) List(ctx context.Context, filter *ListFilter) ([]*Trace, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	mr.RLock()
+	defer mr.RUnlock()
+
+	traces := make([]*Trace, 0, len(mr.traces))
+
+	for id := range mr.traces {
+		trace := mr.traces[id]
+		traces = append(traces, &trace)
+	}
+
+	return filter.apply(traces), nil
+}
+
+// Delete remove trace by id
+func (mr *MemoryRepository) Delete(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	mr.Lock()
+	defer mr.Unlock()
+
+	if _, ok := mr.traces[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(mr.traces, id)
+
+	return nil
+}
+
+// Close do nothing, implemented to satisfy TraceRepository interface
+func (mr *MemoryRepository) Close() error {
+	return nil
+}
+
+// SaveKey insert new api key or replace existing one
+func (mr *MemoryRepository) SaveKey(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = newID()
+	} else if err := validateID(key.ID); err != nil {
+		return err
+	}
+
+	mr.Lock()
+	defer mr.Unlock()
+
+	mr.keys[key.ID] = *key
+
+	return nil
+}
+
+// GetKey load api key by id
+func (mr *MemoryRepository) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	mr.RLock()
+	defer mr.RUnlock()
+
+	key, ok := mr.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return &key, nil
+}
+
+// FindKey load api key by secret hash
+func (mr *MemoryRepository) FindKey(ctx context.Context, hash string) (*APIKey, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	for id := range mr.keys {
+		if key := mr.keys[id]; key.Hash == hash {
+			return &key, nil
+		}
+	}
+
+	return nil, ErrKeyNotFound
+}
+
+// ListKeys return all api keys, oldest first
+func (mr *MemoryRepository) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	mr.RLock()
+	defer mr.RUnlock()
+
+	keys := make([]*APIKey, 0, len(mr.keys))
+
+	for id := range mr.keys {
+		key := mr.keys[id]
+		keys = append(keys, &key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// IncrementUsage increase api key requests counter of the day by n
+func (mr *MemoryRepository) IncrementUsage(ctx context.Context, id string, day time.Time, n int) (int, error) {
+	if err := validateID(id); err != nil {
+		return 0, err
+	}
+
+	mr.Lock()
+	defer mr.Unlock()
+
+	counter := id + ":" + usageDay(day)
+	mr.usage[counter] += n
+
+	return mr.usage[counter], nil
+}
@@ -0,0 +1,427 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	mongoDatabase        = "redirective"
+	mongoCollection      = "tracers"
+	mongoKeysCollection  = "api_keys"
+	mongoUsageCollection = "api_key_usage"
+	mongoConnectTimeout  = 10 * time.Second
+	mongoPingTimeout     = 2 * time.Second
+)
+
+const errorMessageInvalidInsertedID = "invalid inserted trace id type"
+
+// MongoRepository keeps traces in mongodb `redirective.tracers` collection,
+// api keys and their usage are kept in `api_keys` and `api_key_usage` collections
+type MongoRepository struct {
+	client *mongo.Client
+	col    *mongo.Collection
+	keys   *mongo.Collection
+	usage  *mongo.Collection
+}
+
+// mongoTrace is a trace document with mongodb object id
+type mongoTrace struct {
+	ID    primitive.ObjectID `bson:"_id"`
+	Trace `bson:",inline"`
+}
+
+// mongoKey is an api key document with mongodb object id
+type mongoKey struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	APIKey `bson:",inline"`
+}
+
+// mongoUsage is api key requests counter of the day, its id is `<key id>:<day>`
+type mongoUsage struct {
+	ID    string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+// NewMongoRepository connect to mongodb
+func NewMongoRepository(ctx context.Context, uri string) (*MongoRepository, error) {
+	connectCtx, cancel := context.WithTimeout(ctx, mongoConnectTimeout)
+	defer cancel()
+
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, fmt.Errorf("mongodb connection failed. %s", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, mongoPingTimeout)
+	defer cancel()
+
+	err = client.Ping(pingCtx, readpref.Primary())
+	if err != nil {
+		_ = client.Disconnect(ctx)
+
+		return nil, fmt.Errorf("mongodb ping failed. %s", err)
+	}
+
+	repo := &MongoRepository{
+		client: client,
+		col:    client.Database(mongoDatabase).Collection(mongoCollection),
+		keys:   client.Database(mongoDatabase).Collection(mongoKeysCollection),
+		usage:  client.Database(mongoDatabase).Collection(mongoUsageCollection),
+	}
+
+	indexCtx, cancel := context.WithTimeout(ctx, mongoConnectTimeout)
+	defer cancel()
+
+	if err := repo.ensureIndexes(indexCtx); err != nil {
+		_ = client.Disconnect(ctx)
+
+		return nil, fmt.Errorf("mongodb indexes creation failed. %s", err)
+	}
+
+	return repo, nil
+}
+
+// Save insert new trace or replace existing one
+func (mr *MongoRepository) Save(ctx context.Context, trace *Trace) error {
+	if trace.ID == "" {
+		res, err := mr.col.InsertOne(ctx, trace)
+		if err != nil {
+			return err
+		}
+
+		id, ok := res.InsertedID.(primitive.ObjectID)
+		if !ok {
+			return errors.New(errorMessageInvalidInsertedID)
+		}
+
+		trace.ID = id.Hex()
+
+		return nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(trace.ID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	_, err = mr.col.ReplaceOne(ctx, bson.M{"_id": id}, trace, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// Get load trace by id. Documents saved before trace status was introduced are returned as done
+func (mr *MongoRepository) Get(ctx context.Context, id string) (*Trace, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	doc := new(mongoTrace)
+
+	err = mr.col.FindOne(ctx, bson.M{"_id": objectID}).Decode(doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.trace(), nil
+}
+
+// List return traces matching the filter.
+// Creation time range is checked against object id time, so documents saved before
+// `created_at` field was introduced are filtered too
+func (mr *MongoRepository) List(ctx context.Context, filter *ListFilter) ([]*Trace, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+
+	query, err := mongoListQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	order := -1
+	if filter.oldestFirst() {
+		order = 1
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: order}})
+
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	cursor, err := mr.col.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	traces := make([]*Trace, 0)
+
+	for cursor.Next(ctx) {
+		doc := new(mongoTrace)
+		if err := cursor.Decode(doc); err != nil {
+			return nil, err
+		}
+
+		traces = append(traces, doc.trace())
+	}
+
+	return traces, cursor.Err()
+}
+
+// mongoListQuery convert the filter to mongodb query
+func mongoListQuery(filter *ListFilter) (bson.M, error) {
+	conditions := bson.A{}
+
+	if len(filter.Status) > 0 {
+		statuses := bson.A{}
+		for _, status := range filter.Status {
+			statuses = append(statuses, status)
+		}
+
+		// documents without status are finished sync traces
+		if filter.matchStatus(&Trace{Status: StatusDone}) {
+			statuses = append(statuses, nil)
+		}
+
+		conditions = append(conditions, bson.M{"status": bson.M{"$in": statuses}})
+	}
+
+	if filter.URL != "" {
+		conditions = append(conditions, bson.M{"url": filter.URL})
+	}
+
+	if filter.FinalURL != "" {
+		conditions = append(conditions, bson.M{"$expr": bson.M{"$eq": bson.A{
+			bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$redirects.to", -1}}, "$url"}},
+			filter.FinalURL,
+		}}})
+	}
+
+	if filter.Domain != "" {
+		re := primitive.Regex{Pattern: domainRegexp(filter.Domain), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"url": re},
+			bson.M{"redirects.from": re},
+			bson.M{"redirects.to": re},
+		}})
+	}
+
+	if filter.StatusCode != 0 {
+		conditions = append(conditions, bson.M{"redirects.status": filter.StatusCode})
+	}
+
+	if filter.Query != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"url": re},
+			bson.M{"redirects.from": re},
+			bson.M{"redirects.to": re},
+			bson.M{"error": re},
+		}})
+	}
+
+	idRange := bson.M{}
+
+	if !filter.CreatedFrom.IsZero() {
+		idRange["$gte"] = primitive.NewObjectIDFromTimestamp(filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		// object id keeps seconds only, so the whole last second is included
+		idRange["$lt"] = primitive.NewObjectIDFromTimestamp(filter.CreatedTo.Truncate(time.Second).Add(time.Second))
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := primitive.ObjectIDFromHex(filter.Cursor)
+		if err != nil {
+			return nil, ErrInvalidID
+		}
+
+		if filter.oldestFirst() {
+			conditions = append(conditions, bson.M{"_id": bson.M{"$gt": cursor}})
+		} else {
+			conditions = append(conditions, bson.M{"_id": bson.M{"$lt": cursor}})
+		}
+	}
+
+	if len(idRange) > 0 {
+		conditions = append(conditions, bson.M{"_id": idRange})
+	}
+
+	if len(conditions) == 0 {
+		return bson.M{}, nil
+	}
+
+	return bson.M{"$and": conditions}, nil
+}
+
+// ensureIndexes create indexes used by List and FindKey
+func (mr *MongoRepository) ensureIndexes(ctx context.Context) error {
+	_, err := mr.col.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "url", Value: 1}}},
+		{Keys: bson.D{{Key: "redirects.to", Value: 1}}},
+		{Keys: bson.D{{Key: "redirects.status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = mr.keys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	return err
+}
+
+// Delete remove trace by id
+func (mr *MongoRepository) Delete(ctx context.Context, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	res, err := mr.col.DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveKey insert new api key or replace existing one
+func (mr *MongoRepository) SaveKey(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = newID()
+	}
+
+	id, err := primitive.ObjectIDFromHex(key.ID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	_, err = mr.keys.ReplaceOne(ctx, bson.M{"_id": id}, key, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// GetKey load api key by id
+func (mr *MongoRepository) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrInvalidID
+	}
+
+	return mr.findKey(ctx, bson.M{"_id": objectID})
+}
+
+// FindKey load api key by secret hash
+func (mr *MongoRepository) FindKey(ctx context.Context, hash string) (*APIKey, error) {
+	return mr.findKey(ctx, bson.M{"hash": hash})
+}
+
+// ListKeys return all api keys, oldest first
+func (mr *MongoRepository) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	cursor, err := mr.keys.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := make([]*APIKey, 0)
+
+	for cursor.Next(ctx) {
+		doc := new(mongoKey)
+		if err := cursor.Decode(doc); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, doc.key())
+	}
+
+	return keys, cursor.Err()
+}
+
+// IncrementUsage increase api key requests counter of the day by n
+func (mr *MongoRepository) IncrementUsage(ctx context.Context, id string, day time.Time, n int) (int, error) {
+	if err := validateID(id); err != nil {
+		return 0, err
+	}
+
+	doc := new(mongoUsage)
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err := mr.usage.FindOneAndUpdate(ctx, bson.M{"_id": id + ":" + usageDay(day)}, bson.M{"$inc": bson.M{"count": n}}, opts).Decode(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	return doc.Count, nil
+}
+
+func (mr *MongoRepository) findKey(ctx context.Context, filter bson.M) (*APIKey, error) {
+	doc := new(mongoKey)
+
+	err := mr.keys.FindOne(ctx, filter).Decode(doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrKeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.key(), nil
+}
+
+// Close disconnect from mongodb
+func (mr *MongoRepository) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoConnectTimeout)
+	defer cancel()
+
+	return mr.client.Disconnect(ctx)
+}
+
+func (doc *mongoTrace) trace() *Trace {
+	trace := doc.Trace
+	trace.ID = doc.ID.Hex()
+
+	if trace.Status == "" {
+		trace.Status = StatusDone
+	}
+
+	if trace.CreatedAt.IsZero() {
+		trace.CreatedAt = doc.ID.Timestamp().UTC()
+		trace.UpdatedAt = trace.CreatedAt
+	}
+
+	return &trace
+}
+
+func (doc *mongoKey) key() *APIKey {
+	key := doc.APIKey
+	key.ID = doc.ID.Hex()
+
+	return &key
+}
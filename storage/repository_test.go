@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lroman242/redirective/tracer"
+)
+
+// testRepository run the same checks against every driver
+func testRepository(t *testing.T, repo TraceRepository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	trace := NewTrace("http://step0.test", "chrome", map[string]string{"width": "375"}, "")
+	trace.Status = StatusDone
+	trace.Redirects = []*tracer.JSONRedirect{{From: "http://step0.test", To: "http://step1.test", Status: 302}}
+	trace.Screenshot = "test.png"
+
+	if err := repo.Save(ctx, trace); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if trace.ID == "" {
+		t.Fatal("expect trace id to be set")
+	}
+
+	saved, err := repo.Get(ctx, trace.ID)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if saved.ID != trace.ID || saved.URL != trace.URL || saved.Screenshot != "test.png" || saved.Options["width"] != "375" {
+		t.Errorf("invalid saved trace %+v", saved)
+	}
+
+	if len(saved.Redirects) != 1 || saved.Redirects[0].To != "http://step1.test" {
+		t.Error("expect redirects to be saved")
+	}
+
+	// update
+	trace.Status = StatusFailed
+	trace.Error = "navigation failed"
+
+	if err := repo.Save(ctx, trace); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	saved, _ = repo.Get(ctx, trace.ID)
+	if saved.Status != StatusFailed || saved.Error != "navigation failed" {
+		t.Errorf("expect trace to be updated but get %s `%s`", saved.Status, saved.Error)
+	}
+
+	// list
+	queued := NewTrace("http://step1.test", "http", nil, "")
+	queued.CreatedAt = trace.CreatedAt.Add(time.Second)
+
+	if err := repo.Save(ctx, queued); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	traces, err := repo.List(ctx, nil)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(traces) != 2 || traces[0].ID != queued.ID {
+		t.Errorf("expect 2 traces, newest first")
+	}
+
+	traces, _ = repo.List(ctx, &ListFilter{Status: []string{StatusQueued, StatusRunning}})
+	if len(traces) != 1 || traces[0].ID != queued.ID {
+		t.Errorf("expect only queued trace to be listed")
+	}
+
+	traces, _ = repo.List(ctx, &ListFilter{Limit: 1})
+	if len(traces) != 1 {
+		t.Errorf("expect 1 trace but get %d", len(traces))
+	}
+
+	// delete
+	if err := repo.Delete(ctx, trace.ID); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if _, err := repo.Get(ctx, trace.ID); err != ErrNotFound {
+		t.Errorf("expect error: %s but got %v", ErrNotFound, err)
+	}
+
+	if err := repo.Delete(ctx, trace.ID); err != ErrNotFound {
+		t.Errorf("expect error: %s but got %v", ErrNotFound, err)
+	}
+
+	if _, err := repo.Get(ctx, "../../etc/passwd"); err != ErrInvalidID {
+		t.Errorf("expect error: %s but got %v", ErrInvalidID, err)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Errorf("unexpected error `%s`", err)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "redirective-storage")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	return dir
+}
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, NewMemoryRepository())
+}
+
+func TestFileRepository(t *testing.T) {
+	repo, err := NewFileRepository(filepath.Join(tempDir(t), "traces"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	testRepository(t, repo)
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	repo, err := NewSQLiteRepository(filepath.Join(tempDir(t), "redirective.db"))
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	testRepository(t, repo)
+}
+
+func TestNew(t *testing.T) {
+	dir := tempDir(t)
+
+	for driver, dsn := range map[string]string{
+		DriverMemory: "",
+		DriverFile:   filepath.Join(dir, "traces"),
+		DriverSQLite: filepath.Join(dir, "redirective.db"),
+	} {
+		repo, err := New(context.Background(), driver, dsn)
+		if err != nil {
+			t.Errorf("unexpected error `%s` for driver %s", err, driver)
+			continue
+		}
+
+		repo.Close()
+	}
+
+	if _, err := New(context.Background(), "redis", ""); err == nil {
+		t.Error("expect error for unknown driver")
+	}
+}
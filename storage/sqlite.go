@@ -0,0 +1,347 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// register sqlite3 database/sql driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS traces (
+	id         TEXT PRIMARY KEY,
+	status     TEXT NOT NULL,
+	url        TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS traces_status ON traces (status);
+CREATE INDEX IF NOT EXISTS traces_url ON traces (url);
+CREATE INDEX IF NOT EXISTS traces_created_at ON traces (created_at);
+CREATE TABLE IF NOT EXISTS api_keys (
+	id   TEXT PRIMARY KEY,
+	hash TEXT NOT NULL UNIQUE,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS api_key_usage (
+	key_id TEXT NOT NULL,
+	day    TEXT NOT NULL,
+	count  INTEGER NOT NULL,
+	PRIMARY KEY (key_id, day)
+);
+`
+
+// SQLiteRepository keeps traces and api keys in sqlite database. Searchable fields are stored in columns,
+// the whole trace (key) is stored as json in `data` column
+type SQLiteRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteRepository open (or create) sqlite database file and prepare the schema
+func NewSQLiteRepository(path string) (*SQLiteRepository, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create storage directory. %s", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, fmt.Errorf("cannot open sqlite database. %s", err)
+	}
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+
+		return nil, fmt.Errorf("cannot create sqlite schema. %s", err)
+	}
+
+	return &SQLiteRepository{db: db}, nil
+}
+
+// Save insert new trace or replace existing one
+func (sr *SQLiteRepository) Save(ctx context.Context, trace *Trace) error {
+	if trace.ID == "" {
+		trace.ID = newID()
+	} else if err := validateID(trace.ID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return err
+	}
+
+	_, err = sr.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO traces (id, status, url, created_at, data) VALUES (?, ?, ?, ?, ?)",
+		trace.ID, trace.Status, trace.URL, trace.CreatedAt.UnixNano(), string(data))
+
+	return err
+}
+
+// Get load trace by id
+func (sr *SQLiteRepository) Get(ctx context.Context, id string) (*Trace, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	var data string
+
+	err := sr.db.QueryRowContext(ctx, "SELECT data FROM traces WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeTrace(data)
+}
+
+// List return traces matching the filter. Status, url, creation time and cursor conditions
+// are applied by the query, the rest of them are checked while reading the rows
+func (sr *SQLiteRepository) List(ctx context.Context, filter *ListFilter) ([]*Trace, error) {
+	if filter == nil {
+		filter = &ListFilter{}
+	}
+
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+
+	if len(filter.Status) > 0 {
+		conditions = append(conditions, "status IN (?"+strings.Repeat(", ?", len(filter.Status)-1)+")")
+
+		for _, status := range filter.Status {
+			args = append(args, status)
+		}
+	}
+
+	if filter.URL != "" {
+		conditions = append(conditions, "url = ?")
+		args = append(args, filter.URL)
+	}
+
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UnixNano())
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.CreatedTo.UnixNano())
+	}
+
+	order := "DESC"
+
+	if filter.oldestFirst() {
+		order = "ASC"
+	}
+
+	if filter.Cursor != "" {
+		if filter.oldestFirst() {
+			conditions = append(conditions, "id > ?")
+		} else {
+			conditions = append(conditions, "id < ?")
+		}
+
+		args = append(args, filter.Cursor)
+	}
+
+	query := "SELECT data FROM traces"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY id " + order
+
+	rows, err := sr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	traces := make([]*Trace, 0)
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		trace, err := decodeTrace(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filter.Match(trace) {
+			continue
+		}
+
+		traces = append(traces, trace)
+
+		if filter.Limit > 0 && len(traces) == filter.Limit {
+			break
+		}
+	}
+
+	return traces, rows.Err()
+}
+
+// Delete remove trace by id
+func (sr *SQLiteRepository) Delete(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+
+	res, err := sr.db.ExecContext(ctx, "DELETE FROM traces WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Close close database connection
+func (sr *SQLiteRepository) Close() error {
+	return sr.db.Close()
+}
+
+// SaveKey insert new api key or replace existing one
+func (sr *SQLiteRepository) SaveKey(ctx context.Context, key *APIKey) error {
+	if key.ID == "" {
+		key.ID = newID()
+	} else if err := validateID(key.ID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = sr.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO api_keys (id, hash, data) VALUES (?, ?, ?)",
+		key.ID, key.Hash, string(data))
+
+	return err
+}
+
+// GetKey load api key by id
+func (sr *SQLiteRepository) GetKey(ctx context.Context, id string) (*APIKey, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+
+	return sr.queryKey(ctx, "SELECT data FROM api_keys WHERE id = ?", id)
+}
+
+// FindKey load api key by secret hash
+func (sr *SQLiteRepository) FindKey(ctx context.Context, hash string) (*APIKey, error) {
+	return sr.queryKey(ctx, "SELECT data FROM api_keys WHERE hash = ?", hash)
+}
+
+// ListKeys return all api keys, oldest first
+func (sr *SQLiteRepository) ListKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := sr.db.QueryContext(ctx, "SELECT data FROM api_keys ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]*APIKey, 0)
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		key, err := decodeKey(data)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// IncrementUsage increase api key requests counter of the day by n
+func (sr *SQLiteRepository) IncrementUsage(ctx context.Context, id string, day time.Time, n int) (int, error) {
+	if err := validateID(id); err != nil {
+		return 0, err
+	}
+
+	tx, err := sr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO api_key_usage (key_id, day, count) VALUES (?, ?, ?) "+
+			"ON CONFLICT (key_id, day) DO UPDATE SET count = count + excluded.count",
+		id, usageDay(day), n)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+
+	err = tx.QueryRowContext(ctx, "SELECT count FROM api_key_usage WHERE key_id = ? AND day = ?", id, usageDay(day)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+func (sr *SQLiteRepository) queryKey(ctx context.Context, query string, args ...interface{}) (*APIKey, error) {
+	var data string
+
+	err := sr.db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrKeyNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeKey(data)
+}
+
+func decodeTrace(data string) (*Trace, error) {
+	trace := new(Trace)
+	if err := json.Unmarshal([]byte(data), trace); err != nil {
+		return nil, fmt.Errorf("cannot decode trace. %s", err)
+	}
+
+	return trace, nil
+}
+
+func decodeKey(data string) (*APIKey, error) {
+	key := new(APIKey)
+	if err := json.Unmarshal([]byte(data), key); err != nil {
+		return nil, fmt.Errorf("cannot decode api key. %s", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/lroman242/redirective/tracer"
+)
+
+// Trace statuses
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Trace represent single trace request and its results
+type Trace struct {
+	ID           string                  `json:"id" bson:"-"`
+	Status       string                  `json:"status" bson:"status"`
+	URL          string                  `json:"url" bson:"url"`
+	Tracer       string                  `json:"tracer" bson:"tracer"`
+	Options      map[string]string       `json:"options,omitempty" bson:"options,omitempty"`
+	CallbackURL  string                  `json:"callback_url,omitempty" bson:"callback_url,omitempty"`
+	Redirects    []*tracer.JSONRedirect  `json:"redirects" bson:"redirects"`
+	Timing       *tracer.JSONChainTiming `json:"timing,omitempty" bson:"timing,omitempty"` // redirects chain timing summary
+	Truncated    bool                    `json:"truncated" bson:"truncated"`               // tracer stopped following the chain
+	LoopDetected bool                    `json:"loop_detected" bson:"loop_detected"`
+	Loop         *tracer.Loop            `json:"loop,omitempty" bson:"loop,omitempty"` // repeating segment of the chain
+	Screenshot   string                  `json:"screenshot" bson:"screenshot"`
+	Error        string                  `json:"error,omitempty" bson:"error,omitempty"`
+	ErrorCode    string                  `json:"error_code,omitempty" bson:"error_code,omitempty"` // machine readable failure reason
+	ScreenSize   *tracer.ScreenSize      `json:"screen_size,omitempty" bson:"screen_size,omitempty"`
+	UserAgent    string                  `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	Proxy        string                  `json:"proxy,omitempty" bson:"proxy,omitempty"` // proxy url without password
+	Geo          *tracer.Geo             `json:"geo,omitempty" bson:"geo,omitempty"`     // emulated location
+	Requester    string                  `json:"requester,omitempty" bson:"requester,omitempty"`
+	CreatedAt    time.Time               `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time               `json:"updated_at" bson:"updated_at"`
+	StartedAt    *time.Time              `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt   *time.Time              `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	Duration     int64                   `json:"duration_ms" bson:"duration_ms"` // time spent on tracing
+
+	// CallbackAttempts is the number of callback notifications of the finished job, including the one in progress
+	CallbackAttempts int `json:"callback_attempts,omitempty" bson:"callback_attempts,omitempty"`
+
+	// RawOptions are original values of redacted options (proxy with password, header and cookie values),
+	// they are kept in memory for the queued run only
+	RawOptions map[string]string `json:"-" bson:"-"`
+	// RedactedOptions marks the job which options are saved without secrets, it cannot be run once RawOptions are lost
+	RedactedOptions bool `json:"redacted_options,omitempty" bson:"redacted_options,omitempty"`
+}
+
+// NewTrace create new queued trace
+func NewTrace(url, tracerName string, options map[string]string, callbackURL string) *Trace {
+	now := time.Now().UTC()
+
+	return &Trace{
+		Status:      StatusQueued,
+		URL:         url,
+		Tracer:      tracerName,
+		Options:     options,
+		CallbackURL: callbackURL,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// Start mark trace as running
+func (t *Trace) Start() {
+	now := time.Now().UTC()
+
+	t.Status = StatusRunning
+	t.StartedAt = &now
+	t.FinishedAt = nil
+	t.Duration = 0
+	t.Error = ""
+	t.ErrorCode = ""
+	t.UpdatedAt = now
+}
+
+// Finish mark trace as done or failed (if err is not nil) and calculate its duration
+func (t *Trace) Finish(err error) {
+	now := time.Now().UTC()
+
+	if err != nil {
+		t.Status = StatusFailed
+		t.Error = err.Error()
+		t.ErrorCode = tracer.ErrorCode(err)
+	} else {
+		t.Status = StatusDone
+	}
+
+	if t.StartedAt != nil {
+		t.Duration = now.Sub(*t.StartedAt).Milliseconds()
+	}
+
+	t.FinishedAt = &now
+	t.UpdatedAt = now
+}
+
+// SetRedirects set traced redirects chain, its timing summary and loop
+func (t *Trace) SetRedirects(redirects []*tracer.Redirect) {
+	t.Redirects = tracer.NewJSONRedirects(redirects)
+	t.Timing = nil
+	t.Truncated = tracer.IsTruncated(redirects)
+	t.Loop = tracer.DetectLoop(redirects)
+	t.LoopDetected = t.Loop != nil
+
+	if len(redirects) > 0 {
+		t.Timing = tracer.NewJSONChainTiming(tracer.NewChainTiming(redirects))
+	}
+}
+
+// Finished check if trace is done or failed
+func (t *Trace) Finished() bool {
+	return t.Status == StatusDone || t.Status == StatusFailed
+}
+
+// FinalURL return url of the last document in the chain (start url if there were no redirects)
+func (t *Trace) FinalURL() string {
+	if len(t.Redirects) == 0 {
+		return t.URL
+	}
+
+	return t.Redirects[len(t.Redirects)-1].To
+}
+
+// urls return all urls of the chain including start one
+func (t *Trace) urls() []string {
+	urls := make([]string, 0, len(t.Redirects)*2+1)
+	urls = append(urls, t.URL)
+
+	for _, r := range t.Redirects {
+		urls = append(urls, r.From, r.To)
+	}
+
+	return urls
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrace_StartFinish(t *testing.T) {
+	trace := NewTrace("http://step0.test", "chrome", nil, "")
+
+	if trace.Finished() || trace.StartedAt != nil {
+		t.Fatal("expect new trace to be queued")
+	}
+
+	trace.Start()
+
+	if trace.Status != StatusRunning || trace.StartedAt == nil {
+		t.Fatalf("expect trace to be running with start time")
+	}
+
+	started := trace.StartedAt.Add(-50 * time.Millisecond)
+	trace.StartedAt = &started
+
+	trace.Finish(nil)
+
+	if trace.Status != StatusDone || trace.FinishedAt == nil || trace.Duration < 50 {
+		t.Errorf("expect trace to be done with duration >= 50ms but get %s %dms", trace.Status, trace.Duration)
+	}
+
+	trace.Start()
+	trace.Finish(errors.New("navigation failed"))
+
+	if trace.Status != StatusFailed || trace.Error != "navigation failed" {
+		t.Errorf("expect trace to fail with `navigation failed` but get %s `%s`", trace.Status, trace.Error)
+	}
+}
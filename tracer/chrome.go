@@ -3,10 +3,14 @@ package tracer
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/opentracing/opentracing-go/log"
@@ -15,6 +19,7 @@ import (
 
 const setCookieHeaderName = "set-cookie"
 const documentParamName = "Document"
+const scriptInitiator = "script"
 
 const (
 	errorMessageInvalidMainFrameID                      = "invalid mainframe id"
@@ -44,13 +49,27 @@ type ChromeRemoteDebuggerInterface interface {
 	SetVisibleSize(width, height int) error
 	SaveScreenshot(filename string, perm os.FileMode, quality int, fromSurface bool) error
 	SetUserAgent(userAgent string) error
+	Evaluate(expr string, options ...godet.EvaluateOption) (interface{}, error)
+	SendRequest(method string, params godet.Params) (map[string]interface{}, error)
 }
 
-// ChromeTracer represent tracer based on google chrome debugging tools
+// ChromeTracer represent tracer based on google chrome debugging tools.
+// Every trace (and screenshot) runs in its own browser context, so concurrent traces
+// don't share cookies, cache or storage
 type ChromeTracer struct {
 	instance               ChromeRemoteDebuggerInterface
 	size                   *ScreenSize
 	screenshotsStoragePath string
+	waitStrategy           *WaitStrategy
+	networkLog             bool
+	frameTree              bool
+	hopScreenshots         bool
+	device                 *Device
+	request                *RequestOptions
+	proxy                  *url.URL
+	geo                    *Geo
+	maxHops                int
+	policy                 *URLPolicy
 }
 
 // NewChromeTracer create new chrome tracer instance
@@ -59,36 +78,130 @@ func NewChromeTracer(chrome *godet.RemoteDebugger, size *ScreenSize, screenshots
 		instance:               chrome,
 		size:                   size,
 		screenshotsStoragePath: screenshotsStoragePath,
+		waitStrategy:           DefaultWaitStrategy(),
+		maxHops:                DefaultMaxHops,
 	}
 }
 
-func (ct *ChromeTracer) traceURL(url *url.URL, redirects, responses *map[string][]godet.Params, fileName string) (string, error) {
-	frameID := ""
+// SetWaitStrategy change the way tracer waits for the page to settle before capturing results
+func (ct *ChromeTracer) SetWaitStrategy(waitStrategy *WaitStrategy) {
+	ct.waitStrategy = waitStrategy
+}
 
-	err := ct.instance.EnableRequestInterception(true)
-	if err != nil {
-		return frameID, fmt.Errorf("`EnableRequestInterception` failed. %s", err)
+// SetDevice enable device emulation (viewport, pixel ratio, touch and user agent).
+// Tracer screen size is replaced with device viewport
+func (ct *ChromeTracer) SetDevice(device *Device) {
+	ct.device = device
+	ct.size = device.ScreenSize()
+}
+
+// SetRequestOptions set custom user agent, referer, Accept-Language, headers and cookies of the traced url request
+func (ct *ChromeTracer) SetRequestOptions(request *RequestOptions) {
+	ct.request = request
+}
+
+// SetProxy route traced requests through the proxy (http, https or socks5 without authentication).
+// Proxy is applied to the browser context of the trace, so concurrent traces could use different proxies
+func (ct *ChromeTracer) SetProxy(proxy *url.URL) error {
+	if proxy != nil && proxy.Scheme == ProxySchemeSOCKS5 && proxy.User != nil {
+		return errors.New(errorMessageSOCKS5AuthNotSupported)
 	}
 
+	ct.proxy = proxy
+
+	return nil
+}
+
+// SetGeo enable emulation of geolocation api position, timezone and locale
+func (ct *ChromeTracer) SetGeo(geo *Geo) {
+	ct.geo = geo
+}
+
+// SetMaxHops set the number of followed main frame hops, zero means there is no limit
+func (ct *ChromeTracer) SetMaxHops(maxHops int) {
+	ct.maxHops = maxHops
+}
+
+// SetURLPolicy check the traced url and every request of the page (redirects, frames, sub-resources)
+// with the policy, blocked requests are failed by the browser
+func (ct *ChromeTracer) SetURLPolicy(policy *URLPolicy) {
+	ct.policy = policy
+}
+
+// SetNetworkLog enable or disable capturing of sub-resources requests (images, scripts, xhr, beacons, iframes)
+// made by every document of the chain
+func (ct *ChromeTracer) SetNetworkLog(enabled bool) {
+	ct.networkLog = enabled
+}
+
+// SetFrameTree enable or disable tracing of child frames (iframes). Redirects chain of every child frame
+// is reported under the hop where the frame was created
+func (ct *ChromeTracer) SetFrameTree(enabled bool) {
+	ct.frameTree = enabled
+}
+
+// SetHopScreenshots enable or disable screenshots of every document which was rendered
+// before client side redirect (javascript, meta refresh...), final page screenshot is captured anyway
+func (ct *ChromeTracer) SetHopScreenshots(enabled bool) {
+	ct.hopScreenshots = enabled
+}
+
+// listen register devtools events callbacks which collect trace data into `events`
+func (ct *ChromeTracer) listen(events *traceEvents) {
 	ct.instance.CallbackEvent("Network.requestWillBeSent", func(params godet.Params) {
-		if _, ok := params["redirectResponse"]; ok && params["type"] == documentParamName {
-			(*redirects)[params["frameId"].(string)] = append((*redirects)[params["frameId"].(string)], params)
+		events.requestStarted(params)
+		ct.checkRemoteAddress(events, godet.Params(params.Map("redirectResponse")))
+
+		if params["type"] == documentParamName {
+			documentIndex, stopped := events.addRequest(params)
+			if stopped {
+				ct.stopNavigation()
+			}
+
+			// the new document request isn't a server side redirect, so the previous document was rendered
+			if _, ok := params["redirectResponse"]; !ok && documentIndex > 0 {
+				ct.captureHopScreenshot(events, documentIndex-1)
+			}
 		}
+
+		events.addResource(params)
 	})
 	ct.instance.CallbackEvent("Network.responseReceived", func(params godet.Params) {
+		ct.checkRemoteAddress(events, godet.Params(params.Map("response")))
+
 		if params["type"] == documentParamName {
-			(*responses)[params["frameId"].(string)] = append((*responses)[params["frameId"].(string)], params)
+			events.addResponse(params)
 		}
+
+		events.resourceResponse(params)
 	})
+	ct.instance.CallbackEvent("Network.loadingFinished", events.loadingFinished)
+	ct.instance.CallbackEvent("Network.loadingFailed", events.loadingFailed)
+	ct.instance.CallbackEvent("Page.loadEventFired", events.loadFired)
+	ct.instance.CallbackEvent("Page.frameScheduledNavigation", events.addNavigation)
+	ct.instance.CallbackEvent("Page.frameRequestedNavigation", events.addNavigation)
+	ct.instance.CallbackEvent("Page.windowOpen", events.addWindowOpen)
+	ct.instance.CallbackEvent("Page.frameAttached", events.frameAttached)
+}
 
-	// create new tab
-	tab, _ := ct.instance.NewTab("")
-	defer func(tab *godet.Tab) {
-		err = ct.instance.CloseTab(tab)
-		if err != nil {
-			log.Error(fmt.Errorf("`CloseTab` failed. %s", err))
-		}
-	}(tab)
+func (ct *ChromeTracer) traceURL(url *url.URL, events *traceEvents, fileName string) (string, error) {
+	frameID := ""
+
+	err := ct.instance.EnableRequestInterception(true)
+	if err != nil {
+		return frameID, fmt.Errorf("`EnableRequestInterception` failed. %s", err)
+	}
+
+	ct.listen(events)
+
+	// create new tab in isolated browser context
+	it, err := ct.openIsolatedTab()
+	if err != nil {
+		return frameID, err
+	}
+	defer ct.closeIsolatedTab(it)
+	// event handlers send devtools requests to the tab
+	defer events.waitHandlers()
 
 	err = ct.instance.NetworkEvents(true)
 	if err != nil {
@@ -96,7 +209,7 @@ func (ct *ChromeTracer) traceURL(url *url.URL, redirects, responses *map[string]
 	}
 
 	// navigate in existing tab
-	err = ct.instance.ActivateTab(tab)
+	err = ct.instance.ActivateTab(it.tab)
 	if err != nil {
 		return frameID, fmt.Errorf("`ActivateTab` failed. %s", err)
 	}
@@ -107,40 +220,82 @@ func (ct *ChromeTracer) traceURL(url *url.URL, redirects, responses *map[string]
 		return frameID, fmt.Errorf("`AllEvents` failed. %s", err)
 	}
 
-	err = ct.instance.SetDeviceMetricsOverride(ct.size.Width, ct.size.Height, 0, false, false)
+	err = ct.listenRequests(events)
 	if err != nil {
-		return frameID, fmt.Errorf("set screen size error: %s", err)
+		return frameID, err
 	}
 
-	err = ct.instance.SetVisibleSize(ct.size.Width, ct.size.Height)
+	err = ct.emulate(ct.size)
 	if err != nil {
-		return frameID, fmt.Errorf("set visibility size error: %s", err)
+		return frameID, err
 	}
 
-	frameID, err = ct.instance.Navigate(url.String())
+	err = ct.emulateGeo(it.contextID)
 	if err != nil {
-		return frameID, fmt.Errorf("`Navigate` failed. %s", err)
+		return frameID, err
 	}
 
-	time.Sleep(time.Second * 5)
+	err = ct.applyRequestOptions(url)
+	if err != nil {
+		return frameID, err
+	}
+
+	frameID, err = ct.navigate(url)
+	if blockedErr := events.blockedError(); blockedErr != nil {
+		return frameID, blockedErr
+	}
 
-	// take a screenshot
-	err = ct.instance.SaveScreenshot(ct.screenshotsStoragePath+fileName, 0644, 100, true)
 	if err != nil {
-		return frameID, fmt.Errorf("cannot capture screenshot: %s", err)
+		return frameID, fmt.Errorf("`Navigate` failed. %w", classifyNavigationError(err))
+	}
+
+	events.setMainFrameID(frameID)
+
+	err = ct.wait(events)
+
+	// hop screenshots should be saved before the tab is closed
+	events.waitCaptures()
+	events.waitHandlers()
+
+	if blockedErr := events.blockedError(); blockedErr != nil {
+		return frameID, blockedErr
+	}
+
+	if err != nil {
+		return frameID, fmt.Errorf("wait failed. %s", err)
+	}
+
+	// take a screenshot, skipped when file name isn't provided
+	if fileName != "" {
+		err = ct.instance.SaveScreenshot(ct.screenshotsStoragePath+fileName, 0644, 100, true)
+		if err != nil {
+			return frameID, fmt.Errorf("cannot capture screenshot: %s", err)
+		}
 	}
 
 	return frameID, nil
 }
 
-// Trace parse redirect trace path for provided url
+// Trace parse redirect trace path for provided url.
+// Final page screenshot is saved only if fileName is not empty.
+// Both server side (3xx) and client side (meta refresh, javascript, form submission,
+// window.open) redirects of the main frame are reported. Looped chain is returned with ErrRedirectLoop
 func (ct *ChromeTracer) Trace(url *url.URL, fileName string) ([]*Redirect, error) {
 	var redirects []*Redirect
 
-	rawRedirects := make(map[string][]godet.Params)
-	rawResponses := make(map[string][]godet.Params)
+	if err := ct.policy.Check(url); err != nil {
+		return redirects, err
+	}
+
+	events := newTraceEvents()
+	events.networkLog = ct.networkLog
+	events.guard = newHopsGuard(ct.maxHops)
+
+	if ct.hopScreenshots {
+		events.hopScreenshotsFileName = fileName
+	}
 
-	frameID, err := ct.traceURL(url, &rawRedirects, &rawResponses, fileName)
+	frameID, err := ct.traceURL(url, events, fileName)
 	if err != nil {
 		return redirects, err
 	}
@@ -149,76 +304,583 @@ func (ct *ChromeTracer) Trace(url *url.URL, fileName string) ([]*Redirect, error
 		return redirects, errors.New(errorMessageInvalidMainFrameID)
 	}
 
-	if len(rawRedirects) == 0 {
+	events.Lock()
+	defer events.Unlock()
+
+	hasFrames := ct.frameTree && len(events.childFrames(frameID)) > 0
+
+	if len(events.requests[frameID]) <= 1 && len(events.windowOpens) == 0 && !hasFrames {
 		return redirects, nil
 	}
 
-	if rawRedirects, ok := rawRedirects[frameID]; ok {
-		for _, rawRedirect := range rawRedirects {
-			redirect, err := parseRedirectFromRaw(rawRedirect)
+	// truncated hop could be stopped before any response is received
+	if len(events.responses[frameID]) == 0 && !events.stopped {
+		return redirects, errors.New(errorMessageNoResponseFromMainFrame)
+	}
+
+	redirects, documents, err := parseFrameRedirectsFromRaw(events, frameID)
+	if err != nil {
+		return redirects, err
+	}
+
+	redirects[len(redirects)-1].ScreenshotFileName = fileName
+
+	if ct.frameTree {
+		err = attachChildFrames(events, frameID, redirects, documents)
+		if err != nil {
+			return redirects, err
+		}
+	}
+
+	setTimingOffsets(redirects)
+
+	return redirects, loopError(redirects)
+}
+
+// stopNavigation stop loading of the main frame document and disable javascript,
+// so the page can't navigate further when the chain is truncated
+func (ct *ChromeTracer) stopNavigation() {
+	_, err := ct.instance.SendRequest("Page.stopLoading", godet.Params{})
+	if err != nil {
+		log.Error(fmt.Errorf("`Page.stopLoading` failed. %s", err))
+	}
+
+	_, err = ct.instance.SendRequest("Emulation.setScriptExecutionDisabled", godet.Params{"value": true})
+	if err != nil {
+		log.Error(fmt.Errorf("`Emulation.setScriptExecutionDisabled` failed. %s", err))
+	}
+}
+
+// checkRemoteAddress check address chrome connected to with url policy. Chrome resolves hosts on its own,
+// so the checked host could be resolved to another address (dns rebinding). The response is already loaded,
+// so any violation fails the trace and stops the page. Proxy address is checked before the trace
+func (ct *ChromeTracer) checkRemoteAddress(events *traceEvents, response godet.Params) {
+	if ct.policy == nil || ct.proxy != nil {
+		return
+	}
+
+	// responses served from cache or by service worker have no remote address
+	address := response.String("remoteIPAddress")
+	if address == "" {
+		return
+	}
+
+	responseURL, err := url.Parse(response.String("url"))
+	if err != nil {
+		return
+	}
+
+	err = ct.policy.checkRemoteAddress(responseURL, address)
+	if err != nil {
+		events.blockTrace(err)
+		events.handle(ct.stopNavigation)
+	}
+}
+
+// captureHopScreenshot save screenshot of the main frame document before navigating away.
+// Screenshot is captured asynchronously, so events processing isn't blocked
+func (ct *ChromeTracer) captureHopScreenshot(events *traceEvents, documentIndex int) {
+	if events.hopScreenshotsFileName == "" {
+		return
+	}
+
+	if !events.startCapture() {
+		return
+	}
+
+	fileName := hopScreenshotFileName(events.hopScreenshotsFileName, documentIndex)
+
+	go func() {
+		defer events.captures.Done()
+
+		// hop screenshot is optional, so the trace isn't failed if it can't be captured
+		if err := ct.instance.SaveScreenshot(ct.screenshotsStoragePath+fileName, 0644, 100, true); err == nil {
+			events.addHopScreenshot(documentIndex, fileName)
+		}
+	}()
+}
+
+// hopScreenshotFileName create hop screenshot file name from the final one (`name.png` => `name-hop1.png`)
+func hopScreenshotFileName(fileName string, documentIndex int) string {
+	ext := filepath.Ext(fileName)
+
+	return fmt.Sprintf("%s-hop%d%s", strings.TrimSuffix(fileName, ext), documentIndex+1, ext)
+}
+
+// parseFrameRedirectsFromRaw create redirects chain of the frame. Hops which were made by the document
+// loaded with frame request are returned as well (grouped by request index), final response is the last hop.
+// Caller should hold events lock
+func parseFrameRedirectsFromRaw(events *traceEvents, frameID string) ([]*Redirect, map[int]*Redirect, error) {
+	var redirects []*Redirect
+
+	documents := make(map[int]*Redirect)
+	mainFrame := frameID == events.mainFrameID
+	rawRequests := events.requests[frameID]
+	rawResponses := events.responses[frameID]
+
+	for i, rawRequest := range rawRequests {
+		if _, ok := rawRequest["redirectResponse"]; ok {
+			redirect, err := parseRedirectFromRaw(rawRequest)
 			if err != nil {
-				return redirects, fmt.Errorf("an error during parsing redirects. %s", err)
+				return redirects, documents, fmt.Errorf("an error during parsing redirects. %s", err)
 			}
 
+			redirects = append(redirects, redirect)
+		} else if i > 0 {
+			redirect, err := parseClientRedirectFromRaw(rawRequests[i-1], rawRequest, rawResponses, events.navigations[frameID])
+			if err != nil {
+				return redirects, documents, fmt.Errorf("an error during parsing client redirects. %s", err)
+			}
+
+			// previous document was loaded completely before client side redirect
+			redirect.Timing.finish(events.finished[rawRequests[i-1].String("requestId")])
+			documents[i-1] = redirect
 			redirects = append(redirects, redirect)
 		}
-	} /* else {
-		return redirects, errors.New("No redirects found for mainframe")
-	}*/
 
-	if rawRespons, ok := rawResponses[frameID]; ok {
-		response, err := pareseMainResponseFromRaw(rawRespons[len(rawRespons)-1])
+		if !mainFrame {
+			continue
+		}
+
+		// window.open calls made by the document loaded with this request
+		for _, rawWindowOpen := range events.windowOpens {
+			if rawWindowOpen.Int(openerRequestIndexParamName) != i {
+				continue
+			}
+
+			redirect, err := parseWindowOpenFromRaw(rawRequest, rawWindowOpen)
+			if err != nil {
+				return redirects, documents, fmt.Errorf("an error during parsing window.open redirects. %s", err)
+			}
+
+			redirects = append(redirects, redirect)
+		}
+	}
+
+	// truncated main frame chain ends with the hop which wasn't followed
+	if mainFrame && events.stopped && len(redirects) > 0 {
+		redirects[len(redirects)-1].Truncated = true
+	}
+
+	// child frame document could be blocked, so there is no final response.
+	// The last response of truncated chain doesn't belong to the final document
+	if len(rawResponses) > 0 && !(mainFrame && events.stopped) {
+		rawResponse := rawResponses[len(rawResponses)-1]
+
+		response, err := pareseMainResponseFromRaw(rawResponse)
 		if err != nil {
-			return redirects, fmt.Errorf("an error during parsing response. %s", err)
+			return redirects, documents, fmt.Errorf("an error during parsing response. %s", err)
 		}
 
-		response.ScreenshotFileName = fileName
+		response.Timing.finish(events.finished[rawResponse.String("requestId")])
+		documents[len(rawRequests)-1] = response
 		redirects = append(redirects, response)
-	} else {
-		return redirects, errors.New(errorMessageNoResponseFromMainFrame)
 	}
 
-	return redirects, nil
+	if mainFrame {
+		for index, hop := range documents {
+			documentURL := godet.Params(rawRequests[index].Map("request")).String("url")
+			hop.Resources = events.documentResources(index, documentURL)
+
+			if screenshot, ok := events.hopScreenshots[index]; ok {
+				hop.ScreenshotFileName = screenshot
+			}
+		}
+	}
+
+	return redirects, documents, nil
+}
+
+// attachChildFrames add redirects chains of frames created by the parent frame documents to the hops
+// of these documents (recursively). Caller should hold events lock
+func attachChildFrames(events *traceEvents, parentID string, parentRedirects []*Redirect, documents map[int]*Redirect) error {
+	for _, child := range events.childFrames(parentID) {
+		redirects, childDocuments, err := parseFrameRedirectsFromRaw(events, child.id)
+		if err != nil {
+			return fmt.Errorf("an error during parsing frame %s. %s", child.id, err)
+		}
+
+		if len(redirects) == 0 {
+			continue
+		}
+
+		err = attachChildFrames(events, child.id, redirects, childDocuments)
+		if err != nil {
+			return err
+		}
+
+		// frame is attached to the final hop if its document is unknown
+		hop, ok := documents[child.documentIndex]
+		if !ok && len(parentRedirects) > 0 {
+			hop = parentRedirects[len(parentRedirects)-1]
+		}
+
+		if hop != nil {
+			hop.Frames = append(hop.Frames, &Frame{ID: child.id, Redirects: redirects})
+		}
+	}
+
+	return nil
+}
+
+// emulate apply device emulation to the active tab, desktop device is emulated if no device is set.
+// Provided screen size is used as viewport
+func (ct *ChromeTracer) emulate(size *ScreenSize) error {
+	device := ct.device
+	if device == nil {
+		device = DefaultDevice(size)
+	}
+
+	err := ct.instance.SetDeviceMetricsOverride(size.Width, size.Height, device.ScaleFactor, device.Mobile, false)
+	if err != nil {
+		return fmt.Errorf("set screen size error: %s", err)
+	}
+
+	err = ct.instance.SetVisibleSize(size.Width, size.Height)
+	if err != nil {
+		return fmt.Errorf("set visibility size error: %s", err)
+	}
+
+	if device.Touch {
+		_, err = ct.instance.SendRequest("Emulation.setTouchEmulationEnabled", godet.Params{
+			"enabled":        true,
+			"maxTouchPoints": maxTouchPoints,
+		})
+		if err != nil {
+			return fmt.Errorf("`Emulation.setTouchEmulationEnabled` failed. %s", err)
+		}
+	}
+
+	// custom user agent takes precedence over device one
+	userAgent := device.UserAgent
+	if ct.request != nil && ct.request.UserAgent != "" {
+		userAgent = ct.request.UserAgent
+	}
+
+	if userAgent != "" {
+		_, err = ct.instance.SendRequest("Emulation.setUserAgentOverride", godet.Params{
+			"userAgent": userAgent,
+			"platform":  device.Platform,
+		})
+		if err != nil {
+			return fmt.Errorf("`Emulation.setUserAgentOverride` failed. %s", err)
+		}
+	}
+
+	return nil
+}
+
+// applyRequestOptions set extra headers and cookies of the active tab before navigation
+func (ct *ChromeTracer) applyRequestOptions(target *url.URL) error {
+	if ct.request.isEmpty() {
+		return nil
+	}
+
+	if headers := ct.request.extraHeaders(); len(headers) > 0 {
+		_, err := ct.instance.SendRequest("Network.setExtraHTTPHeaders", godet.Params{
+			"headers": headers,
+		})
+		if err != nil {
+			return fmt.Errorf("`Network.setExtraHTTPHeaders` failed. %s", err)
+		}
+	}
+
+	if len(ct.request.Cookies) > 0 {
+		_, err := ct.instance.SendRequest("Network.setCookies", godet.Params{
+			"cookies": ct.request.cookieParams(target),
+		})
+		if err != nil {
+			return fmt.Errorf("`Network.setCookies` failed. %s", err)
+		}
+	}
+
+	return nil
+}
+
+// navigate open url in the active tab and return main frame id.
+// Custom referer is sent with navigation request only
+func (ct *ChromeTracer) navigate(target *url.URL) (string, error) {
+	if ct.request == nil || ct.request.Referer == "" {
+		return ct.instance.Navigate(target.String())
+	}
+
+	res, err := ct.instance.SendRequest("Page.navigate", godet.Params{
+		"url":      target.String(),
+		"referrer": ct.request.Referer,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if errorText, ok := res["errorText"].(string); ok && errorText != "" {
+		return "", godet.NavigationError(errorText)
+	}
+
+	frameID, _ := res["frameId"].(string)
+
+	return frameID, nil
+}
+
+// emulateGeo apply geo settings to the active tab. Geolocation permission is granted
+// to the browser context of the trace, so pages don't wait for the permission prompt
+func (ct *ChromeTracer) emulateGeo(contextID string) error {
+	if ct.geo.IsEmpty() {
+		return nil
+	}
+
+	if ct.geo.Location != nil {
+		_, err := ct.instance.SendRequest("Browser.grantPermissions", godet.Params{
+			"permissions":      []string{"geolocation"},
+			"browserContextId": contextID,
+		})
+		if err != nil {
+			return fmt.Errorf("`Browser.grantPermissions` failed. %s", err)
+		}
+
+		_, err = ct.instance.SendRequest("Emulation.setGeolocationOverride", godet.Params{
+			"latitude":  ct.geo.Location.Latitude,
+			"longitude": ct.geo.Location.Longitude,
+			"accuracy":  ct.geo.Location.Accuracy,
+		})
+		if err != nil {
+			return fmt.Errorf("`Emulation.setGeolocationOverride` failed. %s", err)
+		}
+	}
+
+	if ct.geo.Timezone != "" {
+		_, err := ct.instance.SendRequest("Emulation.setTimezoneOverride", godet.Params{
+			"timezoneId": ct.geo.Timezone,
+		})
+		if err != nil {
+			return fmt.Errorf("`Emulation.setTimezoneOverride` failed. %s", err)
+		}
+	}
+
+	if ct.geo.Locale != "" {
+		_, err := ct.instance.SendRequest("Emulation.setLocaleOverride", godet.Params{
+			"locale": ct.geo.Locale,
+		})
+		if err != nil {
+			return fmt.Errorf("`Emulation.setLocaleOverride` failed. %s", err)
+		}
+	}
+
+	return nil
+}
+
+// listenRequests pause every request of the tab with `Fetch` domain if url policy is set
+// or proxy requires authentication. Requests blocked by the policy are failed, the rest of them
+// are continued immediately. Proxy authentication challenges are answered with proxy credentials
+func (ct *ChromeTracer) listenRequests(events *traceEvents) error {
+	proxyAuth := ct.proxy != nil && ct.proxy.User != nil
+
+	if !proxyAuth && ct.policy == nil {
+		return nil
+	}
+
+	checks := &requestChecks{results: make(map[string]error)}
+
+	ct.instance.CallbackEvent("Fetch.requestPaused", func(params godet.Params) {
+		// main frame document is detected in the events goroutine, where requests are ordered
+		mainDocument := events.isMainDocument(params)
+
+		events.handle(func() {
+			ct.continuePausedRequest(params, mainDocument, events, checks)
+		})
+	})
+
+	if proxyAuth {
+		ct.listenProxyAuth(events)
+	}
+
+	_, err := ct.instance.SendRequest("Fetch.enable", godet.Params{
+		"handleAuthRequests": proxyAuth,
+		"patterns":           []godet.Params{{"urlPattern": "*"}},
+	})
+	if err != nil {
+		return fmt.Errorf("`Fetch.enable` failed. %s", err)
+	}
+
+	return nil
+}
+
+// continuePausedRequest fail the request blocked by url policy or continue it.
+// Blocked main frame document fails the trace
+func (ct *ChromeTracer) continuePausedRequest(params godet.Params, mainDocument bool, events *traceEvents, checks *requestChecks) {
+	err := ct.checkRequest(godet.Params(params.Map("request")).String("url"), checks)
+	if err != nil {
+		if mainDocument {
+			events.blockTrace(err)
+		}
+
+		_, err = ct.instance.SendRequest("Fetch.failRequest", godet.Params{
+			"requestId":   params.String("requestId"),
+			"errorReason": "BlockedByClient",
+		})
+		if err != nil {
+			log.Error(fmt.Errorf("`Fetch.failRequest` failed. %s", err))
+		}
+
+		return
+	}
+
+	_, err = ct.instance.SendRequest("Fetch.continueRequest", godet.Params{
+		"requestId": params.String("requestId"),
+	})
+	if err != nil {
+		log.Error(fmt.Errorf("`Fetch.continueRequest` failed. %s", err))
+	}
+}
+
+// requestChecks cache url policy violations of the trace by scheme and host
+type requestChecks struct {
+	sync.Mutex
+	results map[string]error
+}
+
+// checkRequest check request url with the policy right before it is continued. Allowed hosts are resolved
+// and checked for every request, so the host resolved to another address later is blocked. Violations are cached
+// by scheme and host
+func (ct *ChromeTracer) checkRequest(rawURL string, checks *requestChecks) error {
+	if ct.policy == nil {
+		return nil
+	}
+
+	requestURL, err := url.Parse(rawURL)
+	if err != nil {
+		return wrapError(ErrInvalidURL, err)
+	}
+
+	key := requestURL.Scheme + "://" + requestURL.Hostname()
+
+	checks.Lock()
+	err, ok := checks.results[key]
+	checks.Unlock()
+
+	if ok {
+		return err
+	}
+
+	err = ct.policy.Check(requestURL)
+	if err != nil {
+		checks.Lock()
+		checks.results[key] = err
+		checks.Unlock()
+	}
+
+	return err
+}
+
+// listenProxyAuth answer proxy authentication challenges with proxy credentials
+func (ct *ChromeTracer) listenProxyAuth(events *traceEvents) {
+	ct.instance.CallbackEvent("Fetch.authRequired", func(params godet.Params) {
+		events.handle(func() {
+			ct.continueWithAuth(params)
+		})
+	})
+}
+
+// continueWithAuth answer authentication challenge, only proxy credentials are provided
+func (ct *ChromeTracer) continueWithAuth(params godet.Params) {
+	challenge := godet.Params(params.Map("authChallenge"))
+	response := godet.Params{"response": "Default"}
+
+	// server authentication is left to the page
+	if challenge.String("source") == "Proxy" {
+		password, _ := ct.proxy.User.Password()
+		response = godet.Params{
+			"response": "ProvideCredentials",
+			"username": ct.proxy.User.Username(),
+			"password": password,
+		}
+	}
+
+	_, err := ct.instance.SendRequest("Fetch.continueWithAuth", godet.Params{
+		"requestId":             params.String("requestId"),
+		"authChallengeResponse": response,
+	})
+	if err != nil {
+		log.Error(fmt.Errorf("`Fetch.continueWithAuth` failed. %s", err))
+	}
 }
 
 // Screenshot function makes a final page screen capture
 func (ct *ChromeTracer) Screenshot(url *url.URL, size *ScreenSize, fileName string) error {
+	if err := ct.policy.Check(url); err != nil {
+		return err
+	}
+
 	err := ct.instance.EnableRequestInterception(true)
 	if err != nil {
 		return fmt.Errorf("`EnableRequestInterception` failed. %s", err)
 	}
 
-	// create new tab
-	tab, _ := ct.instance.NewTab(url.String())
-	defer func(tab *godet.Tab) {
-		err = ct.instance.CloseTab(tab)
-		if err != nil {
-			log.Error(fmt.Errorf("`CloseTab` failed. %s", err))
-		}
-	}(tab)
+	events := newTraceEvents()
+	ct.listen(events)
+
+	// create new tab in isolated browser context
+	it, err := ct.openIsolatedTab()
+	if err != nil {
+		return err
+	}
+	defer ct.closeIsolatedTab(it)
+	// event handlers send devtools requests to the tab
+	defer events.waitHandlers()
 
 	// navigate in existing tab
-	err = ct.instance.ActivateTab(tab)
+	err = ct.instance.ActivateTab(it.tab)
 	if err != nil {
 		return fmt.Errorf("`ActivateTab` failed. %s", err)
 	}
 
-	err = ct.instance.SetDeviceMetricsOverride(size.Width, size.Height, 0, false, false)
+	// enable events of the active tab, they are used by wait strategy
+	err = ct.instance.AllEvents(true)
 	if err != nil {
-		return fmt.Errorf("set screen size error: %s", err)
+		return fmt.Errorf("`AllEvents` failed. %s", err)
 	}
 
-	err = ct.instance.SetVisibleSize(size.Width, size.Height)
+	err = ct.listenRequests(events)
 	if err != nil {
-		return fmt.Errorf("set visibility size error: %s", err)
+		return err
+	}
+
+	err = ct.emulate(size)
+	if err != nil {
+		return err
+	}
+
+	err = ct.emulateGeo(it.contextID)
+	if err != nil {
+		return err
+	}
+
+	err = ct.applyRequestOptions(url)
+	if err != nil {
+		return err
+	}
+
+	frameID, err := ct.navigate(url)
+	if blockedErr := events.blockedError(); blockedErr != nil {
+		return blockedErr
 	}
 
-	_, err = ct.instance.Navigate(url.String())
 	if err != nil {
-		return fmt.Errorf("`Navigate` failed. %s", err)
+		return fmt.Errorf("`Navigate` failed. %w", classifyNavigationError(err))
 	}
 
-	time.Sleep(time.Second * 5)
+	events.setMainFrameID(frameID)
+
+	err = ct.wait(events)
+
+	events.waitHandlers()
+
+	if blockedErr := events.blockedError(); blockedErr != nil {
+		return blockedErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("wait failed. %s", err)
+	}
 
 	// take a screenshot
 	err = ct.instance.SaveScreenshot(ct.screenshotsStoragePath+fileName, 0644, 100, true)
@@ -285,7 +947,179 @@ func parseRedirectFromRaw(rawRedirect godet.Params) (*Redirect, error) {
 
 	initiator := rawRedirect.Map("initiator")["type"].(string)
 
-	return NewRedirect(from, to, requestHeaders, &responseHeaders, cookies, status, initiator), nil
+	redirect := NewRedirect(from, to, requestHeaders, &responseHeaders, cookies, status, initiator)
+	redirect.Type = RedirectTypeHTTP
+	redirect.Timing = parseTimingFromRaw(redirectResponse)
+	redirect.Method = godet.Params(request).String("method")
+	parseResponseDetailsFromRaw(redirect, redirectResponse)
+
+	return redirect, nil
+}
+
+// parseClientRedirectFromRaw create redirect made by the page itself (not by 3xx response).
+// `From` is the document loaded by previous request, its response is used as redirect response
+func parseClientRedirectFromRaw(previousRequest, rawRequest godet.Params, rawResponses []godet.Params, rawNavigations []godet.Params) (*Redirect, error) {
+	if _, ok := previousRequest["request"]; !ok {
+		return nil, errors.New(errorMessageRequestNotExists)
+	}
+
+	if _, ok := rawRequest["request"]; !ok {
+		return nil, errors.New(errorMessageRequestNotExists)
+	}
+
+	from, err := url.Parse(godet.Params(previousRequest.Map("request")).String("url"))
+	if err != nil {
+		return nil, errors.New(errorMessageInvalidFromURL)
+	}
+
+	request := rawRequest.Map("request")
+
+	to, err := url.Parse(godet.Params(request).String("url"))
+	if err != nil {
+		return nil, errors.New(errorMessageInvalidToURL)
+	}
+
+	requestHeaders, err := parseHeadersFromRaw(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var cookies []*http.Cookie
+
+	var timing *Timing
+
+	var previousResponseDetails map[string]interface{}
+
+	status := 0
+	responseHeaders := http.Header{}
+	previousResponse := findResponseByRequestID(rawResponses, previousRequest.String("requestId"))
+
+	if previousResponse != nil {
+		response := previousResponse.Map("response")
+		responseHeaders, cookies = parseResponseHeadersFromRaw(response)
+		status = godet.Params(response).Int("status")
+		timing = parseTimingFromRaw(response)
+		previousResponseDetails = response
+	}
+
+	initiator := ""
+	if _, ok := rawRequest["initiator"]; ok {
+		initiator = rawRequest.Map("initiator")["type"].(string)
+	}
+
+	redirectType, scheduledDelay := clientRedirectType(rawRequest, rawNavigations)
+
+	redirect := NewRedirect(from, to, requestHeaders, &responseHeaders, cookies, status, initiator)
+	redirect.Type = redirectType
+	redirect.Delay = scheduledDelay
+	redirect.Timing = timing
+	redirect.Method = godet.Params(previousRequest.Map("request")).String("method")
+	parseResponseDetailsFromRaw(redirect, previousResponseDetails)
+
+	// actual time the previous document was shown is preferred over scheduled delay
+	requestTime, requestTimeOk := rawRequest["timestamp"].(float64)
+	responseTime, responseTimeOk := previousResponse["timestamp"].(float64)
+
+	if requestTimeOk && responseTimeOk {
+		redirect.Delay = secondsToDuration(requestTime - responseTime)
+	}
+
+	return redirect, nil
+}
+
+// parseWindowOpenFromRaw create redirect to the url opened by window.open call
+// made from document loaded by `openerRequest`
+func parseWindowOpenFromRaw(openerRequest, rawWindowOpen godet.Params) (*Redirect, error) {
+	if _, ok := openerRequest["request"]; !ok {
+		return nil, errors.New(errorMessageRequestNotExists)
+	}
+
+	from, err := url.Parse(godet.Params(openerRequest.Map("request")).String("url"))
+	if err != nil {
+		return nil, errors.New(errorMessageInvalidFromURL)
+	}
+
+	to, err := url.Parse(rawWindowOpen.String("url"))
+	if err != nil {
+		return nil, errors.New(errorMessageInvalidToURL)
+	}
+
+	redirect := NewRedirect(from, to, &http.Header{}, &http.Header{}, nil, 0, scriptInitiator)
+	redirect.Type = RedirectTypeWindowOpen
+
+	return redirect, nil
+}
+
+// clientRedirectType detect the way client side navigation was made.
+// Navigation reason reported by `Page` domain is preferred, request initiator is used otherwise.
+// Delay of scheduled navigation (meta refresh) is returned as well
+func clientRedirectType(rawRequest godet.Params, rawNavigations []godet.Params) (string, time.Duration) {
+	requestURL := rawRequest.Map("request")["url"]
+
+	// the latest navigation to the same url describes this request
+	for i := len(rawNavigations) - 1; i >= 0; i-- {
+		if rawNavigations[i]["url"] != requestURL {
+			continue
+		}
+
+		delay := secondsToDuration(rawNavigations[i]["delay"])
+
+		switch rawNavigations[i].String("reason") {
+		case "metaTagRefresh", "httpHeaderRefresh":
+			return RedirectTypeMetaRefresh, delay
+		case "scriptInitiated":
+			return RedirectTypeJavaScript, delay
+		case "formSubmissionPost", "formSubmissionGet":
+			return RedirectTypeFormPost, delay
+		}
+	}
+
+	if rawRequest.Map("request")["method"] == http.MethodPost {
+		return RedirectTypeFormPost, 0
+	}
+
+	if initiator := rawRequest.Map("initiator"); initiator != nil && initiator["type"] == scriptInitiator {
+		return RedirectTypeJavaScript, 0
+	}
+
+	return RedirectTypeOther, 0
+}
+
+func findResponseByRequestID(rawResponses []godet.Params, requestID string) godet.Params {
+	for i := len(rawResponses) - 1; i >= 0; i-- {
+		if rawResponses[i].String("requestId") == requestID {
+			return rawResponses[i]
+		}
+	}
+
+	return nil
+}
+
+func parseResponseHeadersFromRaw(response map[string]interface{}) (http.Header, []*http.Cookie) {
+	var cookies []*http.Cookie
+
+	responseHeaders := http.Header{}
+
+	headers, _ := response["headers"].(map[string]interface{})
+	for index, header := range headers {
+		responseHeaders.Add(index, header.(string))
+
+		if strings.ToLower(index) == setCookieHeaderName {
+			cookies = parseCookies(header.(string))
+		}
+	}
+
+	return responseHeaders, cookies
+}
+
+// secondsToDuration convert devtools timestamp/delay (seconds) to time.Duration
+func secondsToDuration(seconds interface{}) time.Duration {
+	s, ok := seconds.(float64)
+	if !ok || s < 0 {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
 }
 
 func parseHeadersFromRaw(request map[string]interface{}) (*http.Header, error) {
@@ -336,17 +1170,7 @@ func pareseMainResponseFromRaw(rawResponses godet.Params) (*Redirect, error) {
 		return nil, errors.New(errorMessageRedirectResponseParamHeadersNotExists)
 	}
 
-	var cookies []*http.Cookie
-
-	responseHeaders := http.Header{}
-
-	for index, header := range response["headers"].(map[string]interface{}) {
-		responseHeaders.Add(index, header.(string))
-
-		if strings.ToLower(index) == setCookieHeaderName {
-			cookies = parseCookies(header.(string))
-		}
-	}
+	responseHeaders, cookies := parseResponseHeadersFromRaw(response)
 
 	if _, ok := response["requestHeaders"]; !ok {
 		return nil, errors.New(errorMessageRequestParamHeadersNotExists)
@@ -361,6 +1185,25 @@ func pareseMainResponseFromRaw(rawResponses godet.Params) (*Redirect, error) {
 	status := int(response["status"].(float64))
 
 	redirect := NewRedirect(&url.URL{}, to, &requestHeaders, &responseHeaders, cookies, status, "")
+	redirect.Timing = parseTimingFromRaw(response)
+	parseResponseDetailsFromRaw(redirect, response)
 
 	return redirect, nil
 }
+
+// parseResponseDetailsFromRaw copy protocol, status text, mime type and remote address of the raw response
+func parseResponseDetailsFromRaw(redirect *Redirect, response map[string]interface{}) {
+	if response == nil {
+		return
+	}
+
+	details := godet.Params(response)
+
+	redirect.Protocol = details.String("protocol")
+	redirect.StatusText = details.String("statusText")
+	redirect.MimeType = details.String("mimeType")
+
+	if ip := details.String("remoteIPAddress"); ip != "" {
+		redirect.RemoteAddress = net.JoinHostPort(ip, strconv.Itoa(details.Int("remotePort")))
+	}
+}
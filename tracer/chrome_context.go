@@ -0,0 +1,92 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/opentracing/opentracing-go/log"
+	"github.com/raff/godet"
+)
+
+const (
+	errorMessageInvalidBrowserContextID = "invalid browser context id"
+	errorMessageInvalidTargetID         = "invalid target id"
+)
+
+// isolatedTab is a tab opened in its own browser context (like incognito window).
+// Cookies, cache and storage of the context are not shared with other traces
+// and are removed when the context is disposed
+type isolatedTab struct {
+	// control tab is opened in default browser context, debugger attaches to it
+	// to dispose the context because the context can't be disposed from its own tab
+	control   *godet.Tab
+	tab       *godet.Tab
+	contextID string
+}
+
+// openIsolatedTab create new browser context with a blank tab inside of it
+func (ct *ChromeTracer) openIsolatedTab() (*isolatedTab, error) {
+	control, err := ct.instance.NewTab("")
+	if err != nil {
+		return nil, fmt.Errorf("`NewTab` failed. %s", err)
+	}
+
+	it := &isolatedTab{control: control}
+
+	contextParams := godet.Params{}
+	if ct.proxy != nil {
+		contextParams["proxyServer"] = proxyServer(ct.proxy)
+	}
+
+	res, err := ct.instance.SendRequest("Target.createBrowserContext", contextParams)
+	if err != nil {
+		ct.closeIsolatedTab(it)
+		return nil, fmt.Errorf("`Target.createBrowserContext` failed. %s", err)
+	}
+
+	it.contextID, _ = res["browserContextId"].(string)
+	if it.contextID == "" {
+		ct.closeIsolatedTab(it)
+		return nil, errors.New(errorMessageInvalidBrowserContextID)
+	}
+
+	res, err = ct.instance.SendRequest("Target.createTarget", godet.Params{
+		"url":              "about:blank",
+		"browserContextId": it.contextID,
+	})
+	if err != nil {
+		ct.closeIsolatedTab(it)
+		return nil, fmt.Errorf("`Target.createTarget` failed. %s", err)
+	}
+
+	targetID, _ := res["targetId"].(string)
+	if targetID == "" {
+		ct.closeIsolatedTab(it)
+		return nil, errors.New(errorMessageInvalidTargetID)
+	}
+
+	it.tab = &godet.Tab{ID: targetID}
+
+	return it, nil
+}
+
+// closeIsolatedTab dispose browser context (closing its tab and removing all the data) and close control tab
+func (ct *ChromeTracer) closeIsolatedTab(it *isolatedTab) {
+	if it.contextID != "" {
+		// switch debugger back to control tab, isolated tab dies together with its context
+		if err := ct.instance.ActivateTab(it.control); err != nil {
+			log.Error(fmt.Errorf("`ActivateTab` failed. %s", err))
+		}
+
+		_, err := ct.instance.SendRequest("Target.disposeBrowserContext", godet.Params{
+			"browserContextId": it.contextID,
+		})
+		if err != nil {
+			log.Error(fmt.Errorf("`Target.disposeBrowserContext` failed. %s", err))
+		}
+	}
+
+	if err := ct.instance.CloseTab(it.control); err != nil {
+		log.Error(fmt.Errorf("`CloseTab` failed. %s", err))
+	}
+}
@@ -0,0 +1,293 @@
+package tracer
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func TestChromeTracer_Trace_IsolatedContext(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = func(f *fakeRemoteDebugger, _ string) {
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("1", 10.5))
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "script", 11))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("2", 11.5))
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "test.png")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 {
+		t.Fatalf("expect 2 redirects but get %d", len(redirects))
+	}
+
+	if redirects[0].Type != RedirectTypeJavaScript {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeJavaScript, redirects[0].Type)
+	}
+
+	if redirects[1].ScreenshotFileName != "test.png" {
+		t.Errorf("expect screenshot file name to be set on final response")
+	}
+
+	expected := []string{
+		"NewTab",
+		"Target.createBrowserContext",
+		"Target.createTarget",
+		"ActivateTab",
+		"Navigate",
+		"SaveScreenshot",
+		"ActivateTab",
+		"Target.disposeBrowserContext",
+		"CloseTab",
+	}
+
+	assertCallsOrder(t, fake.calls(), expected)
+}
+
+func TestChromeTracer_Trace_WithoutScreenshot(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = func(f *fakeRemoteDebugger, _ string) {
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("1", 10.5))
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "script", 11))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("2", 11.5))
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 || redirects[1].ScreenshotFileName != "" {
+		t.Errorf("expect 2 redirects without screenshot")
+	}
+
+	for _, call := range fake.calls() {
+		if call == "SaveScreenshot" {
+			t.Error("expect screenshot not to be captured")
+		}
+	}
+}
+
+func TestChromeTracer_Screenshot_IsolatedContext(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	if err := ct.Screenshot(traceURL, NewScreenSize(800, 600), "test.png"); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	assertCallsOrder(t, fake.calls(), []string{
+		"Target.createBrowserContext",
+		"Target.createTarget",
+		"Navigate",
+		"SaveScreenshot",
+		"Target.disposeBrowserContext",
+		"CloseTab",
+	})
+}
+
+func TestChromeTracer_openIsolatedTab(t *testing.T) {
+	ct := &ChromeTracer{instance: newFakeRemoteDebugger()}
+
+	it, err := ct.openIsolatedTab()
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if it.contextID != "context" {
+		t.Errorf("expect browser context id `context` but get `%s`", it.contextID)
+	}
+
+	if it.tab.ID != "target" {
+		t.Errorf("expect tab id `target` but get `%s`", it.tab.ID)
+	}
+
+	if it.control == nil {
+		t.Error("expect control tab to be opened")
+	}
+}
+
+// assertCallsOrder check that expected methods were called in provided order (other calls are ignored)
+func assertCallsOrder(t *testing.T, calls, expected []string) {
+	t.Helper()
+
+	i := 0
+	for _, call := range calls {
+		if i < len(expected) && call == expected[i] {
+			i++
+		}
+	}
+
+	if i != len(expected) {
+		t.Errorf("expect calls %s in order but get %s", strings.Join(expected, ", "), strings.Join(calls, ", "))
+	}
+}
+
+var _ ChromeRemoteDebuggerInterface = (*godet.RemoteDebugger)(nil)
+
+func makeTestFrameEvents(f *fakeRemoteDebugger, _ string) {
+	inFrame := func(params godet.Params) godet.Params {
+		params["frameId"] = "IFRAME"
+		return params
+	}
+
+	f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+	f.fire("Network.responseReceived", makeTestDocumentResponse("1", 10.5))
+	f.fire("Page.frameAttached", godet.Params{"frameId": "IFRAME", "parentFrameId": "F394EA807250832376BE81745B17B0E9"})
+
+	f.fire("Network.requestWillBeSent", inFrame(makeTestDocumentRequest("2", "http://ads.test/click", http.MethodGet, "parser", 11)))
+
+	redirect := inFrame(makeTestDocumentRequest("2", "http://offer.test", http.MethodGet, "parser", 11.2))
+	redirect["documentURL"] = "http://offer.test"
+	redirect["redirectResponse"] = map[string]interface{}{
+		"url":     "http://ads.test/click",
+		"status":  302.0,
+		"headers": map[string]interface{}{"Location": "http://offer.test"},
+	}
+	f.fire("Network.requestWillBeSent", redirect)
+	f.fire("Network.responseReceived", inFrame(makeTestDocumentResponse("2", 11.5)))
+}
+
+func TestChromeTracer_Trace_FrameTree(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = makeTestFrameEvents
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+	ct.SetFrameTree(true)
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	// main frame has no redirects, so the final response holds the frame
+	if len(redirects) != 1 || len(redirects[0].Frames) != 1 {
+		t.Fatalf("expect final response with one child frame but get %d redirects", len(redirects))
+	}
+
+	frame := redirects[0].Frames[0]
+	if frame.ID != "IFRAME" || len(frame.Redirects) != 2 {
+		t.Fatalf("expect frame chain of 2 hops but get %+v", frame)
+	}
+
+	if frame.Redirects[0].From.String() != "http://ads.test/click" || frame.Redirects[0].To.String() != "http://offer.test" || frame.Redirects[0].Status != 302 {
+		t.Errorf("invalid frame redirect %+v", frame.Redirects[0])
+	}
+
+	jsonRedirect := NewJSONRedirect(redirects[0])
+	if len(jsonRedirect.Frames) != 1 || len(jsonRedirect.Frames[0].Redirects) != 2 {
+		t.Errorf("expect frame tree to be converted to json")
+	}
+}
+
+func TestChromeTracer_Trace_FrameTreeDisabled(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = makeTestFrameEvents
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 0 {
+		t.Errorf("expect child frames to be ignored but get %d redirects", len(redirects))
+	}
+}
+
+func TestChromeTracer_Trace_HopScreenshots(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = func(f *fakeRemoteDebugger, _ string) {
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("1", 10.5))
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "script", 11))
+		f.fire("Network.responseReceived", makeTestDocumentResponse("2", 11.5))
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+	ct.SetHopScreenshots(true)
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "final.png")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 {
+		t.Fatalf("expect 2 redirects but get %d", len(redirects))
+	}
+
+	if redirects[0].ScreenshotFileName != "final-hop1.png" || redirects[1].ScreenshotFileName != "final.png" {
+		t.Errorf("invalid screenshots %s, %s", redirects[0].ScreenshotFileName, redirects[1].ScreenshotFileName)
+	}
+
+	screenshots := 0
+	for _, call := range fake.calls() {
+		if call == "SaveScreenshot" {
+			screenshots++
+		}
+	}
+
+	if screenshots != 2 {
+		t.Errorf("expect hop and final screenshots to be saved but get %d", screenshots)
+	}
+}
+
+func Test_hopScreenshotFileName(t *testing.T) {
+	if name := hopScreenshotFileName("abc.png", 0); name != "abc-hop1.png" {
+		t.Errorf("invalid hop screenshot name. expect abc-hop1.png but get %s", name)
+	}
+
+	if name := hopScreenshotFileName("shots/abc", 2); name != "shots/abc-hop3" {
+		t.Errorf("invalid hop screenshot name. expect shots/abc-hop3 but get %s", name)
+	}
+}
@@ -0,0 +1,405 @@
+package tracer
+
+import (
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+// openerRequestIndexParamName is added to `Page.windowOpen` params to keep
+// the index of main frame request which loaded the opener document
+const openerRequestIndexParamName = "openerRequestIndex"
+
+// traceEvents collects raw devtools events of a single trace.
+// Callbacks are executed in godet events goroutine so all access is guarded by mutex
+type traceEvents struct {
+	sync.Mutex
+
+	mainFrameID string
+	// Document requests (including redirects) grouped by frameId
+	requests map[string][]godet.Params
+	// Document responses grouped by frameId
+	responses map[string][]godet.Params
+	// scheduled or requested client side navigations grouped by frameId
+	navigations map[string][]godet.Params
+	// window.open calls
+	windowOpens []godet.Params
+
+	// requests in flight (any resource type), used by network idle wait strategy
+	inflight     map[string]bool
+	lastActivity time.Time
+	// time of the last `load` event and the last main frame document request
+	lastLoad       time.Time
+	lastNavigation time.Time
+	// `Network.loadingFinished` timestamps (seconds) grouped by requestId
+	finished map[string]float64
+
+	// sub-resources requests are collected only if network log is enabled
+	networkLog bool
+	resources  []*Resource
+	// the latest resource of every requestId (sub-resource redirects share requestId)
+	resourcesByID map[string]*Resource
+
+	// child frames in order of attachment
+	frames []*childFrame
+
+	// main frame urls guard, main frame documents aren't collected after it stops the chain
+	guard   *hopsGuard
+	stopped bool
+	// url policy violation which fails the trace
+	blocked error
+	// devtools events handlers running outside of events goroutine
+	handlers       sync.WaitGroup
+	handlersClosed bool
+
+	// screenshots of main frame documents taken before navigating away, grouped by document request index.
+	// Hop screenshots are named after final screenshot file, they are disabled if the name is empty
+	hopScreenshotsFileName string
+	hopScreenshots         map[int]string
+	captures               sync.WaitGroup
+	capturesClosed         bool
+}
+
+// childFrame describe frame attached to another one
+type childFrame struct {
+	id       string
+	parentID string
+	// index of parent frame document request which was loaded when frame was attached
+	documentIndex int
+}
+
+func newTraceEvents() *traceEvents {
+	return &traceEvents{
+		requests:    make(map[string][]godet.Params),
+		responses:   make(map[string][]godet.Params),
+		navigations: make(map[string][]godet.Params),
+		inflight:    make(map[string]bool),
+		finished:    make(map[string]float64),
+
+		resourcesByID:  make(map[string]*Resource),
+		hopScreenshots: make(map[int]string),
+	}
+}
+
+// isMainDocument check if paused request is a document request of the main frame,
+// the first document request is made by main frame navigation
+func (te *traceEvents) isMainDocument(params godet.Params) bool {
+	if params.String("resourceType") != documentParamName {
+		return false
+	}
+
+	te.Lock()
+	defer te.Unlock()
+
+	frameID := params.String("frameId")
+
+	return frameID == te.mainFrameID || (te.mainFrameID == "" && len(te.requests) == 0)
+}
+
+// blockTrace record url policy violation which fails the trace, the first one is kept
+func (te *traceEvents) blockTrace(err error) {
+	te.Lock()
+	defer te.Unlock()
+
+	if te.blocked == nil {
+		te.blocked = err
+	}
+}
+
+// blockedError return url policy violation which fails the trace
+func (te *traceEvents) blockedError() error {
+	te.Lock()
+	defer te.Unlock()
+
+	return te.blocked
+}
+
+func (te *traceEvents) setMainFrameID(frameID string) {
+	te.Lock()
+	te.mainFrameID = frameID
+	te.Unlock()
+}
+
+// addRequest add document request and return its index in the main frame (-1 for child frames
+// and requests made after the chain is stopped). True is returned if the chain should be stopped at the request
+func (te *traceEvents) addRequest(params godet.Params) (int, bool) {
+	te.Lock()
+	defer te.Unlock()
+
+	// the first document request is made by main frame navigation
+	if te.mainFrameID == "" && len(te.requests) == 0 {
+		te.mainFrameID = params.String("frameId")
+	}
+
+	// frame attachment event could be missed if frame is created before events are enabled
+	if params.String("frameId") != te.mainFrameID && te.frame(params.String("frameId")) == nil {
+		te.attachFrame(params.String("frameId"), te.mainFrameID)
+	}
+
+	if te.mainFrameID == params.String("frameId") && te.stopped {
+		return -1, false
+	}
+
+	te.requests[params.String("frameId")] = append(te.requests[params.String("frameId")], params)
+
+	if te.mainFrameID != params.String("frameId") {
+		return -1, false
+	}
+
+	te.lastNavigation = time.Now()
+
+	if te.guard != nil {
+		documentURL, err := url.Parse(godet.Params(params.Map("request")).String("url"))
+		if err == nil && te.guard.visit(documentURL) {
+			te.stopped = true
+		}
+	}
+
+	return len(te.requests[te.mainFrameID]) - 1, te.stopped
+}
+
+// isStopped check if the main frame chain was stopped
+func (te *traceEvents) isStopped() bool {
+	te.Lock()
+	defer te.Unlock()
+
+	return te.stopped
+}
+
+// startCapture register new hop screenshot capture, false is returned if trace results are already collected
+func (te *traceEvents) startCapture() bool {
+	te.Lock()
+	defer te.Unlock()
+
+	if te.capturesClosed {
+		return false
+	}
+
+	te.captures.Add(1)
+
+	return true
+}
+
+// handle run devtools event handler in a separate goroutine, so events delivery isn't blocked
+// by devtools round-trips of the handler. Handlers started before waitHandlers are waited for
+func (te *traceEvents) handle(handler func()) {
+	te.Lock()
+	if te.handlersClosed {
+		te.Unlock()
+
+		go handler()
+
+		return
+	}
+
+	te.handlers.Add(1)
+	te.Unlock()
+
+	go func() {
+		defer te.handlers.Done()
+
+		handler()
+	}()
+}
+
+// waitHandlers wait for running event handlers, new ones aren't waited for
+func (te *traceEvents) waitHandlers() {
+	te.Lock()
+	te.handlersClosed = true
+	te.Unlock()
+
+	te.handlers.Wait()
+}
+
+// waitCaptures wait for started hop screenshots captures and reject new ones
+func (te *traceEvents) waitCaptures() {
+	te.Lock()
+	te.capturesClosed = true
+	te.Unlock()
+
+	te.captures.Wait()
+}
+
+// addHopScreenshot keep screenshot of the main frame document loaded with request `documentIndex`
+func (te *traceEvents) addHopScreenshot(documentIndex int, fileName string) {
+	te.Lock()
+	te.hopScreenshots[documentIndex] = fileName
+	te.Unlock()
+}
+
+func (te *traceEvents) requestStarted(params godet.Params) {
+	te.Lock()
+	te.inflight[params.String("requestId")] = true
+	te.lastActivity = time.Now()
+	te.Unlock()
+}
+
+func (te *traceEvents) requestFinished(params godet.Params) {
+	te.Lock()
+	delete(te.inflight, params.String("requestId"))
+	te.lastActivity = time.Now()
+	te.Unlock()
+}
+
+// loadingFinished mark request as finished and keep its timestamp to calculate response download time
+func (te *traceEvents) loadingFinished(params godet.Params) {
+	te.requestFinished(params)
+
+	te.Lock()
+	if timestamp, ok := params["timestamp"].(float64); ok {
+		te.finished[params.String("requestId")] = timestamp
+	}
+
+	if resource, ok := te.resourcesByID[params.String("requestId")]; ok {
+		resource.Size = int64(params.Int("encodedDataLength"))
+	}
+	te.Unlock()
+}
+
+// loadingFailed mark request as finished and keep network error of the resource
+func (te *traceEvents) loadingFailed(params godet.Params) {
+	te.requestFinished(params)
+
+	te.Lock()
+	if resource, ok := te.resourcesByID[params.String("requestId")]; ok {
+		resource.Error = params.String("errorText")
+		if reason := params.String("blockedReason"); reason != "" {
+			resource.Error += " (" + reason + ")"
+		}
+	}
+	te.Unlock()
+}
+
+// addResource add sub-resource request to the network log (main frame documents are reported as redirects)
+func (te *traceEvents) addResource(params godet.Params) {
+	te.Lock()
+	defer te.Unlock()
+
+	if !te.networkLog || (params.String("type") == documentParamName && params.String("frameId") == te.mainFrameID) {
+		return
+	}
+
+	requestID := params.String("requestId")
+
+	// sub-resource redirect is reported as the new request with the same requestId
+	if previous, ok := te.resourcesByID[requestID]; ok {
+		if redirectResponse := params.Map("redirectResponse"); redirectResponse != nil {
+			previous.Status = godet.Params(redirectResponse).Int("status")
+		}
+	}
+
+	resource := newResourceFromRaw(params, len(te.requests[te.mainFrameID])-1)
+	te.resources = append(te.resources, resource)
+	te.resourcesByID[requestID] = resource
+}
+
+// resourceResponse set response status of the resource
+func (te *traceEvents) resourceResponse(params godet.Params) {
+	te.Lock()
+	if resource, ok := te.resourcesByID[params.String("requestId")]; ok {
+		resource.Status = godet.Params(params.Map("response")).Int("status")
+	}
+	te.Unlock()
+}
+
+// documentResources return resources requested by the document loaded with main frame request `documentIndex`.
+// Caller should hold the lock
+func (te *traceEvents) documentResources(documentIndex int, documentURL string) []*Resource {
+	var resources []*Resource
+
+	for _, r := range te.resources {
+		if r.documentIndex == documentIndex {
+			resources = append(resources, r)
+		}
+	}
+
+	markThirdParty(resources, documentURL)
+
+	return resources
+}
+
+// frameAttached register child frame from `Page.frameAttached` event
+func (te *traceEvents) frameAttached(params godet.Params) {
+	te.Lock()
+	if te.frame(params.String("frameId")) == nil {
+		te.attachFrame(params.String("frameId"), params.String("parentFrameId"))
+	}
+	te.Unlock()
+}
+
+// attachFrame register child frame. Caller should hold the lock
+func (te *traceEvents) attachFrame(frameID, parentID string) {
+	te.frames = append(te.frames, &childFrame{
+		id:            frameID,
+		parentID:      parentID,
+		documentIndex: len(te.requests[parentID]) - 1,
+	})
+}
+
+// frame find child frame by id. Caller should hold the lock
+func (te *traceEvents) frame(frameID string) *childFrame {
+	for _, f := range te.frames {
+		if f.id == frameID {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// childFrames return frames attached to the parent one which made document requests.
+// Caller should hold the lock
+func (te *traceEvents) childFrames(parentID string) []*childFrame {
+	var frames []*childFrame
+
+	for _, f := range te.frames {
+		if f.parentID == parentID && len(te.requests[f.id]) > 0 {
+			frames = append(frames, f)
+		}
+	}
+
+	return frames
+}
+
+func (te *traceEvents) loadFired(godet.Params) {
+	te.Lock()
+	te.lastLoad = time.Now()
+	te.Unlock()
+}
+
+// isLoaded check if `load` event was fired for the last document in main frame
+func (te *traceEvents) isLoaded() bool {
+	te.Lock()
+	defer te.Unlock()
+
+	return !te.lastLoad.IsZero() && te.lastLoad.After(te.lastNavigation)
+}
+
+// isIdle check if there were no network requests in flight for `idleTime`
+func (te *traceEvents) isIdle(idleTime time.Duration) bool {
+	te.Lock()
+	defer te.Unlock()
+
+	return !te.lastActivity.IsZero() && len(te.inflight) == 0 && time.Since(te.lastActivity) >= idleTime
+}
+
+func (te *traceEvents) addResponse(params godet.Params) {
+	te.Lock()
+	te.responses[params.String("frameId")] = append(te.responses[params.String("frameId")], params)
+	te.Unlock()
+}
+
+func (te *traceEvents) addNavigation(params godet.Params) {
+	te.Lock()
+	te.navigations[params.String("frameId")] = append(te.navigations[params.String("frameId")], params)
+	te.Unlock()
+}
+
+func (te *traceEvents) addWindowOpen(params godet.Params) {
+	te.Lock()
+	params[openerRequestIndexParamName] = float64(len(te.requests[te.mainFrameID]) - 1)
+	te.windowOpens = append(te.windowOpens, params)
+	te.Unlock()
+}
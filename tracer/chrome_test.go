@@ -210,6 +210,10 @@ func TestParseRedirectFromRaw1(t *testing.T) {
 		t.Errorf("invalid redirect Initiator param. expect %s but get %s", "other", redirect.Initiator)
 	}
 
+	if redirect.Type != RedirectTypeHTTP {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeHTTP, redirect.Type)
+	}
+
 	if redirect.RequestHeaders.Get("Test") != "redirective-request-header" {
 		t.Errorf("invalid redirect RequestHeader param. expect %s but get %s", "redirective-request-header", redirect.RequestHeaders.Get("Test"))
 	}
@@ -225,6 +229,14 @@ func TestParseRedirectFromRaw1(t *testing.T) {
 	if redirect.Cookies[0].Value != "bar" || redirect.Cookies[0].Name != "foo" || redirect.Cookies[0].Raw != "foo=bar; expires=Sat, 28-Dec-2019 18:32:22 GMT; Max-Age=31536000; domain=test.com" {
 		t.Error("invalid redirect Cookies values")
 	}
+
+	if redirect.Timing == nil || redirect.Timing.DNS.Round(time.Millisecond) != 113*time.Millisecond || redirect.Timing.TTFB.Round(time.Millisecond) != 545*time.Millisecond {
+		t.Errorf("invalid redirect Timing param %+v", redirect.Timing)
+	}
+
+	if redirect.Method != "GET" || redirect.Protocol != "http/1.1" || redirect.StatusText != "Found" || redirect.MimeType != "text/html" || redirect.RemoteAddress != "104.248.96.70:80" {
+		t.Errorf("invalid redirect response details %s %s %s %s %s", redirect.Method, redirect.Protocol, redirect.StatusText, redirect.MimeType, redirect.RemoteAddress)
+	}
 }
 
 func TestParseRedirectFromRaw2(t *testing.T) {
@@ -1382,3 +1394,224 @@ func Test_ParseRedirectFromRaw_RedirectNoHeaders(t *testing.T) {
 //	}
 //	//TODO: check rd!
 //}
+
+func makeTestDocumentRequest(requestID, rawURL, method, initiator string, timestamp float64) godet.Params {
+	return godet.Params{
+		"requestId": requestID,
+		"frameId":   "F394EA807250832376BE81745B17B0E9",
+		"type":      documentParamName,
+		"timestamp": timestamp,
+		"initiator": map[string]interface{}{
+			"type": initiator,
+		},
+		"request": map[string]interface{}{
+			"url":    rawURL,
+			"method": method,
+			"headers": map[string]interface{}{
+				"Referer": "http://step0.test",
+			},
+		},
+	}
+}
+
+func makeTestDocumentResponse(requestID string, timestamp float64) godet.Params {
+	return godet.Params{
+		"requestId": requestID,
+		"frameId":   "F394EA807250832376BE81745B17B0E9",
+		"type":      documentParamName,
+		"timestamp": timestamp,
+		"response": map[string]interface{}{
+			"url":    "http://step0.test",
+			"status": 200.00,
+			"requestHeaders": map[string]interface{}{
+				"Referer": "http://step0.test",
+			},
+			"headers": map[string]interface{}{
+				"set-cookie": "foo=bar; domain=step0.test",
+				"Test":       "redirective-response-header",
+			},
+		},
+	}
+}
+
+func Test_parseClientRedirectFromRaw(t *testing.T) {
+	previous := makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10)
+	current := makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "other", 12.5)
+	responses := []godet.Params{makeTestDocumentResponse("1", 10.5)}
+	navigations := []godet.Params{{"url": "http://step1.test", "reason": "metaTagRefresh", "delay": 2.0}}
+
+	redirect, err := parseClientRedirectFromRaw(previous, current, responses, navigations)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if redirect.From.String() != "http://step0.test" {
+		t.Errorf("invalid redirect From param. expect %s but get %s", "http://step0.test", redirect.From.String())
+	}
+
+	if redirect.To.String() != "http://step1.test" {
+		t.Errorf("invalid redirect To param. expect %s but get %s", "http://step1.test", redirect.To.String())
+	}
+
+	if redirect.Type != RedirectTypeMetaRefresh {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeMetaRefresh, redirect.Type)
+	}
+
+	if redirect.Delay != 2*time.Second {
+		t.Errorf("invalid redirect Delay param. expect %s but get %s", 2*time.Second, redirect.Delay)
+	}
+
+	if redirect.Status != 200 {
+		t.Errorf("invalid redirect Status param. expect %d but get %d", 200, redirect.Status)
+	}
+
+	if redirect.ResponseHeaders.Get("Test") != "redirective-response-header" {
+		t.Errorf("invalid redirect ResponseHeaders param. expect %s but get %s", "redirective-response-header", redirect.ResponseHeaders.Get("Test"))
+	}
+
+	if len(redirect.Cookies) != 1 || redirect.Cookies[0].Name != "foo" {
+		t.Error("invalid redirect Cookies values")
+	}
+
+	if redirect.RequestHeaders.Get("Referer") != "http://step0.test" {
+		t.Errorf("invalid redirect RequestHeaders param. expect %s but get %s", "http://step0.test", redirect.RequestHeaders.Get("Referer"))
+	}
+}
+
+func Test_parseClientRedirectFromRaw_NoPreviousResponse(t *testing.T) {
+	previous := makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10)
+	current := makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "script", 12.5)
+
+	redirect, err := parseClientRedirectFromRaw(previous, current, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if redirect.Type != RedirectTypeJavaScript {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeJavaScript, redirect.Type)
+	}
+
+	if redirect.Status != 0 || redirect.Delay != 0 {
+		t.Errorf("expect empty Status and Delay but get %d and %s", redirect.Status, redirect.Delay)
+	}
+}
+
+func Test_parseClientRedirectFromRaw_NoRequest(t *testing.T) {
+	previous := makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10)
+
+	_, err := parseClientRedirectFromRaw(previous, godet.Params{}, nil, nil)
+	if err == nil || err.Error() != errorMessageRequestNotExists {
+		t.Errorf("expect error: %s but got %v", errorMessageRequestNotExists, err)
+	}
+
+	_, err = parseClientRedirectFromRaw(godet.Params{}, previous, nil, nil)
+	if err == nil || err.Error() != errorMessageRequestNotExists {
+		t.Errorf("expect error: %s but got %v", errorMessageRequestNotExists, err)
+	}
+}
+
+func Test_clientRedirectType(t *testing.T) {
+	cases := []struct {
+		request     godet.Params
+		navigations []godet.Params
+		expect      string
+		delay       time.Duration
+	}{
+		{
+			request:     makeTestDocumentRequest("1", "http://step1.test", http.MethodGet, "other", 0),
+			navigations: []godet.Params{{"url": "http://step1.test", "reason": "metaTagRefresh", "delay": 1.5}},
+			expect:      RedirectTypeMetaRefresh,
+			delay:       1500 * time.Millisecond,
+		},
+		{
+			request:     makeTestDocumentRequest("1", "http://step1.test", http.MethodGet, "other", 0),
+			navigations: []godet.Params{{"url": "http://step1.test", "reason": "scriptInitiated", "delay": 0.0}},
+			expect:      RedirectTypeJavaScript,
+		},
+		{
+			request:     makeTestDocumentRequest("1", "http://step1.test", http.MethodPost, "other", 0),
+			navigations: []godet.Params{{"url": "http://step1.test", "reason": "formSubmissionPost"}},
+			expect:      RedirectTypeFormPost,
+		},
+		{
+			request:     makeTestDocumentRequest("1", "http://step1.test", http.MethodPost, "other", 0),
+			navigations: []godet.Params{{"url": "http://other.test", "reason": "scriptInitiated"}},
+			expect:      RedirectTypeFormPost,
+		},
+		{
+			request: makeTestDocumentRequest("1", "http://step1.test", http.MethodGet, "script", 0),
+			expect:  RedirectTypeJavaScript,
+		},
+		{
+			request: makeTestDocumentRequest("1", "http://step1.test", http.MethodGet, "other", 0),
+			expect:  RedirectTypeOther,
+		},
+	}
+
+	for i, c := range cases {
+		redirectType, delay := clientRedirectType(c.request, c.navigations)
+		if redirectType != c.expect {
+			t.Errorf("case %d: expect redirect type %s but get %s", i, c.expect, redirectType)
+		}
+
+		if delay != c.delay {
+			t.Errorf("case %d: expect delay %s but get %s", i, c.delay, delay)
+		}
+	}
+}
+
+func Test_parseWindowOpenFromRaw(t *testing.T) {
+	opener := makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10)
+
+	redirect, err := parseWindowOpenFromRaw(opener, godet.Params{"url": "http://popup.test"})
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if redirect.From.String() != "http://step0.test" {
+		t.Errorf("invalid redirect From param. expect %s but get %s", "http://step0.test", redirect.From.String())
+	}
+
+	if redirect.To.String() != "http://popup.test" {
+		t.Errorf("invalid redirect To param. expect %s but get %s", "http://popup.test", redirect.To.String())
+	}
+
+	if redirect.Type != RedirectTypeWindowOpen {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeWindowOpen, redirect.Type)
+	}
+
+	_, err = parseWindowOpenFromRaw(godet.Params{}, godet.Params{"url": "http://popup.test"})
+	if err == nil || err.Error() != errorMessageRequestNotExists {
+		t.Errorf("expect error: %s but got %v", errorMessageRequestNotExists, err)
+	}
+}
+
+func Test_traceEvents_addWindowOpen(t *testing.T) {
+	events := newTraceEvents()
+	events.setMainFrameID("F394EA807250832376BE81745B17B0E9")
+	events.addRequest(makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+	events.addRequest(makeTestDocumentRequest("2", "http://step1.test", http.MethodGet, "other", 11))
+	events.addWindowOpen(godet.Params{"url": "http://popup.test"})
+
+	if len(events.windowOpens) != 1 {
+		t.Fatalf("expect 1 window.open event but get %d", len(events.windowOpens))
+	}
+
+	if index := events.windowOpens[0].Int(openerRequestIndexParamName); index != 1 {
+		t.Errorf("expect opener request index %d but get %d", 1, index)
+	}
+}
+
+func Test_secondsToDuration(t *testing.T) {
+	if d := secondsToDuration(1.25); d != 1250*time.Millisecond {
+		t.Errorf("expect %s but get %s", 1250*time.Millisecond, d)
+	}
+
+	if d := secondsToDuration(-1.0); d != 0 {
+		t.Errorf("expect negative value to be converted to 0 but get %s", d)
+	}
+
+	if d := secondsToDuration(nil); d != 0 {
+		t.Errorf("expect nil value to be converted to 0 but get %s", d)
+	}
+}
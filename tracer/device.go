@@ -0,0 +1,100 @@
+package tracer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Device names of supported emulation presets
+const (
+	DeviceDesktop  = "desktop"
+	DeviceIPhone   = "iphone"
+	DeviceIPhoneSE = "iphone-se"
+	DevicePixel    = "pixel"
+	DeviceGalaxy   = "galaxy"
+	DeviceIPad     = "ipad"
+)
+
+const (
+	iPhoneUserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1"
+	iPadUserAgent   = "Mozilla/5.0 (iPad; CPU OS 13_3 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.0.4 Mobile/15E148 Safari/604.1"
+	pixelUserAgent  = "Mozilla/5.0 (Linux; Android 10; Pixel 4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Mobile Safari/537.36"
+	galaxyUserAgent = "Mozilla/5.0 (Linux; Android 10; SM-G981B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Mobile Safari/537.36"
+	maxTouchPoints  = 5
+)
+
+// Device describe emulated device: viewport, device pixel ratio, touch support and user agent
+type Device struct {
+	Name        string  `json:"name"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	ScaleFactor float64 `json:"scale_factor"` // device pixel ratio, 0 means browser default
+	Mobile      bool    `json:"mobile"`
+	Touch       bool    `json:"touch"`
+	UserAgent   string  `json:"user_agent,omitempty"` // browser user agent is used if empty
+	Platform    string  `json:"platform,omitempty"`   // navigator.platform value
+}
+
+// devices contains supported emulation presets
+var devices = map[string]*Device{
+	DeviceDesktop:  {Name: DeviceDesktop, Width: 1920, Height: 1080},
+	DeviceIPhone:   {Name: DeviceIPhone, Width: 375, Height: 812, ScaleFactor: 3, Mobile: true, Touch: true, UserAgent: iPhoneUserAgent, Platform: "iPhone"},
+	DeviceIPhoneSE: {Name: DeviceIPhoneSE, Width: 375, Height: 667, ScaleFactor: 2, Mobile: true, Touch: true, UserAgent: iPhoneUserAgent, Platform: "iPhone"},
+	DevicePixel:    {Name: DevicePixel, Width: 353, Height: 745, ScaleFactor: 3, Mobile: true, Touch: true, UserAgent: pixelUserAgent, Platform: "Linux armv8l"},
+	DeviceGalaxy:   {Name: DeviceGalaxy, Width: 360, Height: 800, ScaleFactor: 4, Mobile: true, Touch: true, UserAgent: galaxyUserAgent, Platform: "Linux armv8l"},
+	DeviceIPad:     {Name: DeviceIPad, Width: 768, Height: 1024, ScaleFactor: 2, Mobile: true, Touch: true, UserAgent: iPadUserAgent, Platform: "iPad"},
+}
+
+// deviceAliases contains generic names of device presets
+var deviceAliases = map[string]string{
+	"mobile": DeviceIPhone,
+	"tablet": DeviceIPad,
+}
+
+// DefaultDevice return desktop device preset with provided screen size
+func DefaultDevice(size *ScreenSize) *Device {
+	device := *devices[DeviceDesktop]
+	device.Width, device.Height = size.Width, size.Height
+
+	return &device
+}
+
+// DeviceByName return a copy of device preset by name or alias (case insensitive)
+func DeviceByName(name string) (*Device, error) {
+	name = strings.ToLower(name)
+	if alias, ok := deviceAliases[name]; ok {
+		name = alias
+	}
+
+	preset, ok := devices[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown device `%s`. supported devices: %s", name, strings.Join(DeviceNames(), ", "))
+	}
+
+	device := *preset
+
+	return &device, nil
+}
+
+// DeviceNames return sorted names and aliases of supported device presets
+func DeviceNames() []string {
+	names := make([]string, 0, len(devices)+len(deviceAliases))
+
+	for name := range devices {
+		names = append(names, name)
+	}
+
+	for alias := range deviceAliases {
+		names = append(names, alias)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ScreenSize return device viewport size
+func (d *Device) ScreenSize() *ScreenSize {
+	return NewScreenSize(d.Width, d.Height)
+}
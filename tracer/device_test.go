@@ -0,0 +1,79 @@
+package tracer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDeviceByName(t *testing.T) {
+	device, err := DeviceByName("Mobile")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if device.Name != DeviceIPhone || !device.Mobile || !device.Touch || device.UserAgent == "" {
+		t.Errorf("expect mobile alias to be iphone preset but get %+v", device)
+	}
+
+	// presets are copied, so changes don't affect other traces
+	device.Width = 100
+
+	if preset, _ := DeviceByName(DeviceIPhone); preset.Width == 100 {
+		t.Error("expect device preset not to be changed")
+	}
+
+	if _, err := DeviceByName("nokia"); err == nil {
+		t.Error("expect error for unknown device")
+	}
+}
+
+func TestDeviceNames(t *testing.T) {
+	names := DeviceNames()
+
+	if len(names) != len(devices)+len(deviceAliases) {
+		t.Errorf("expect devices and aliases names but get %v", names)
+	}
+
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("expect sorted names but get %v", names)
+		}
+	}
+}
+
+func TestChromeTracer_emulate(t *testing.T) {
+	traceURL, _ := url.Parse("http://step0.test")
+
+	for name, expectEmulation := range map[string]bool{DeviceDesktop: false, DevicePixel: true} {
+		fake := newFakeRemoteDebugger()
+
+		ct := &ChromeTracer{
+			instance:     fake,
+			size:         NewScreenSize(1920, 1080),
+			waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+		}
+
+		device, _ := DeviceByName(name)
+		ct.SetDevice(device)
+
+		if ct.size.Width != device.Width || ct.size.Height != device.Height {
+			t.Errorf("expect tracer screen size to be replaced with %s viewport", name)
+		}
+
+		if _, err := ct.Trace(traceURL, ""); err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		touch, userAgent := false, false
+
+		for _, call := range fake.calls() {
+			touch = touch || call == "Emulation.setTouchEmulationEnabled"
+			userAgent = userAgent || call == "Emulation.setUserAgentOverride"
+		}
+
+		if touch != expectEmulation || userAgent != expectEmulation {
+			t.Errorf("invalid %s emulation. expect touch and user agent override %t but get %t, %t", name, expectEmulation, touch, userAgent)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package tracer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/raff/godet"
+)
+
+// Error codes of trace failures, they are reported as machine readable `error_code`
+const (
+	ErrorCodeInvalidURL         = "invalid_url"
+	ErrorCodeDNS                = "dns_failure"
+	ErrorCodeTLS                = "tls_error"
+	ErrorCodeConnection         = "connection_failed"
+	ErrorCodeTimeout            = "timeout"
+	ErrorCodeRedirectLoop       = "redirect_loop"
+	ErrorCodeBrowserUnavailable = "browser_unavailable"
+	ErrorCodeBlocked            = "blocked_by_policy"
+	ErrorCodeCredentials        = "credentials_missing"
+	ErrorCodeInternal           = "internal_error"
+)
+
+// Sentinel errors of trace failures. Tracers wrap original errors, so the kind of failure
+// could be checked with errors.Is and converted to error code with ErrorCode
+var (
+	ErrInvalidURL         = errors.New("invalid url")
+	ErrDNS                = errors.New("dns resolution failed")
+	ErrTLS                = errors.New("tls error")
+	ErrConnection         = errors.New("connection failed")
+	ErrTimeout            = errors.New("timeout")
+	ErrRedirectLoop       = errors.New("redirect loop")
+	ErrBrowserUnavailable = errors.New("browser unavailable")
+	ErrBlocked            = errors.New("blocked by policy")
+	ErrCredentialsMissing = errors.New("credentials missing")
+)
+
+// errorCodes contains error code of every sentinel error
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrInvalidURL, ErrorCodeInvalidURL},
+	{ErrDNS, ErrorCodeDNS},
+	{ErrTLS, ErrorCodeTLS},
+	{ErrConnection, ErrorCodeConnection},
+	{ErrTimeout, ErrorCodeTimeout},
+	{ErrRedirectLoop, ErrorCodeRedirectLoop},
+	{ErrBrowserUnavailable, ErrorCodeBrowserUnavailable},
+	{ErrBlocked, ErrorCodeBlocked},
+	{ErrCredentialsMissing, ErrorCodeCredentials},
+}
+
+// chromeNetErrors map chrome network error prefixes (net::ERR_...) to sentinel errors
+var chromeNetErrors = []struct {
+	prefix string
+	err    error
+}{
+	{"net::ERR_NAME_NOT_RESOLVED", ErrDNS},
+	{"net::ERR_NAME_RESOLUTION_FAILED", ErrDNS},
+	{"net::ERR_CERT_", ErrTLS},
+	{"net::ERR_SSL_", ErrTLS},
+	{"net::ERR_BAD_SSL_CLIENT_AUTH_CERT", ErrTLS},
+	{"net::ERR_TIMED_OUT", ErrTimeout},
+	{"net::ERR_CONNECTION_TIMED_OUT", ErrTimeout},
+	{"net::ERR_TOO_MANY_REDIRECTS", ErrRedirectLoop},
+	{"net::ERR_BLOCKED_", ErrBlocked},
+	{"net::ERR_INVALID_URL", ErrInvalidURL},
+	{"net::ERR_UNSAFE_REDIRECT", ErrBlocked},
+	{"net::ERR_CONNECTION_", ErrConnection},
+	{"net::ERR_ADDRESS_", ErrConnection},
+	{"net::ERR_PROXY_", ErrConnection},
+	{"net::ERR_TUNNEL_CONNECTION_FAILED", ErrConnection},
+	{"net::ERR_EMPTY_RESPONSE", ErrConnection},
+	{"net::ERR_INTERNET_DISCONNECTED", ErrConnection},
+}
+
+// ErrorCode return error code of trace failure, ErrorCodeInternal is returned for unknown errors
+// and empty string if err is nil
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+
+	return ErrorCodeInternal
+}
+
+// wrapError wrap err with sentinel error, original error message is kept
+func wrapError(kind, err error) error {
+	return fmt.Errorf("%w. %s", kind, err)
+}
+
+// classifyNetError wrap http client error with sentinel error of known failure kind
+func classifyNetError(err error) error {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateErr x509.CertificateInvalidError
+	var recordHeaderErr tls.RecordHeaderError
+
+	switch {
+	case errors.Is(err, ErrBlocked):
+		return err
+	case errors.As(err, &dnsErr):
+		return wrapError(ErrDNS, err)
+	case errors.As(err, &unknownAuthorityErr), errors.As(err, &hostnameErr),
+		errors.As(err, &certificateErr), errors.As(err, &recordHeaderErr),
+		strings.Contains(err.Error(), "tls: "):
+		return wrapError(ErrTLS, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return wrapError(ErrTimeout, err)
+	case errors.As(err, &opErr):
+		return wrapError(ErrConnection, err)
+	}
+
+	return err
+}
+
+// classifyNavigationError wrap chrome navigation error with sentinel error of known failure kind
+func classifyNavigationError(err error) error {
+	var navigationErr godet.NavigationError
+	if !errors.As(err, &navigationErr) {
+		return err
+	}
+
+	for _, e := range chromeNetErrors {
+		if strings.HasPrefix(string(navigationErr), e.prefix) {
+			return wrapError(e.err, err)
+		}
+	}
+
+	return err
+}
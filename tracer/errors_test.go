@@ -0,0 +1,91 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func TestErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		code string
+	}{
+		{nil, ""},
+		{errors.New("unknown"), ErrorCodeInternal},
+		{wrapError(ErrDNS, errors.New("no such host")), ErrorCodeDNS},
+		{fmt.Errorf("`Get` failed. %w", wrapError(ErrTimeout, errors.New("deadline exceeded"))), ErrorCodeTimeout},
+		{fmt.Errorf("%w. no browser", ErrBrowserUnavailable), ErrorCodeBrowserUnavailable},
+	}
+
+	for _, c := range cases {
+		if code := ErrorCode(c.err); code != c.code {
+			t.Errorf("expect error code `%s` but get `%s` for `%v`", c.code, code, c.err)
+		}
+	}
+}
+
+func Test_classifyNavigationError(t *testing.T) {
+	cases := map[string]error{
+		"net::ERR_NAME_NOT_RESOLVED":      ErrDNS,
+		"net::ERR_CERT_AUTHORITY_INVALID": ErrTLS,
+		"net::ERR_CONNECTION_REFUSED":     ErrConnection,
+		"net::ERR_TOO_MANY_REDIRECTS":     ErrRedirectLoop,
+		"net::ERR_TIMED_OUT":              ErrTimeout,
+	}
+
+	for text, kind := range cases {
+		err := classifyNavigationError(godet.NavigationError(text))
+		if !errors.Is(err, kind) {
+			t.Errorf("expect `%s` to be classified as `%s` but get `%s`", text, kind, err)
+		}
+	}
+
+	err := classifyNavigationError(godet.NavigationError("net::ERR_ABORTED"))
+	if ErrorCode(err) != ErrorCodeInternal {
+		t.Errorf("expect unknown navigation error to be internal but get `%s`", err)
+	}
+}
+
+func TestHTTPTracer_Trace_Errors(t *testing.T) {
+	loopServer := newRedirectTestServer()
+	defer loopServer.Close()
+
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slowServer.Close()
+
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+
+	cases := []struct {
+		url    string
+		client *http.Client
+		code   string
+	}{
+		{loopServer.URL + "/loop", nil, ErrorCodeRedirectLoop},
+		{tlsServer.URL, nil, ErrorCodeTLS},
+		{slowServer.URL, &http.Client{Timeout: 50 * time.Millisecond}, ErrorCodeTimeout},
+		{closedServer.URL, nil, ErrorCodeConnection},
+		{"http://redirective.invalid", nil, ErrorCodeDNS},
+	}
+
+	for _, c := range cases {
+		traceURL, _ := url.Parse(c.url)
+
+		_, err := NewHTTPTracer(c.client).Trace(traceURL, "")
+		if code := ErrorCode(err); code != c.code {
+			t.Errorf("expect error code `%s` but get `%s` for `%s`. error: %v", c.code, code, c.url, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tracer
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/raff/godet"
+)
+
+// fakeRemoteDebugger implements ChromeRemoteDebuggerInterface for tests which don't need real browser
+type fakeRemoteDebugger struct {
+	sync.Mutex
+
+	callbacks  map[string]godet.EventCallback
+	requests   []string
+	params     map[string]godet.Params
+	evaluate   func(expr string) (interface{}, error)
+	onNavigate func(f *fakeRemoteDebugger, url string)
+	// onRequest is called before SendRequest response, it could delay the response
+	onRequest func(method string)
+}
+
+func newFakeRemoteDebugger() *fakeRemoteDebugger {
+	return &fakeRemoteDebugger{
+		callbacks: make(map[string]godet.EventCallback),
+		params:    make(map[string]godet.Params),
+	}
+}
+
+func (f *fakeRemoteDebugger) called(method string) {
+	f.Lock()
+	f.requests = append(f.requests, method)
+	f.Unlock()
+}
+
+func (f *fakeRemoteDebugger) EnableRequestInterception(enabled bool) error {
+	f.called("EnableRequestInterception")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) CallbackEvent(method string, cb godet.EventCallback) {
+	f.Lock()
+	f.callbacks[method] = cb
+	f.Unlock()
+}
+
+// fire emulates devtools event
+func (f *fakeRemoteDebugger) fire(method string, params godet.Params) {
+	f.Lock()
+	cb := f.callbacks[method]
+	f.Unlock()
+
+	if cb != nil {
+		cb(params)
+	}
+}
+
+func (f *fakeRemoteDebugger) NewTab(url string) (*godet.Tab, error) {
+	f.called("NewTab")
+	return &godet.Tab{ID: "tab"}, nil
+}
+
+func (f *fakeRemoteDebugger) CloseTab(tab *godet.Tab) error {
+	f.called("CloseTab")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) NetworkEvents(enable bool) error {
+	f.called("NetworkEvents")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) ActivateTab(tab *godet.Tab) error {
+	f.called("ActivateTab")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) AllEvents(enable bool) error {
+	f.called("AllEvents")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) Navigate(url string) (string, error) {
+	f.called("Navigate")
+
+	if f.onNavigate != nil {
+		f.onNavigate(f, url)
+	}
+
+	return "F394EA807250832376BE81745B17B0E9", nil
+}
+
+func (f *fakeRemoteDebugger) SetDeviceMetricsOverride(width int, height int, deviceScaleFactor float64, mobile bool, fitWindow bool) error {
+	f.called("SetDeviceMetricsOverride")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) SetVisibleSize(width, height int) error {
+	f.called("SetVisibleSize")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) SaveScreenshot(filename string, perm os.FileMode, quality int, fromSurface bool) error {
+	f.called("SaveScreenshot")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) SetUserAgent(userAgent string) error {
+	f.called("SetUserAgent")
+	return nil
+}
+
+func (f *fakeRemoteDebugger) Evaluate(expr string, options ...godet.EvaluateOption) (interface{}, error) {
+	f.called("Evaluate")
+
+	if f.evaluate != nil {
+		return f.evaluate(expr)
+	}
+
+	return nil, nil
+}
+
+func (f *fakeRemoteDebugger) SendRequest(method string, params godet.Params) (map[string]interface{}, error) {
+	f.called(method)
+
+	f.Lock()
+	f.params[method] = params
+	onRequest := f.onRequest
+	f.Unlock()
+
+	if onRequest != nil {
+		onRequest(method)
+	}
+
+	switch method {
+	case "Page.navigate":
+		if f.onNavigate != nil {
+			f.onNavigate(f, params.String("url"))
+		}
+
+		return map[string]interface{}{"frameId": "F394EA807250832376BE81745B17B0E9"}, nil
+	case "Target.createBrowserContext":
+		return map[string]interface{}{"browserContextId": "context"}, nil
+	case "Target.createTarget":
+		if params["browserContextId"] != "context" {
+			return nil, errors.New("unknown browser context")
+		}
+
+		return map[string]interface{}{"targetId": "target"}, nil
+	}
+
+	return map[string]interface{}{}, nil
+}
+
+// sent return params of the last request with provided method
+func (f *fakeRemoteDebugger) sent(method string) godet.Params {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.params[method]
+}
+
+// calls return the list of called methods
+func (f *fakeRemoteDebugger) calls() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	return append([]string{}, f.requests...)
+}
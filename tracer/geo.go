@@ -0,0 +1,88 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultGeoAccuracy is emulated location accuracy in meters if it isn't provided
+const defaultGeoAccuracy = 100
+
+const errorMessageInvalidGeoLocation = "invalid geolocation `%s`. expected format `latitude,longitude[,accuracy]`"
+
+// Geo describe emulated visitor location: geolocation api position, timezone and locale
+type Geo struct {
+	Location *GeoLocation `json:"location,omitempty" bson:"location,omitempty"`
+	Timezone string       `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA timezone id (Europe/Berlin)
+	Locale   string       `json:"locale,omitempty" bson:"locale,omitempty"`     // ICU locale (de_DE)
+}
+
+// GeoLocation describe position reported by geolocation api
+type GeoLocation struct {
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	Longitude float64 `json:"longitude" bson:"longitude"`
+	Accuracy  float64 `json:"accuracy" bson:"accuracy"` // meters
+}
+
+// ParseGeoLocation parse `latitude,longitude[,accuracy]` string
+func ParseGeoLocation(raw string) (*GeoLocation, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf(errorMessageInvalidGeoLocation, raw)
+	}
+
+	values := make([]float64, 0, len(parts))
+
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf(errorMessageInvalidGeoLocation, raw)
+		}
+
+		values = append(values, value)
+	}
+
+	location := &GeoLocation{Latitude: values[0], Longitude: values[1], Accuracy: defaultGeoAccuracy}
+	if len(values) == 3 {
+		location.Accuracy = values[2]
+	}
+
+	if location.Latitude < -90 || location.Latitude > 90 || location.Longitude < -180 || location.Longitude > 180 || location.Accuracy < 0 {
+		return nil, errors.New("geolocation is out of range")
+	}
+
+	return location, nil
+}
+
+// IsEmpty check if nothing should be emulated
+func (g *Geo) IsEmpty() bool {
+	return g == nil || (g.Location == nil && g.Timezone == "" && g.Locale == "")
+}
+
+// Merge return geo settings where empty values are taken from defaults
+func (g *Geo) Merge(defaults *Geo) *Geo {
+	merged := &Geo{}
+	if defaults != nil {
+		*merged = *defaults
+	}
+
+	if g == nil {
+		return merged
+	}
+
+	if g.Location != nil {
+		merged.Location = g.Location
+	}
+
+	if g.Timezone != "" {
+		merged.Timezone = g.Timezone
+	}
+
+	if g.Locale != "" {
+		merged.Locale = g.Locale
+	}
+
+	return merged
+}
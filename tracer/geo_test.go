@@ -0,0 +1,81 @@
+package tracer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseGeoLocation(t *testing.T) {
+	location, err := ParseGeoLocation("52.52, 13.405")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if location.Latitude != 52.52 || location.Longitude != 13.405 || location.Accuracy != defaultGeoAccuracy {
+		t.Errorf("invalid location %+v", location)
+	}
+
+	location, _ = ParseGeoLocation("52.52,13.405,10")
+	if location == nil || location.Accuracy != 10 {
+		t.Errorf("expect accuracy 10 but get %+v", location)
+	}
+
+	for _, raw := range []string{"52.52", "52.52,13.405,10,1", "north,east", "91,0", "0,181", "0,0,-1"} {
+		if _, err := ParseGeoLocation(raw); err == nil {
+			t.Errorf("expect error for geolocation `%s`", raw)
+		}
+	}
+}
+
+func TestGeo_Merge(t *testing.T) {
+	defaults := &Geo{Location: &GeoLocation{Latitude: 1, Longitude: 2}, Timezone: "Europe/Berlin", Locale: "de_DE"}
+
+	merged := (&Geo{Locale: "en_US"}).Merge(defaults)
+	if merged.Locale != "en_US" || merged.Timezone != "Europe/Berlin" || merged.Location != defaults.Location {
+		t.Errorf("expect explicit locale and default timezone and location but get %+v", merged)
+	}
+
+	if defaults.Locale != "de_DE" {
+		t.Error("expect defaults not to be changed")
+	}
+
+	var empty *Geo
+	if !empty.Merge(nil).IsEmpty() {
+		t.Error("expect empty geo")
+	}
+}
+
+func TestChromeTracer_SetGeo(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	ct.SetGeo(&Geo{Location: &GeoLocation{Latitude: 52.52, Longitude: 13.405}, Timezone: "Europe/Berlin", Locale: "de_DE"})
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	if _, err := ct.Trace(traceURL, ""); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if fake.sent("Browser.grantPermissions")["browserContextId"] != "context" {
+		t.Error("expect geolocation permission to be granted to trace browser context")
+	}
+
+	if fake.sent("Emulation.setGeolocationOverride")["latitude"] != 52.52 {
+		t.Errorf("invalid geolocation override %v", fake.sent("Emulation.setGeolocationOverride"))
+	}
+
+	if fake.sent("Emulation.setTimezoneOverride")["timezoneId"] != "Europe/Berlin" {
+		t.Errorf("invalid timezone override %v", fake.sent("Emulation.setTimezoneOverride"))
+	}
+
+	if fake.sent("Emulation.setLocaleOverride")["locale"] != "de_DE" {
+		t.Errorf("invalid locale override %v", fake.sent("Emulation.setLocaleOverride"))
+	}
+}
@@ -0,0 +1,312 @@
+package tracer
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+const (
+	harVersion        = "1.2"
+	harCreatorName    = "redirective"
+	harCreatorVersion = "1.0"
+	harPageID         = "page_1"
+	harDefaultMethod  = http.MethodGet
+	// harNotAvailable is used by HAR for sizes and timings which are not known
+	harNotAvailable = -1
+)
+
+// HAR represent HTTP Archive 1.2 document (http://www.softwareishard.com/blog/har-12-spec/)
+type HAR struct {
+	Log *HARLog `json:"log"`
+}
+
+// HARLog is a root of exported data
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator *HARCreator `json:"creator"`
+	Pages   []*HARPage  `json:"pages"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator describe application which created the log
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HARPage describe traced page, all hops of the chain belong to the same page
+type HARPage struct {
+	StartedDateTime time.Time       `json:"startedDateTime"`
+	ID              string          `json:"id"`
+	Title           string          `json:"title"`
+	PageTimings     *HARPageTimings `json:"pageTimings"`
+}
+
+// HARPageTimings describe page load timings (not available for traces)
+type HARPageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+// HAREntry describe single hop request and response
+type HAREntry struct {
+	Pageref         string       `json:"pageref"`
+	StartedDateTime time.Time    `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         *HARRequest  `json:"request"`
+	Response        *HARResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         *HARTimings  `json:"timings"`
+	ServerIPAddress string       `json:"serverIPAddress,omitempty"`
+	Connection      string       `json:"connection,omitempty"`
+	Comment         string       `json:"comment,omitempty"`
+}
+
+// HARRequest describe hop request
+type HARRequest struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*HARCookie    `json:"cookies"`
+	Headers     []*HARNameValue `json:"headers"`
+	QueryString []*HARNameValue `json:"queryString"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+// HARResponse describe hop response
+type HARResponse struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []*HARCookie    `json:"cookies"`
+	Headers     []*HARNameValue `json:"headers"`
+	Content     *HARContent     `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int             `json:"headersSize"`
+	BodySize    int             `json:"bodySize"`
+}
+
+// HARContent describe response content (body isn't captured)
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+// HARCookie describe request or response cookie
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly"`
+	Secure   bool       `json:"secure"`
+}
+
+// HARNameValue is a header or query string param
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings describe hop network phases in milliseconds (-1 if phase is not available)
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// NewHAR create HAR document of the redirects chain, startedAt is a time the trace was started.
+// Every hop is exported as separate entry of the same page
+func NewHAR(startedAt time.Time, redirects []*JSONRedirect) *HAR {
+	page := &HARPage{
+		StartedDateTime: startedAt,
+		ID:              harPageID,
+		PageTimings:     &HARPageTimings{OnContentLoad: harNotAvailable, OnLoad: harNotAvailable},
+	}
+
+	entries := make([]*HAREntry, 0, len(redirects))
+	for _, r := range redirects {
+		entries = append(entries, newHAREntry(startedAt, r))
+	}
+
+	if len(entries) > 0 {
+		page.Title = entries[0].Request.URL
+	}
+
+	return &HAR{
+		Log: &HARLog{
+			Version: harVersion,
+			Creator: &HARCreator{Name: harCreatorName, Version: harCreatorVersion},
+			Pages:   []*HARPage{page},
+			Entries: entries,
+		},
+	}
+}
+
+// newHAREntry convert hop to HAR entry. Hop request is made to `From` url and redirects to `To`,
+// the last hop (final response) has no `From`, so its request is made to `To`
+func newHAREntry(startedAt time.Time, r *JSONRedirect) *HAREntry {
+	requestURL, redirectURL := r.From, r.To
+	if requestURL == "" {
+		requestURL, redirectURL = r.To, ""
+	}
+
+	method := r.Method
+	if method == "" {
+		method = harDefaultMethod
+	}
+
+	entry := &HAREntry{
+		Pageref:         harPageID,
+		StartedDateTime: startedAt,
+		Request: &HARRequest{
+			Method:      method,
+			URL:         requestURL,
+			HTTPVersion: r.Protocol,
+			Cookies:     newHARRequestCookies(r.RequestHeaders),
+			Headers:     newHARNameValues(r.RequestHeaders),
+			QueryString: newHARQueryString(requestURL),
+			HeadersSize: harNotAvailable,
+			BodySize:    harNotAvailable,
+		},
+		Response: &HARResponse{
+			Status:      r.Status,
+			StatusText:  r.StatusText,
+			HTTPVersion: r.Protocol,
+			Cookies:     newHARResponseCookies(r.Cookies),
+			Headers:     newHARNameValues(r.ResponseHeaders),
+			Content:     &HARContent{MimeType: r.MimeType},
+			RedirectURL: redirectURL,
+			HeadersSize: harNotAvailable,
+			BodySize:    harNotAvailable,
+		},
+		Timings: newHARTimings(r.Timing),
+		Comment: r.Type,
+	}
+
+	if r.Timing != nil {
+		entry.StartedDateTime = startedAt.Add(time.Duration(r.Timing.Start * float64(time.Millisecond)))
+		entry.Time = r.Timing.Total
+	}
+
+	if host, port, err := net.SplitHostPort(r.RemoteAddress); err == nil {
+		entry.ServerIPAddress = host
+		entry.Connection = port
+	}
+
+	return entry
+}
+
+// newHARTimings convert hop timing, HAR connect phase includes TLS handshake
+func newHARTimings(t *JSONTiming) *HARTimings {
+	if t == nil {
+		return &HARTimings{Blocked: harNotAvailable, DNS: harNotAvailable, Connect: harNotAvailable, SSL: harNotAvailable}
+	}
+
+	optional := func(value float64) float64 {
+		if value <= 0 {
+			return harNotAvailable
+		}
+
+		return value
+	}
+
+	return &HARTimings{
+		Blocked: harNotAvailable,
+		DNS:     optional(t.DNS),
+		Connect: optional(t.Connect + t.TLS),
+		SSL:     optional(t.TLS),
+		Send:    t.Send,
+		Wait:    t.TTFB,
+		Receive: t.Receive,
+	}
+}
+
+// newHARNameValues convert headers to the list sorted by name
+func newHARNameValues(headers map[string]string) []*HARNameValue {
+	values := make([]*HARNameValue, 0, len(headers))
+	for name, value := range headers {
+		values = append(values, &HARNameValue{Name: name, Value: value})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Name < values[j].Name
+	})
+
+	return values
+}
+
+func newHARQueryString(rawURL string) []*HARNameValue {
+	values := make([]*HARNameValue, 0)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return values
+	}
+
+	query := u.Query()
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range query[name] {
+			values = append(values, &HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	return values
+}
+
+// newHARRequestCookies parse cookies sent with `Cookie` request header
+func newHARRequestCookies(headers map[string]string) []*HARCookie {
+	cookies := make([]*HARCookie, 0)
+
+	header := http.Header{}
+	for name, value := range headers {
+		header.Add(name, value)
+	}
+
+	for _, c := range (&http.Request{Header: header}).Cookies() {
+		cookies = append(cookies, &HARCookie{Name: c.Name, Value: c.Value})
+	}
+
+	return cookies
+}
+
+func newHARResponseCookies(jsonCookies []*JSONCookie) []*HARCookie {
+	cookies := make([]*HARCookie, 0, len(jsonCookies))
+
+	for _, c := range jsonCookies {
+		cookie := &HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HTTPOnly,
+			Secure:   c.Secure,
+		}
+
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies
+}
@@ -0,0 +1,100 @@
+package tracer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNewHAR(t *testing.T) {
+	startedAt := time.Date(2020, 4, 17, 10, 0, 0, 0, time.UTC)
+	redirects := []*JSONRedirect{
+		{
+			From:            "http://step0.test/?utm_source=mail&id=1",
+			To:              "https://step1.test/",
+			RequestHeaders:  map[string]string{"User-Agent": "test", "Cookie": "session=abc; theme=dark"},
+			ResponseHeaders: map[string]string{"Location": "https://step1.test/"},
+			Cookies:         []*JSONCookie{{Name: "foo", Value: "bar", Domain: "step0.test", HTTPOnly: true}},
+			Status:          302,
+			StatusText:      "Found",
+			Protocol:        "http/1.1",
+			RemoteAddress:   "192.0.2.1:80",
+			Type:            RedirectTypeHTTP,
+			Timing:          &JSONTiming{DNS: 10, Connect: 20, Send: 1, TTFB: 100, Total: 131},
+		},
+		{
+			To:       "https://step1.test/",
+			Status:   200,
+			Method:   "GET",
+			MimeType: "text/html",
+			Timing:   &JSONTiming{Start: 150, Connect: 30, TLS: 40, TTFB: 50, Receive: 5, Total: 125},
+		},
+	}
+
+	har := NewHAR(startedAt, redirects)
+
+	if har.Log.Version != "1.2" || len(har.Log.Pages) != 1 || len(har.Log.Entries) != 2 {
+		t.Fatalf("invalid HAR log %+v", har.Log)
+	}
+
+	if har.Log.Pages[0].Title != "http://step0.test/?utm_source=mail&id=1" {
+		t.Errorf("invalid page title. expect first request url but get %s", har.Log.Pages[0].Title)
+	}
+
+	first := har.Log.Entries[0]
+
+	if first.Request.Method != "GET" || first.Request.URL != redirects[0].From || first.Response.RedirectURL != redirects[0].To {
+		t.Errorf("invalid first entry request %+v, response %+v", first.Request, first.Response)
+	}
+
+	if len(first.Request.QueryString) != 2 || first.Request.QueryString[0].Name != "id" {
+		t.Errorf("expect sorted query string params but get %+v", first.Request.QueryString)
+	}
+
+	if len(first.Request.Cookies) != 2 || first.Request.Cookies[0].Name != "session" {
+		t.Errorf("expect request cookies to be parsed from header but get %+v", first.Request.Cookies)
+	}
+
+	if len(first.Response.Cookies) != 1 || !first.Response.Cookies[0].HTTPOnly || first.Response.Cookies[0].Expires != nil {
+		t.Errorf("invalid response cookies %+v", first.Response.Cookies)
+	}
+
+	if first.ServerIPAddress != "192.0.2.1" || first.Connection != "80" || first.Comment != RedirectTypeHTTP {
+		t.Errorf("invalid first entry server address or comment %+v", first)
+	}
+
+	if first.Timings.DNS != 10 || first.Timings.Connect != 20 || first.Timings.SSL != -1 || first.Timings.Wait != 100 || first.Time != 131 {
+		t.Errorf("invalid first entry timings %+v", first.Timings)
+	}
+
+	last := har.Log.Entries[1]
+
+	if last.Request.URL != "https://step1.test/" || last.Response.RedirectURL != "" || last.Response.Content.MimeType != "text/html" {
+		t.Errorf("invalid last entry request %+v, response %+v", last.Request, last.Response)
+	}
+
+	if !last.StartedDateTime.Equal(startedAt.Add(150 * time.Millisecond)) {
+		t.Errorf("invalid last entry start. expect %s but get %s", startedAt.Add(150*time.Millisecond), last.StartedDateTime)
+	}
+
+	if last.Timings.DNS != -1 || last.Timings.Connect != 70 || last.Timings.SSL != 40 || last.Timings.Receive != 5 {
+		t.Errorf("invalid last entry timings %+v", last.Timings)
+	}
+
+	if _, err := json.Marshal(har); err != nil {
+		t.Errorf("unexpected error `%s`", err)
+	}
+}
+
+func TestNewHAR_WithoutTiming(t *testing.T) {
+	har := NewHAR(time.Now(), []*JSONRedirect{{From: "http://step0.test", To: "http://step1.test", Type: RedirectTypeWindowOpen}})
+
+	timings := har.Log.Entries[0].Timings
+	if timings.DNS != -1 || timings.Connect != -1 || timings.Send != 0 || timings.Wait != 0 || timings.Receive != 0 {
+		t.Errorf("expect unknown timings but get %+v", timings)
+	}
+
+	if len(NewHAR(time.Now(), nil).Log.Entries) != 0 {
+		t.Error("expect no entries for empty chain")
+	}
+}
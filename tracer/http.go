@@ -0,0 +1,214 @@
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+)
+
+const httpInitiator = "server"
+
+// maxHTTPResponseBodySize limits final response body download used to measure receive time
+const maxHTTPResponseBodySize = 10 << 20
+
+// defaultHTTPUserAgent is the same as net/http client default one
+const defaultHTTPUserAgent = "Go-http-client/1.1"
+
+const (
+	errorMessageScreenshotNotSupported        = "screenshots are not supported by http tracer"
+	errorMessageHTTPRedirectResponseNotExists = "invalid redirect. redirect response not exists"
+)
+
+// HTTPTracer represent tracer based on net/http client.
+// It follows server side (3xx) redirects only and doesn't require a browser
+type HTTPTracer struct {
+	client  *http.Client
+	request *RequestOptions
+	proxy   *url.URL
+	maxHops int
+	policy  *URLPolicy
+}
+
+// NewHTTPTracer create new http tracer instance
+func NewHTTPTracer(client *http.Client) *HTTPTracer {
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &HTTPTracer{
+		client:  client,
+		maxHops: DefaultMaxHops,
+	}
+}
+
+// SetRequestOptions set custom user agent, referer, Accept-Language, headers and cookies of the traced url request
+func (ht *HTTPTracer) SetRequestOptions(request *RequestOptions) {
+	ht.request = request
+}
+
+// SetProxy route traced requests through the proxy (http, https or socks5 with optional authentication)
+func (ht *HTTPTracer) SetProxy(proxy *url.URL) {
+	ht.proxy = proxy
+}
+
+// SetMaxHops set the number of followed redirects, zero means there is no limit
+func (ht *HTTPTracer) SetMaxHops(maxHops int) {
+	ht.maxHops = maxHops
+}
+
+// SetURLPolicy check the traced url and every redirect with the policy. Connections are checked as well
+// unless the client has custom transport or the proxy is used
+func (ht *HTTPTracer) SetURLPolicy(policy *URLPolicy) {
+	ht.policy = policy
+}
+
+// Trace parse redirect trace path for provided url.
+// Tracing is stopped if hops limit is exceeded or the chain is looped, the last hop is marked as truncated then.
+// Looped chain is returned with ErrRedirectLoop
+func (ht *HTTPTracer) Trace(url *url.URL, fileName string) ([]*Redirect, error) {
+	var redirects []*Redirect
+
+	if err := ht.policy.Check(url); err != nil {
+		return redirects, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return redirects, fmt.Errorf("cannot create cookie jar. %s", err)
+	}
+
+	next := ht.client.Transport
+
+	if next == nil && ht.proxy == nil && ht.policy != nil {
+		guarded := ht.policy.Transport()
+		defer guarded.CloseIdleConnections()
+
+		next = guarded
+	}
+
+	if ht.proxy != nil {
+		proxied, err := proxyTransport(next, ht.proxy)
+		if err != nil {
+			return redirects, err
+		}
+		defer proxied.CloseIdleConnections()
+
+		next = proxied
+	}
+
+	guard := newHopsGuard(ht.maxHops)
+	guard.visit(url)
+
+	// copy client to keep redirect hook, cookies and timings local to the trace
+	transport := newTimingTransport(next)
+	client := *ht.client
+	client.Jar = jar
+	client.Transport = transport
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		redirect, err := parseRedirectFromResponse(req.Response, req.URL)
+		if err != nil {
+			return err
+		}
+
+		redirect.Timing = transport.timing(req.Response.Request)
+
+		redirects = append(redirects, redirect)
+
+		if guard.visit(req.URL) {
+			redirect.Truncated = true
+
+			return http.ErrUseLastResponse
+		}
+
+		return ht.policy.Check(req.URL)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url.String(), nil)
+	if err != nil {
+		return redirects, fmt.Errorf("cannot create request. %w", wrapError(ErrInvalidURL, err))
+	}
+
+	// set user agent explicitly, so it is reported in request headers of every hop
+	req.Header.Set(userAgentHeaderName, defaultHTTPUserAgent)
+
+	if !ht.request.isEmpty() {
+		ht.request.apply(req)
+		// cookies are added to the jar, so they follow redirects the same way as cookies set by server
+		jar.SetCookies(url, ht.request.Cookies)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return redirects, fmt.Errorf("`Get` failed. %w", classifyNetError(err))
+	}
+	defer resp.Body.Close()
+
+	if len(redirects) == 0 {
+		return redirects, nil
+	}
+
+	// the response of truncated hop is a redirect, so it isn't reported as the final one
+	if IsTruncated(redirects) {
+		setTimingOffsets(redirects)
+
+		return redirects, loopError(redirects)
+	}
+
+	response := parseMainResponse(resp)
+	response.Timing = transport.timing(resp.Request)
+
+	// download response body to measure receive time
+	_, err = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxHTTPResponseBodySize))
+	if err == nil {
+		response.Timing.finish(float64(time.Now().UnixNano()) / float64(time.Second))
+	}
+
+	redirects = append(redirects, response)
+
+	setTimingOffsets(redirects)
+
+	return redirects, nil
+}
+
+// Screenshot is not supported by HTTPTracer
+func (ht *HTTPTracer) Screenshot(url *url.URL, size *ScreenSize, fileName string) error {
+	return errors.New(errorMessageScreenshotNotSupported)
+}
+
+func parseRedirectFromResponse(resp *http.Response, to *url.URL) (*Redirect, error) {
+	if resp == nil || resp.Request == nil {
+		return nil, errors.New(errorMessageHTTPRedirectResponseNotExists)
+	}
+
+	requestHeaders := resp.Request.Header.Clone()
+	responseHeaders := resp.Header.Clone()
+
+	redirect := NewRedirect(resp.Request.URL, to, &requestHeaders, &responseHeaders, resp.Cookies(), resp.StatusCode, httpInitiator)
+	redirect.Type = RedirectTypeHTTP
+	parseResponseDetails(redirect, resp)
+
+	return redirect, nil
+}
+
+func parseMainResponse(resp *http.Response) *Redirect {
+	requestHeaders := resp.Request.Header.Clone()
+	responseHeaders := resp.Header.Clone()
+
+	redirect := NewRedirect(&url.URL{}, resp.Request.URL, &requestHeaders, &responseHeaders, resp.Cookies(), resp.StatusCode, "")
+	parseResponseDetails(redirect, resp)
+
+	return redirect
+}
+
+// parseResponseDetails copy request method, protocol, status text and mime type of the response
+func parseResponseDetails(redirect *Redirect, resp *http.Response) {
+	redirect.Method = resp.Request.Method
+	redirect.Protocol = resp.Proto
+	redirect.StatusText = http.StatusText(resp.StatusCode)
+	redirect.MimeType = resp.Header.Get("Content-Type")
+}
@@ -0,0 +1,222 @@
+package tracer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func newRedirectTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/step0", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "foo", Value: "bar", Path: "/"})
+		w.Header().Set("Test", "redirective-response-header")
+		http.Redirect(w, r, "/step1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/step1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("foo"); err != nil || c.Value != "bar" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/pong#hop", http.StatusFound)
+	})
+	mux.HandleFunc("/pong", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ping", http.StatusFound)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		hop, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		http.Redirect(w, r, "/hop?n="+strconv.Itoa(hop+1), http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestNewHTTPTracer(t *testing.T) {
+	client := &http.Client{}
+
+	ht := NewHTTPTracer(client)
+	if ht.client != client {
+		t.Error("wrong http client instance")
+	}
+
+	ht = NewHTTPTracer(nil)
+	if ht.client == nil {
+		t.Error("expect default http client to be created")
+	}
+}
+
+func TestHTTPTracer_Trace(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	traceURL, _ := url.Parse(server.URL + "/step0")
+
+	redirects, err := NewHTTPTracer(nil).Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 3 {
+		t.Fatalf("expect 3 redirects but get %d", len(redirects))
+	}
+
+	if redirects[0].From.String() != server.URL+"/step0" {
+		t.Errorf("invalid redirect From param. expect %s but get %s", server.URL+"/step0", redirects[0].From.String())
+	}
+
+	if redirects[0].To.String() != server.URL+"/step1" {
+		t.Errorf("invalid redirect To param. expect %s but get %s", server.URL+"/step1", redirects[0].To.String())
+	}
+
+	if redirects[0].Status != http.StatusMovedPermanently {
+		t.Errorf("invalid redirect Status param. expect %d but get %d", http.StatusMovedPermanently, redirects[0].Status)
+	}
+
+	if redirects[0].Initiator != httpInitiator {
+		t.Errorf("invalid redirect Initiator param. expect %s but get %s", httpInitiator, redirects[0].Initiator)
+	}
+
+	if redirects[0].Type != RedirectTypeHTTP {
+		t.Errorf("invalid redirect Type param. expect %s but get %s", RedirectTypeHTTP, redirects[0].Type)
+	}
+
+	if redirects[0].ResponseHeaders.Get("Test") != "redirective-response-header" {
+		t.Errorf("invalid redirect ResponseHeaders param. expect %s but get %s", "redirective-response-header", redirects[0].ResponseHeaders.Get("Test"))
+	}
+
+	if len(redirects[0].Cookies) != 1 || redirects[0].Cookies[0].Name != "foo" || redirects[0].Cookies[0].Value != "bar" {
+		t.Error("invalid redirect Cookies values")
+	}
+
+	if redirects[1].Status != http.StatusFound {
+		t.Errorf("invalid redirect Status param. expect %d but get %d", http.StatusFound, redirects[1].Status)
+	}
+
+	if redirects[1].To.String() != server.URL+"/final" {
+		t.Errorf("invalid redirect To param. expect %s but get %s", server.URL+"/final", redirects[1].To.String())
+	}
+
+	if redirects[2].Status != http.StatusOK {
+		t.Errorf("invalid final response Status. expect %d but get %d (cookies are not passed along the chain?)", http.StatusOK, redirects[2].Status)
+	}
+
+	if redirects[2].To.String() != server.URL+"/final" {
+		t.Errorf("invalid final response To param. expect %s but get %s", server.URL+"/final", redirects[2].To.String())
+	}
+}
+
+func TestHTTPTracer_Trace_NoRedirects(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	traceURL, _ := url.Parse(server.URL + "/final")
+
+	redirects, err := NewHTTPTracer(nil).Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 0 {
+		t.Errorf("no redirects expected but get %d", len(redirects))
+	}
+}
+
+func TestHTTPTracer_Trace_TooManyRedirects(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	traceURL, _ := url.Parse(server.URL + "/hop?n=0")
+
+	redirects, err := NewHTTPTracer(nil).Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	// the last hop isn't followed
+	if len(redirects) != DefaultMaxHops+1 {
+		t.Fatalf("expect %d redirects but get %d", DefaultMaxHops+1, len(redirects))
+	}
+
+	if !IsTruncated(redirects) || DetectLoop(redirects) != nil {
+		t.Error("expect truncated chain without loop")
+	}
+
+	ht := NewHTTPTracer(nil)
+	ht.SetMaxHops(2)
+
+	redirects, err = ht.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 3 || !redirects[2].Truncated || redirects[1].Truncated {
+		t.Errorf("expect 3 redirects with the last truncated one but get %d", len(redirects))
+	}
+}
+
+func TestHTTPTracer_Trace_Loop(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	cases := []struct {
+		path      string
+		loop      []string
+		redirects int
+	}{
+		// a single url is stopped when it is repeated three times
+		{"/loop", []string{server.URL + "/loop"}, 2},
+		// the segment is stopped when it is repeated twice
+		{"/ping", []string{server.URL + "/ping", server.URL + "/pong"}, 3},
+	}
+
+	for _, c := range cases {
+		traceURL, _ := url.Parse(server.URL + c.path)
+
+		redirects, err := NewHTTPTracer(nil).Trace(traceURL, "")
+		if !errors.Is(err, ErrRedirectLoop) {
+			t.Fatalf("expect redirect loop error of %s but get `%v`", c.path, err)
+		}
+
+		if len(redirects) != c.redirects || !IsTruncated(redirects) {
+			t.Fatalf("expect %d redirects of truncated chain but get %d", c.redirects, len(redirects))
+		}
+
+		loop := DetectLoop(redirects)
+		if loop == nil {
+			t.Fatalf("expect loop of %s to be detected", c.path)
+		}
+
+		if loop.Start != 0 || len(loop.URLs) != len(c.loop) || loop.URLs[0] != c.loop[0] {
+			t.Errorf("invalid loop of %s %+v", c.path, loop)
+		}
+	}
+}
+
+func TestHTTPTracer_Screenshot(t *testing.T) {
+	traceURL, _ := url.Parse("http://example.com")
+
+	err := NewHTTPTracer(nil).Screenshot(traceURL, NewScreenSize(1920, 1080), "test.png")
+	if err == nil || err.Error() != errorMessageScreenshotNotSupported {
+		t.Errorf("expect error: %s but got %v", errorMessageScreenshotNotSupported, err)
+	}
+}
+
+func Test_parseRedirectFromResponse_NoResponse(t *testing.T) {
+	_, err := parseRedirectFromResponse(nil, &url.URL{})
+	if err == nil || err.Error() != errorMessageHTTPRedirectResponseNotExists {
+		t.Errorf("expect error: %s but got %v", errorMessageHTTPRedirectResponseNotExists, err)
+	}
+}
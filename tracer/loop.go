@@ -0,0 +1,145 @@
+package tracer
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxHops limits the number of followed hops if the limit isn't set explicitly
+const DefaultMaxHops = 20
+
+// loopRepetitions is the number of consecutive repetitions of urls segment which is reported as a loop.
+// A single repetition (`a -> b -> a -> final`) could be a legit cookie check, so it isn't enough
+const loopRepetitions = 2
+
+// selfLoopRepetitions is the number of repetitions of a single url reported as a loop.
+// A single redirect to itself (`a -> a`) could be a legit cookie reload, so it should recur once more
+const selfLoopRepetitions = 3
+
+// Loop describe the repeating segment of redirects chain
+type Loop struct {
+	// index of the hop which starts the first repetition of the segment
+	Start int `json:"start" bson:"start"`
+	// normalized urls of the segment
+	URLs []string `json:"urls" bson:"urls"`
+}
+
+// NormalizeURL convert url to the form used to compare chain urls: scheme and host are lower cased,
+// default port, fragment and empty path are removed, query params are sorted
+func NormalizeURL(u *url.URL) string {
+	normalized := *u
+	normalized.Scheme = strings.ToLower(u.Scheme)
+	normalized.Host = strings.ToLower(u.Host)
+	normalized.Fragment = ""
+
+	if port := normalized.Port(); (normalized.Scheme == "http" && port == "80") || (normalized.Scheme == "https" && port == "443") {
+		normalized.Host = strings.TrimSuffix(normalized.Host, ":"+port)
+	}
+
+	if normalized.Path == "" {
+		normalized.Path = "/"
+	}
+
+	// Encode sorts query params by name, values order is kept
+	normalized.RawQuery = normalized.Query().Encode()
+
+	return normalized.String()
+}
+
+// DetectLoop find the repeating segment of redirects chain, nil is returned if there is no loop.
+// Window.open hops aren't a part of the main frame navigation, so they are skipped
+func DetectLoop(redirects []*Redirect) *Loop {
+	var urls []string
+
+	for _, r := range redirects {
+		// final response doesn't have `From` url
+		if r.From == nil || r.From.String() == "" || r.Type == RedirectTypeWindowOpen {
+			continue
+		}
+
+		if len(urls) == 0 {
+			urls = append(urls, NormalizeURL(r.From))
+		}
+
+		urls = append(urls, NormalizeURL(r.To))
+	}
+
+	return detectLoop(urls)
+}
+
+// IsTruncated check if tracer stopped following redirects chain because of hops limit or loop
+func IsTruncated(redirects []*Redirect) bool {
+	return len(redirects) > 0 && redirects[len(redirects)-1].Truncated
+}
+
+// loopError return ErrRedirectLoop if the chain was truncated because of a loop, nil is returned otherwise
+func loopError(redirects []*Redirect) error {
+	if !IsTruncated(redirects) {
+		return nil
+	}
+
+	loop := DetectLoop(redirects)
+	if loop == nil {
+		return nil
+	}
+
+	return wrapError(ErrRedirectLoop, fmt.Errorf("loop detected from hop %d", loop.Start+1))
+}
+
+// detectLoop check if the sequence of normalized urls ends with segment repeated loopRepetitions times
+// (selfLoopRepetitions times for a single url)
+func detectLoop(urls []string) *Loop {
+	for size := 1; size*loopRepetitions <= len(urls); size++ {
+		repetitions := loopRepetitions
+		if size == 1 {
+			repetitions = selfLoopRepetitions
+		}
+
+		start := len(urls) - size*repetitions
+		if start < 0 {
+			continue
+		}
+
+		if isRepeated(urls[start:], size) {
+			return &Loop{Start: start, URLs: append([]string{}, urls[len(urls)-size:]...)}
+		}
+	}
+
+	return nil
+}
+
+// isRepeated check if urls consist of the same segment of provided size
+func isRepeated(urls []string, size int) bool {
+	for i := size; i < len(urls); i++ {
+		if urls[i] != urls[i-size] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hopsGuard tracks urls of the chain while it is traced and decides when to stop following it
+type hopsGuard struct {
+	maxHops int
+	urls    []string
+}
+
+func newHopsGuard(maxHops int) *hopsGuard {
+	return &hopsGuard{maxHops: maxHops}
+}
+
+// visit add the next url of the chain, true is returned if the url shouldn't be followed (hops limit
+// is exceeded or the chain is looped).
+// Zero max hops means there is no limit
+func (g *hopsGuard) visit(u *url.URL) bool {
+	g.urls = append(g.urls, NormalizeURL(u))
+
+	hops := len(g.urls) - 1
+	if g.maxHops > 0 && hops > g.maxHops {
+		return true
+	}
+
+	return detectLoop(g.urls) != nil
+}
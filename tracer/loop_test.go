@@ -0,0 +1,206 @@
+package tracer
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	for raw, normalized := range map[string]string{
+		"HTTP://Example.COM":                 "http://example.com/",
+		"http://example.com:80/a#fragment":   "http://example.com/a",
+		"https://example.com:443/?b=2&a=1":   "https://example.com/?a=1&b=2",
+		"https://example.com:8443/a?a=2&a=1": "https://example.com:8443/a?a=2&a=1",
+		"http://[2001:db8::1]:80/":           "http://[2001:db8::1]/",
+	} {
+		u, _ := url.Parse(raw)
+
+		if result := NormalizeURL(u); result != normalized {
+			t.Errorf("expect %s to be normalized to %s but get %s", raw, normalized, result)
+		}
+	}
+}
+
+// makeTestChain create redirects chain of provided urls followed by the final response
+func makeTestChain(urls ...string) []*Redirect {
+	var redirects []*Redirect
+
+	for i := 1; i < len(urls); i++ {
+		from, _ := url.Parse(urls[i-1])
+		to, _ := url.Parse(urls[i])
+		redirects = append(redirects, &Redirect{From: from, To: to, Type: RedirectTypeHTTP})
+	}
+
+	final, _ := url.Parse(urls[len(urls)-1])
+
+	return append(redirects, &Redirect{From: &url.URL{}, To: final})
+}
+
+func TestDetectLoop(t *testing.T) {
+	cases := []struct {
+		urls  []string
+		start int
+		loop  []string
+	}{
+		{[]string{"http://a.test", "http://b.test", "http://c.test"}, 0, nil},
+		// cookie check redirects back only once
+		{[]string{"http://a.test", "http://b.test", "http://a.test", "http://c.test"}, 0, nil},
+		// cookie reload redirects to itself only once
+		{[]string{"http://a.test", "http://a.test", "http://b.test"}, 0, nil},
+		{[]string{"http://a.test", "http://a.test", "http://a.test"}, 0, []string{"http://a.test/"}},
+		{[]string{"http://a.test", "http://b.test", "http://c.test#1", "http://b.test", "http://c.test#2"}, 1, []string{"http://b.test/", "http://c.test/"}},
+	}
+
+	for _, c := range cases {
+		loop := DetectLoop(makeTestChain(c.urls...))
+
+		if c.loop == nil {
+			if loop != nil {
+				t.Errorf("unexpected loop %+v of %v", loop, c.urls)
+			}
+
+			continue
+		}
+
+		if loop == nil || loop.Start != c.start || len(loop.URLs) != len(c.loop) || loop.URLs[0] != c.loop[0] || loop.URLs[len(c.loop)-1] != c.loop[len(c.loop)-1] {
+			t.Errorf("expect loop %v from hop %d of %v but get %+v", c.loop, c.start, c.urls, loop)
+		}
+	}
+}
+
+func TestDetectLoop_WindowOpen(t *testing.T) {
+	redirects := makeTestChain("http://a.test", "http://b.test")
+
+	from, _ := url.Parse("http://b.test")
+	redirects = append(redirects, &Redirect{From: from, To: from, Type: RedirectTypeWindowOpen})
+
+	if loop := DetectLoop(redirects); loop != nil {
+		t.Errorf("window.open hops should be skipped but get loop %+v", loop)
+	}
+}
+
+func Test_hopsGuard(t *testing.T) {
+	guard := newHopsGuard(2)
+
+	for i, raw := range []string{"http://a.test", "http://b.test", "http://c.test"} {
+		u, _ := url.Parse(raw)
+
+		if guard.visit(u) {
+			t.Fatalf("unexpected stop at url %d", i)
+		}
+	}
+
+	u, _ := url.Parse("http://d.test")
+	if !guard.visit(u) {
+		t.Error("expect guard to stop the chain when hops limit is exceeded")
+	}
+
+	unlimited := newHopsGuard(0)
+	for i := 0; i < DefaultMaxHops*2; i++ {
+		u, _ := url.Parse("http://a.test/?hop=" + strconv.Itoa(i))
+
+		if unlimited.visit(u) {
+			t.Fatalf("unexpected stop at url %d", i)
+		}
+	}
+}
+
+// makeTestRedirectRequest create document request made by server side redirect
+func makeTestRedirectRequest(requestID, from, to string, timestamp float64) godet.Params {
+	params := makeTestDocumentRequest(requestID, to, http.MethodGet, "other", timestamp)
+	params["documentURL"] = to
+	params["redirectResponse"] = map[string]interface{}{
+		"url":     from,
+		"status":  302.0,
+		"headers": map[string]interface{}{"Location": to},
+	}
+
+	return params
+}
+
+func TestChromeTracer_Trace_Loop(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = func(f *fakeRemoteDebugger, _ string) {
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://ping.test", http.MethodGet, "other", 10))
+
+		urls := []string{"http://ping.test", "http://pong.test"}
+		for i := 1; i < 10; i++ {
+			f.fire("Network.requestWillBeSent", makeTestRedirectRequest("1", urls[(i-1)%2], urls[i%2], 10+float64(i)))
+		}
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 5 * time.Second},
+		maxHops:      DefaultMaxHops,
+	}
+
+	started := time.Now()
+	traceURL, _ := url.Parse("http://ping.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if !errors.Is(err, ErrRedirectLoop) {
+		t.Fatalf("expect redirect loop error but get `%v`", err)
+	}
+
+	if time.Since(started) > time.Second {
+		t.Error("expect tracer not to wait when the chain is stopped")
+	}
+
+	if len(redirects) != 3 || !IsTruncated(redirects) {
+		t.Fatalf("expect 3 redirects of truncated chain but get %d", len(redirects))
+	}
+
+	loop := DetectLoop(redirects)
+	if loop == nil || loop.Start != 0 || len(loop.URLs) != 2 {
+		t.Errorf("invalid loop %+v", loop)
+	}
+
+	stopped := false
+	for _, method := range fake.calls() {
+		stopped = stopped || method == "Page.stopLoading"
+	}
+
+	if !stopped {
+		t.Error("expect navigation to be stopped")
+	}
+}
+
+func TestChromeTracer_Trace_MaxHops(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = func(f *fakeRemoteDebugger, _ string) {
+		f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://hop0.test", http.MethodGet, "other", 10))
+		f.fire("Network.requestWillBeSent", makeTestRedirectRequest("1", "http://hop0.test", "http://hop1.test", 11))
+		f.fire("Network.requestWillBeSent", makeTestRedirectRequest("1", "http://hop1.test", "http://hop2.test", 12))
+		f.fire("Network.requestWillBeSent", makeTestRedirectRequest("1", "http://hop2.test", "http://hop3.test", 13))
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+	ct.SetMaxHops(1)
+
+	traceURL, _ := url.Parse("http://hop0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 || !IsTruncated(redirects) || redirects[1].To.String() != "http://hop2.test" {
+		t.Fatalf("expect chain to be truncated at the second hop but get %d redirects", len(redirects))
+	}
+
+	if DetectLoop(redirects) != nil {
+		t.Error("unexpected loop")
+	}
+}
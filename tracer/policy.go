@@ -0,0 +1,290 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// policyLookupTimeout limits dns resolution of the checked host
+const policyLookupTimeout = 5 * time.Second
+
+// privateNetworks are special purpose address ranges which are blocked unless private addresses are allowed
+var privateNetworks = mustParseNetworks(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade nat
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local, cloud metadata
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // ietf protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved, broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+// URLPolicy decides which urls could be requested by tracers. Only http(s) urls are allowed,
+// hosts resolving to private, loopback, link-local (cloud metadata) and other special purpose addresses
+// are blocked unless private addresses are allowed. Deny list takes precedence over allow list,
+// if allow list isn't empty only matching hosts are allowed. Addresses of allowed networks are never
+// treated as private ones. Nil policy allows everything
+type URLPolicy struct {
+	allowPrivate bool
+	allow        []hostRule
+	deny         []hostRule
+	lookupIP     func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// hostRule matches a domain (and its subdomains) or a network (single ip is a network too)
+type hostRule struct {
+	domain  string
+	network *net.IPNet
+}
+
+// NewURLPolicy create url policy. Allow and deny rules are domain names (subdomains match too),
+// ip addresses or networks in CIDR notation
+func NewURLPolicy(allow, deny []string, allowPrivate bool) (*URLPolicy, error) {
+	allowRules, err := parseHostRules(allow)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow list. %s", err)
+	}
+
+	denyRules, err := parseHostRules(deny)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deny list. %s", err)
+	}
+
+	return &URLPolicy{
+		allowPrivate: allowPrivate,
+		allow:        allowRules,
+		deny:         denyRules,
+		lookupIP:     net.DefaultResolver.LookupIPAddr,
+	}, nil
+}
+
+// Check validate url scheme and host, the host is resolved and all its addresses are checked
+func (p *URLPolicy) Check(u *url.URL) error {
+	if p == nil {
+		return nil
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return wrapError(ErrBlocked, fmt.Errorf("scheme `%s` is not allowed", u.Scheme))
+	}
+
+	return p.CheckHost(u.Hostname())
+}
+
+// CheckHost validate the host and all its addresses
+func (p *URLPolicy) CheckHost(host string) error {
+	if p == nil {
+		return nil
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return wrapError(ErrInvalidURL, errors.New("host is empty"))
+	}
+
+	if matchDomain(p.deny, host) {
+		return wrapError(ErrBlocked, fmt.Errorf("host %s is denied", host))
+	}
+
+	addresses, err := p.resolve(host)
+	if err != nil {
+		return wrapError(ErrDNS, err)
+	}
+
+	hostAllowed := len(p.allow) == 0 || matchDomain(p.allow, host)
+
+	for _, ip := range addresses {
+		if err := p.checkAddress(host, ip, hostAllowed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkAddress validate resolved address of the host. Host which isn't allowed by domain rules
+// should be allowed by network rules
+func (p *URLPolicy) checkAddress(host string, ip net.IP, hostAllowed bool) error {
+	if matchNetwork(p.deny, ip) {
+		return wrapError(ErrBlocked, fmt.Errorf("address %s of host %s is denied", ip, host))
+	}
+
+	allowedNetwork := matchNetwork(p.allow, ip)
+
+	if !hostAllowed && !allowedNetwork {
+		return wrapError(ErrBlocked, fmt.Errorf("host %s is not allowed", host))
+	}
+
+	if !p.allowPrivate && !allowedNetwork && isPrivate(ip) {
+		return wrapError(ErrBlocked, fmt.Errorf("address %s of host %s is private", ip, host))
+	}
+
+	return nil
+}
+
+// checkRemoteAddress check address the url host was connected to by the client resolving hosts on its own,
+// so the host resolved to another address after the check (dns rebinding) is blocked
+func (p *URLPolicy) checkRemoteAddress(u *url.URL, address string) error {
+	if p == nil {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+
+	ip := net.ParseIP(strings.Trim(address, "[]"))
+	if ip == nil {
+		return wrapError(ErrBlocked, fmt.Errorf("address %s of host %s is not an ip", address, host))
+	}
+
+	return p.checkAddress(host, ip, len(p.allow) == 0 || matchDomain(p.allow, host))
+}
+
+// resolve return addresses of the host, ip address is returned as is
+func (p *URLPolicy) resolve(host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), policyLookupTimeout)
+	defer cancel()
+
+	addresses, err := p.lookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	ips := make([]net.IP, 0, len(addresses))
+	for _, address := range addresses {
+		ips = append(ips, address.IP)
+	}
+
+	return ips, nil
+}
+
+// dialControl check the address connection is made to, so the host can't be resolved
+// to another address after the check (dns rebinding). Host rules are checked before dialing
+func (p *URLPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return wrapError(ErrBlocked, fmt.Errorf("address %s is not an ip", host))
+	}
+
+	return p.checkAddress(host, ip, true)
+}
+
+// Transport return copy of default http transport which connects to addresses allowed by the policy only
+func (p *URLPolicy) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if p != nil {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   p.dialControl,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	return transport
+}
+
+func parseHostRules(rules []string) ([]hostRule, error) {
+	parsed := make([]hostRule, 0, len(rules))
+
+	for _, rule := range rules {
+		rule = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(rule)), ".")
+
+		switch {
+		case rule == "":
+			continue
+		case strings.Contains(rule, "/"):
+			_, network, err := net.ParseCIDR(rule)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network `%s`", rule)
+			}
+
+			parsed = append(parsed, hostRule{network: network})
+		case net.ParseIP(rule) != nil:
+			ip := net.ParseIP(rule)
+			bits := 8 * net.IPv6len
+
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			parsed = append(parsed, hostRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}})
+		case strings.ContainsAny(rule, ":@ "):
+			return nil, fmt.Errorf("invalid domain `%s`", rule)
+		default:
+			parsed = append(parsed, hostRule{domain: strings.TrimPrefix(rule, "*.")})
+		}
+	}
+
+	return parsed, nil
+}
+
+func matchDomain(rules []hostRule, host string) bool {
+	for _, rule := range rules {
+		if rule.domain != "" && (host == rule.domain || strings.HasSuffix(host, "."+rule.domain)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchNetwork(rules []hostRule, ip net.IP) bool {
+	for _, rule := range rules {
+		if rule.network != nil && rule.network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPrivate(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
@@ -0,0 +1,413 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+// newTestURLPolicy create policy which resolves `*.test` hosts with provided addresses
+func newTestURLPolicy(t *testing.T, allow, deny []string, allowPrivate bool, hosts map[string]string) *URLPolicy {
+	t.Helper()
+
+	policy, err := NewURLPolicy(allow, deny, allowPrivate)
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	policy.lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		address, ok := hosts[host]
+		if !ok {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+
+		return []net.IPAddr{{IP: net.ParseIP(address)}}, nil
+	}
+
+	return policy
+}
+
+func TestNewURLPolicy(t *testing.T) {
+	for _, rules := range [][]string{{"10.0.0.0/33"}, {"http://example.com"}, {"example.com:80"}} {
+		if _, err := NewURLPolicy(rules, nil, false); err == nil {
+			t.Errorf("expect error for allow list %v", rules)
+		}
+
+		if _, err := NewURLPolicy(nil, rules, false); err == nil {
+			t.Errorf("expect error for deny list %v", rules)
+		}
+	}
+}
+
+func TestURLPolicy_Check(t *testing.T) {
+	hosts := map[string]string{
+		"public.test":   "93.184.216.34",
+		"internal.test": "10.0.0.5",
+		"metadata.test": "169.254.169.254",
+	}
+
+	cases := []struct {
+		name   string
+		policy *URLPolicy
+		url    string
+		err    error
+	}{
+		{"nil policy", nil, "file:///etc/passwd", nil},
+		{"public", newTestURLPolicy(t, nil, nil, false, hosts), "https://public.test/path", nil},
+		{"public ip", newTestURLPolicy(t, nil, nil, false, hosts), "http://8.8.8.8", nil},
+		{"file scheme", newTestURLPolicy(t, nil, nil, false, hosts), "file:///etc/passwd", ErrBlocked},
+		{"javascript scheme", newTestURLPolicy(t, nil, nil, false, hosts), "javascript:alert(1)", ErrBlocked},
+		{"devtools", newTestURLPolicy(t, nil, nil, false, hosts), "http://127.0.0.1:9222/json", ErrBlocked},
+		{"metadata ip", newTestURLPolicy(t, nil, nil, false, hosts), "http://169.254.169.254/latest/meta-data", ErrBlocked},
+		{"ipv6 loopback", newTestURLPolicy(t, nil, nil, false, hosts), "http://[::1]:8080", ErrBlocked},
+		{"ipv4 mapped", newTestURLPolicy(t, nil, nil, false, hosts), "http://[::ffff:127.0.0.1]", ErrBlocked},
+		{"unspecified", newTestURLPolicy(t, nil, nil, false, hosts), "http://0.0.0.0:8080", ErrBlocked},
+		{"resolved private", newTestURLPolicy(t, nil, nil, false, hosts), "http://internal.test", ErrBlocked},
+		{"resolved metadata", newTestURLPolicy(t, nil, nil, false, hosts), "http://METADATA.test./computeMetadata", ErrBlocked},
+		{"not resolved", newTestURLPolicy(t, nil, nil, false, hosts), "http://unknown.test", ErrDNS},
+		{"empty host", newTestURLPolicy(t, nil, nil, false, hosts), "http:///path", ErrInvalidURL},
+		{"private allowed", newTestURLPolicy(t, nil, nil, true, hosts), "http://internal.test", nil},
+		{"denied domain", newTestURLPolicy(t, nil, []string{"public.test"}, false, hosts), "http://www.public.test", ErrBlocked},
+		{"denied network", newTestURLPolicy(t, nil, []string{"93.184.216.0/24"}, false, hosts), "http://public.test", ErrBlocked},
+		{"deny precedence", newTestURLPolicy(t, []string{"public.test"}, []string{"public.test"}, false, hosts), "http://public.test", ErrBlocked},
+		{"not in allow list", newTestURLPolicy(t, []string{"example.test"}, nil, false, hosts), "http://public.test", ErrBlocked},
+		{"allowed domain", newTestURLPolicy(t, []string{"*.public.test"}, nil, false, hosts), "http://public.test", nil},
+		{"allowed domain private", newTestURLPolicy(t, []string{"internal.test"}, nil, false, hosts), "http://internal.test", ErrBlocked},
+		{"allowed network", newTestURLPolicy(t, []string{"10.0.0.0/24"}, nil, false, hosts), "http://internal.test", nil},
+		{"allowed ip", newTestURLPolicy(t, []string{"127.0.0.1"}, nil, false, hosts), "http://127.0.0.1:8080", nil},
+	}
+
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatalf("unexpected error `%s`", err)
+		}
+
+		err = c.policy.Check(u)
+		if c.err == nil && err != nil {
+			t.Errorf("%s: unexpected error `%s`", c.name, err)
+		}
+
+		if c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%s: expect error %s but get %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestURLPolicy_dialControl(t *testing.T) {
+	policy := newTestURLPolicy(t, []string{"10.0.0.0/24"}, []string{"93.184.216.34"}, false, nil)
+
+	for address, blocked := range map[string]bool{
+		"8.8.8.8:443":         false,
+		"10.0.0.5:80":         false,
+		"127.0.0.1:9222":      true,
+		"169.254.169.254:80":  true,
+		"[fd00:ec2::254]:80":  true,
+		"93.184.216.34:80":    true,
+		"[2606:4700::1111]:0": false,
+	} {
+		err := policy.dialControl("tcp", address, nil)
+		if blocked != errors.Is(err, ErrBlocked) {
+			t.Errorf("expect address %s to be blocked: %t but get %v", address, blocked, err)
+		}
+	}
+}
+
+func TestHTTPTracer_Trace_URLPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	ht := NewHTTPTracer(&http.Client{Timeout: time.Second})
+	ht.SetURLPolicy(newTestURLPolicy(t, nil, nil, false, nil))
+
+	if _, err := ht.Trace(serverURL, ""); !errors.Is(err, ErrBlocked) || ErrorCode(err) != ErrorCodeBlocked {
+		t.Errorf("expect loopback url to be blocked but get %v", err)
+	}
+
+	// test server is allowed explicitly, redirects to private addresses are still blocked
+	ht.SetURLPolicy(newTestURLPolicy(t, []string{serverURL.Hostname()}, nil, false, nil))
+
+	if _, err := ht.Trace(serverURL, ""); err != nil {
+		t.Errorf("unexpected error `%s`", err)
+	}
+
+	for _, path := range []string{"/metadata", "/file"} {
+		redirects, err := ht.Trace(serverURL.ResolveReference(&url.URL{Path: path}), "")
+		if !errors.Is(err, ErrBlocked) {
+			t.Errorf("expect redirect of %s to be blocked but get %v", path, err)
+		}
+
+		if len(redirects) != 1 {
+			t.Errorf("expect blocked redirect to be reported but get %d redirects", len(redirects))
+		}
+	}
+}
+
+func TestHTTPTracer_Trace_URLPolicyConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+
+	// host passes the check, but it is connected to loopback address (dns rebinding)
+	policy := newTestURLPolicy(t, nil, nil, false, nil)
+	policy.lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+
+	ht := NewHTTPTracer(&http.Client{Timeout: time.Second})
+	ht.SetURLPolicy(policy)
+
+	rebindURL := *serverURL
+	rebindURL.Host = "localhost:" + serverURL.Port()
+
+	if _, err := ht.Trace(&rebindURL, ""); !errors.Is(err, ErrBlocked) || ErrorCode(err) != ErrorCodeBlocked {
+		t.Errorf("expect connection to loopback address to be blocked but get %v", err)
+	}
+}
+
+func TestChromeTracer_Trace_URLPolicy(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+		policy: newTestURLPolicy(t, nil, nil, false, map[string]string{
+			"step0.test":    "93.184.216.34",
+			"internal.test": "10.0.0.5",
+		}),
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+	mainFrameID := "F394EA807250832376BE81745B17B0E9"
+
+	// sub-resource is blocked, the page is loaded anyway
+	fake.onNavigate = func(f *fakeRemoteDebugger, url string) {
+		f.fire("Fetch.requestPaused", godet.Params{
+			"requestId":    "interception-1",
+			"frameId":      mainFrameID,
+			"resourceType": documentParamName,
+			"request":      map[string]interface{}{"url": "http://step0.test/"},
+		})
+		f.fire("Fetch.requestPaused", godet.Params{
+			"requestId":    "interception-2",
+			"frameId":      mainFrameID,
+			"resourceType": "Image",
+			"request":      map[string]interface{}{"url": "http://internal.test/pixel.gif"},
+		})
+	}
+
+	if _, err := ct.Trace(traceURL, ""); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if fake.sent("Fetch.continueRequest").String("requestId") != "interception-1" {
+		t.Error("expect allowed request to be continued")
+	}
+
+	if failed := fake.sent("Fetch.failRequest"); failed.String("requestId") != "interception-2" || failed.String("errorReason") != "BlockedByClient" {
+		t.Errorf("expect blocked request to be failed but get %v", failed)
+	}
+
+	// main frame redirect is blocked
+	fake = newFakeRemoteDebugger()
+	ct.instance = fake
+	fake.onNavigate = func(f *fakeRemoteDebugger, url string) {
+		f.fire("Fetch.requestPaused", godet.Params{
+			"requestId":    "interception-3",
+			"frameId":      mainFrameID,
+			"resourceType": documentParamName,
+			"request":      map[string]interface{}{"url": "http://internal.test/admin"},
+		})
+	}
+
+	if _, err := ct.Trace(traceURL, ""); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expect trace to be blocked but get %v", err)
+	}
+
+	if _, err := ct.Trace(&url.URL{Scheme: "file", Path: "/etc/passwd"}, ""); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expect file url to be blocked but get %v", err)
+	}
+}
+
+func TestChromeTracer_listenRequests_NotBlocking(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	events := newTraceEvents()
+	release := make(chan struct{})
+
+	ct := &ChromeTracer{
+		instance: fake,
+		policy:   newTestURLPolicy(t, nil, nil, false, map[string]string{"step0.test": "93.184.216.34"}),
+	}
+
+	if err := ct.listenRequests(events); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	// devtools response is delayed
+	fake.onRequest = func(method string) {
+		if method == "Fetch.continueRequest" {
+			<-release
+		}
+	}
+
+	fired := make(chan struct{})
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			fake.fire("Fetch.requestPaused", godet.Params{
+				"requestId":    "interception-" + strconv.Itoa(i),
+				"resourceType": "Image",
+				"request":      map[string]interface{}{"url": "http://step0.test/pixel.gif"},
+			})
+		}
+
+		close(fired)
+	}()
+
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Error("expect events delivery not to be blocked by devtools requests")
+	}
+
+	close(release)
+	events.waitHandlers()
+
+	if fake.sent("Fetch.continueRequest") == nil {
+		t.Error("expect paused requests to be continued")
+	}
+}
+
+func TestChromeTracer_listenRequests_ResolveEveryRequest(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	events := newTraceEvents()
+
+	// the host is resolved to loopback address after the first request
+	policy := newTestURLPolicy(t, nil, nil, false, nil)
+	lookups := 0
+	policy.lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		if lookups == 1 {
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		}
+
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+
+	ct := &ChromeTracer{instance: fake, policy: policy}
+
+	if err := ct.listenRequests(events); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	for i := 1; i <= 2; i++ {
+		fake.fire("Fetch.requestPaused", godet.Params{
+			"requestId":    "interception-" + strconv.Itoa(i),
+			"resourceType": "Image",
+			"request":      map[string]interface{}{"url": "http://rebind.test/pixel.gif"},
+		})
+
+		// wait for the request to be answered, so lookups are ordered
+		for j := 0; j < 100 && fake.sent("Fetch.continueRequest") == nil && fake.sent("Fetch.failRequest") == nil; j++ {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	events.waitHandlers()
+
+	if fake.sent("Fetch.continueRequest").String("requestId") != "interception-1" {
+		t.Error("expect the first request to be continued")
+	}
+
+	if failed := fake.sent("Fetch.failRequest"); failed.String("requestId") != "interception-2" {
+		t.Errorf("expect the request to rebound host to be failed before it is sent but get %v", failed)
+	}
+}
+
+func TestChromeTracer_Trace_URLPolicyRebinding(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+
+	// the host passes checks of the trace url and paused request, then chrome resolves it to loopback address
+	policy := newTestURLPolicy(t, nil, nil, false, nil)
+	lookups := 0
+	policy.lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups++
+		if lookups <= 2 {
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		}
+
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+		policy:       policy,
+	}
+
+	fake.onNavigate = func(f *fakeRemoteDebugger, url string) {
+		f.fire("Fetch.requestPaused", godet.Params{
+			"requestId":    "interception-1",
+			"frameId":      "F394EA807250832376BE81745B17B0E9",
+			"resourceType": documentParamName,
+			"request":      map[string]interface{}{"url": "http://rebind.test/"},
+		})
+
+		// wait for the policy check, chrome resolves the host after it
+		for i := 0; i < 100 && f.sent("Fetch.continueRequest") == nil; i++ {
+			time.Sleep(time.Millisecond)
+		}
+
+		addresses, _ := policy.lookupIP(context.Background(), "rebind.test")
+
+		f.fire("Network.responseReceived", godet.Params{
+			"requestId": "1000.1",
+			"frameId":   "F394EA807250832376BE81745B17B0E9",
+			"type":      documentParamName,
+			"response": map[string]interface{}{
+				"url":             "http://rebind.test/",
+				"status":          float64(200),
+				"remoteIPAddress": addresses[0].IP.String(),
+				"remotePort":      float64(80),
+			},
+		})
+	}
+
+	traceURL, _ := url.Parse("http://rebind.test")
+
+	if _, err := ct.Trace(traceURL, ""); !errors.Is(err, ErrBlocked) || ErrorCode(err) != ErrorCodeBlocked {
+		t.Errorf("expect rebinding host to be blocked but get %v", err)
+	}
+
+	if lookups != 3 {
+		t.Errorf("expect host to be resolved 3 times but get %d lookups", lookups)
+	}
+
+	if fake.sent("Page.stopLoading") == nil {
+		t.Error("expect page loading to be stopped")
+	}
+}
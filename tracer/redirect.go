@@ -6,6 +6,16 @@ import (
 	"time"
 )
 
+// Redirect types describe the way navigation to the next document was made
+const (
+	RedirectTypeHTTP        = "http"
+	RedirectTypeMetaRefresh = "meta-refresh"
+	RedirectTypeJavaScript  = "javascript"
+	RedirectTypeFormPost    = "form-post"
+	RedirectTypeWindowOpen  = "window-open"
+	RedirectTypeOther       = "other"
+)
+
 // Redirect type represent http redirect
 type Redirect struct {
 	From               *url.URL               `json:"from"`
@@ -17,6 +27,27 @@ type Redirect struct {
 	Initiator          string                 `json:"initiator"`
 	OtherInfo          map[string]interface{} `json:"other_info"`
 	ScreenshotFileName string                 `json:"screenshot,omitempty"`
+	Type               string                 `json:"type"`
+	Delay              time.Duration          `json:"delay"`
+	Timing             *Timing                `json:"timing"`
+	// request method and response details of the hop (used by HAR export)
+	Method        string `json:"method"`
+	Protocol      string `json:"protocol"`
+	StatusText    string `json:"status_text"`
+	MimeType      string `json:"mime_type"`
+	RemoteAddress string `json:"remote_address"`
+	// sub-resources requested by `From` document (by `To` document for the final response)
+	Resources []*Resource `json:"resources"`
+	// child frames created by `From` document (by `To` document for the final response)
+	Frames []*Frame `json:"frames"`
+	// tracer stopped and didn't follow `To` url (hops limit is exceeded or the chain is looped)
+	Truncated bool `json:"truncated"`
+}
+
+// Frame represent child frame (iframe) with its own redirects chain
+type Frame struct {
+	ID        string      `json:"id"`
+	Redirects []*Redirect `json:"redirects"`
 }
 
 // NewRedirect combine data from http request and response to create
@@ -44,6 +75,23 @@ type JSONRedirect struct {
 	Initiator          string                 `json:"initiator"`
 	OtherInfo          map[string]interface{} `json:"other_info"`
 	ScreenshotFileName string                 `json:"screenshot,omitempty"`
+	Type               string                 `json:"type"`
+	Delay              int64                  `json:"delay_ms"` // time spent on the page before the hop
+	Timing             *JSONTiming            `json:"timing,omitempty"`
+	Method             string                 `json:"method,omitempty"`
+	Protocol           string                 `json:"protocol,omitempty"`
+	StatusText         string                 `json:"status_text,omitempty"`
+	MimeType           string                 `json:"mime_type,omitempty"`
+	RemoteAddress      string                 `json:"remote_address,omitempty"`
+	Resources          []*Resource            `json:"resources,omitempty"`
+	Frames             []*JSONFrame           `json:"frames,omitempty"`
+	Truncated          bool                   `json:"truncated,omitempty"`
+}
+
+// JSONFrame used to transform Frame type into json string
+type JSONFrame struct {
+	ID        string          `json:"id"`
+	Redirects []*JSONRedirect `json:"redirects"`
 }
 
 // NewJSONRedirects transform slice of `Redirect`s to slice of `jsonRedirect`s
@@ -81,9 +129,35 @@ func NewJSONRedirect(r *Redirect) *JSONRedirect {
 		Initiator:          r.Initiator,
 		OtherInfo:          r.OtherInfo,
 		ScreenshotFileName: r.ScreenshotFileName,
+		Type:               r.Type,
+		Delay:              r.Delay.Milliseconds(),
+		Timing:             NewJSONTiming(r.Timing),
+		Method:             r.Method,
+		Protocol:           r.Protocol,
+		StatusText:         r.StatusText,
+		MimeType:           r.MimeType,
+		RemoteAddress:      r.RemoteAddress,
+		Resources:          r.Resources,
+		Frames:             NewJSONFrames(r.Frames),
+		Truncated:          r.Truncated,
 	}
 }
 
+// NewJSONFrames transform slice of `Frame`s to slice of `JSONFrame`s
+func NewJSONFrames(frames []*Frame) []*JSONFrame {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	jsonFrames := make([]*JSONFrame, 0, len(frames))
+
+	for _, f := range frames {
+		jsonFrames = append(jsonFrames, &JSONFrame{ID: f.ID, Redirects: NewJSONRedirects(f.Redirects)})
+	}
+
+	return jsonFrames
+}
+
 // JSONCookie transform http.Cookie into json string
 type JSONCookie struct {
 	Name  string `json:"name"`
@@ -0,0 +1,126 @@
+package tracer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/raff/godet"
+)
+
+const (
+	userAgentHeaderName      = "User-Agent"
+	refererHeaderName        = "Referer"
+	acceptLanguageHeaderName = "Accept-Language"
+)
+
+const (
+	errorMessageInvalidHeader = "invalid header `%s`. expected format `Name: value`"
+	errorMessageInvalidCookie = "invalid cookie `%s`. expected format `name=value`"
+)
+
+// RequestOptions describe custom settings of the traced url request.
+// User agent, Accept-Language and extra headers are sent with every request of the chain,
+// referer is sent with the traced url request (and kept through server side redirects),
+// cookies are set before navigation
+type RequestOptions struct {
+	UserAgent      string
+	Referer        string
+	AcceptLanguage string
+	Headers        http.Header
+	Cookies        []*http.Cookie
+}
+
+// ParseHeader parse `Name: value` header line
+func ParseHeader(line string) (string, string, error) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return "", "", fmt.Errorf(errorMessageInvalidHeader, line)
+	}
+
+	return http.CanonicalHeaderKey(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1]), nil
+}
+
+// ParseRequestCookies parse cookies in `Cookie` header format (`name=value; name2=value2`)
+func ParseRequestCookies(line string) ([]*http.Cookie, error) {
+	for _, pair := range strings.Split(line, ";") {
+		if !strings.Contains(pair, "=") {
+			return nil, fmt.Errorf(errorMessageInvalidCookie, line)
+		}
+	}
+
+	cookies := (&http.Request{Header: http.Header{"Cookie": {line}}}).Cookies()
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf(errorMessageInvalidCookie, line)
+	}
+
+	return cookies, nil
+}
+
+// isEmpty check if no custom settings are provided
+func (o *RequestOptions) isEmpty() bool {
+	return o == nil || (o.UserAgent == "" && o.Referer == "" && o.AcceptLanguage == "" &&
+		len(o.Headers) == 0 && len(o.Cookies) == 0)
+}
+
+// extraHeaders return headers which are sent with every request of the chain
+// (user agent and referer are set separately)
+func (o *RequestOptions) extraHeaders() map[string]string {
+	headers := make(map[string]string)
+
+	for name := range o.Headers {
+		headers[name] = o.Headers.Get(name)
+	}
+
+	if o.AcceptLanguage != "" {
+		headers[acceptLanguageHeaderName] = o.AcceptLanguage
+	}
+
+	return headers
+}
+
+// apply set custom headers to the first http request of the chain
+func (o *RequestOptions) apply(req *http.Request) {
+	for name, value := range o.extraHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	if o.UserAgent != "" {
+		req.Header.Set(userAgentHeaderName, o.UserAgent)
+	}
+
+	if o.Referer != "" {
+		req.Header.Set(refererHeaderName, o.Referer)
+	}
+}
+
+// cookieParams convert cookies to `Network.setCookies` params.
+// Cookies without domain are bound to the traced url
+func (o *RequestOptions) cookieParams(target *url.URL) []godet.Params {
+	params := make([]godet.Params, 0, len(o.Cookies))
+
+	for _, c := range o.Cookies {
+		cookie := godet.Params{
+			"name":     c.Name,
+			"value":    c.Value,
+			"secure":   c.Secure,
+			"httpOnly": c.HttpOnly,
+		}
+
+		if c.Domain != "" {
+			cookie["domain"] = c.Domain
+			cookie["path"] = "/"
+		} else {
+			cookie["url"] = target.String()
+		}
+
+		if c.Path != "" {
+			cookie["path"] = c.Path
+		}
+
+		params = append(params, cookie)
+	}
+
+	return params
+}
@@ -0,0 +1,143 @@
+package tracer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func testRequestOptions() *RequestOptions {
+	return &RequestOptions{
+		UserAgent:      "redirective-test",
+		Referer:        "http://referer.test/ad",
+		AcceptLanguage: "de-DE",
+		Headers:        http.Header{"X-Test": {"redirective"}},
+		Cookies:        []*http.Cookie{{Name: "session", Value: "abc"}},
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	name, value, err := ParseHeader("x-forwarded-for:  10.0.0.1 ")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if name != "X-Forwarded-For" || value != "10.0.0.1" {
+		t.Errorf("invalid header. expect X-Forwarded-For: 10.0.0.1 but get %s: %s", name, value)
+	}
+
+	for _, line := range []string{"X-Test", ": value", ""} {
+		if _, _, err := ParseHeader(line); err == nil {
+			t.Errorf("expect error for header `%s`", line)
+		}
+	}
+}
+
+func TestParseRequestCookies(t *testing.T) {
+	cookies, err := ParseRequestCookies("foo=bar; session=abc")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(cookies) != 2 || cookies[0].Name != "foo" || cookies[1].Value != "abc" {
+		t.Errorf("expect foo and session cookies but get %v", cookies)
+	}
+
+	for _, line := range []string{"foo", "foo=bar; baz", ""} {
+		if _, err := ParseRequestCookies(line); err == nil {
+			t.Errorf("expect error for cookie `%s`", line)
+		}
+	}
+}
+
+func TestHTTPTracer_SetRequestOptions(t *testing.T) {
+	received := make(map[string]*http.Request)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/step0", func(w http.ResponseWriter, r *http.Request) {
+		received[r.URL.Path] = r
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		received[r.URL.Path] = r
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	traceURL, _ := url.Parse(server.URL + "/step0")
+
+	ht := NewHTTPTracer(nil)
+	ht.SetRequestOptions(testRequestOptions())
+
+	redirects, err := ht.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 {
+		t.Fatalf("expect 2 redirects but get %d", len(redirects))
+	}
+
+	for path, r := range received {
+		if r.UserAgent() != "redirective-test" || r.Header.Get("Accept-Language") != "de-DE" || r.Header.Get("X-Test") != "redirective" {
+			t.Errorf("expect custom headers to be sent to %s but get %v", path, r.Header)
+		}
+
+		if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+			t.Errorf("expect session cookie to be sent to %s", path)
+		}
+	}
+
+	// referer is kept through server side redirects like browser does
+	for path, r := range received {
+		if referer := r.Referer(); referer != "http://referer.test/ad" {
+			t.Errorf("invalid %s request referer. expect http://referer.test/ad but get %s", path, referer)
+		}
+	}
+
+	if ua := redirects[0].RequestHeaders.Get("User-Agent"); ua != "redirective-test" {
+		t.Errorf("expect custom user agent to be reported but get %s", ua)
+	}
+}
+
+func TestChromeTracer_SetRequestOptions(t *testing.T) {
+	traceURL, _ := url.Parse("http://step0.test")
+	fake := newFakeRemoteDebugger()
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	device, _ := DeviceByName(DeviceIPhone)
+	ct.SetDevice(device)
+	ct.SetRequestOptions(testRequestOptions())
+
+	if _, err := ct.Trace(traceURL, ""); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if ua := fake.sent("Emulation.setUserAgentOverride").String("userAgent"); ua != "redirective-test" {
+		t.Errorf("expect custom user agent to override device one but get %s", ua)
+	}
+
+	headers, _ := fake.sent("Network.setExtraHTTPHeaders")["headers"].(map[string]string)
+	if headers["Accept-Language"] != "de-DE" || headers["X-Test"] != "redirective" {
+		t.Errorf("invalid extra headers %v", headers)
+	}
+
+	navigation := fake.sent("Page.navigate")
+	if navigation.String("url") != "http://step0.test" || navigation.String("referrer") != "http://referer.test/ad" {
+		t.Errorf("expect navigation with referrer but get %v", navigation)
+	}
+
+	cookies := ct.request.cookieParams(traceURL)
+	if len(fake.sent("Network.setCookies")) == 0 || len(cookies) != 1 || cookies[0]["url"] != "http://step0.test" {
+		t.Errorf("expect session cookie bound to traced url but get %v", cookies)
+	}
+}
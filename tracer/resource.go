@@ -0,0 +1,72 @@
+package tracer
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/raff/godet"
+)
+
+// Resource describe sub-resource request (image, script, xhr, beacon, iframe...)
+// made by a document of the chain. Resources are captured only if network log is enabled
+type Resource struct {
+	URL        string `json:"url"`
+	Type       string `json:"type"` // devtools resource type (Image, Script, XHR, Fetch, Ping, Document for iframes...)
+	Method     string `json:"method"`
+	Status     int    `json:"status"`
+	Size       int64  `json:"size"` // transferred bytes
+	Initiator  string `json:"initiator"`
+	ThirdParty bool   `json:"third_party"` // resource site differs from the document one
+	Error      string `json:"error,omitempty"`
+
+	// index of main frame document request which was loaded when resource was requested
+	documentIndex int
+}
+
+// newResourceFromRaw create resource from `Network.requestWillBeSent` params
+func newResourceFromRaw(params godet.Params, documentIndex int) *Resource {
+	request := godet.Params(params.Map("request"))
+
+	return &Resource{
+		URL:           request.String("url"),
+		Type:          params.String("type"),
+		Method:        request.String("method"),
+		Initiator:     godet.Params(params.Map("initiator")).String("type"),
+		documentIndex: documentIndex,
+	}
+}
+
+// markThirdParty set ThirdParty flag of resources requested from another site than the document
+func markThirdParty(resources []*Resource, documentURL string) {
+	document, err := url.Parse(documentURL)
+	if err != nil {
+		return
+	}
+
+	for _, r := range resources {
+		u, err := url.Parse(r.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		r.ThirdParty = siteOf(u.Hostname()) != siteOf(document.Hostname())
+	}
+}
+
+// siteOf return the last two labels of the host name (ip addresses are returned as is).
+// It is an approximation of registrable domain which doesn't take public suffixes into account
+func siteOf(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+
+	return strings.Join(labels[len(labels)-2:], ".")
+}
@@ -0,0 +1,130 @@
+package tracer
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func makeTestResourceRequest(requestID, rawURL, resourceType, initiator string) godet.Params {
+	params := makeTestDocumentRequest(requestID, rawURL, http.MethodGet, initiator, 10.2)
+	params["type"] = resourceType
+
+	return params
+}
+
+func fireTestNetworkLogEvents(f *fakeRemoteDebugger, _ string) {
+	f.fire("Network.requestWillBeSent", makeTestDocumentRequest("1", "http://step0.test", http.MethodGet, "other", 10))
+	f.fire("Network.responseReceived", makeTestDocumentResponse("1", 10.1))
+
+	// tracking pixel redirected to another domain
+	f.fire("Network.requestWillBeSent", makeTestResourceRequest("2", "http://pixel.tracker.test/p.gif", "Image", "parser"))
+
+	redirectedPixel := makeTestResourceRequest("2", "http://tracker2.test/p.gif", "Image", "parser")
+	redirectedPixel["redirectResponse"] = map[string]interface{}{"status": 302.0}
+	f.fire("Network.requestWillBeSent", redirectedPixel)
+	f.fire("Network.responseReceived", godet.Params{"requestId": "2", "type": "Image", "response": map[string]interface{}{"status": 200.0}})
+	f.fire("Network.loadingFinished", godet.Params{"requestId": "2", "encodedDataLength": 43.0})
+
+	// blocked script of the same site
+	f.fire("Network.requestWillBeSent", makeTestResourceRequest("3", "http://cdn.step0.test/app.js", "Script", "parser"))
+	f.fire("Network.loadingFailed", godet.Params{"requestId": "3", "errorText": "net::ERR_FAILED", "blockedReason": "inspector"})
+
+	f.fire("Network.requestWillBeSent", makeTestDocumentRequest("4", "http://step1.test", http.MethodGet, "script", 11))
+	f.fire("Network.responseReceived", makeTestDocumentResponse("4", 11.5))
+
+	f.fire("Network.requestWillBeSent", makeTestResourceRequest("5", "http://step1.test/api", "XHR", "script"))
+	f.fire("Network.responseReceived", godet.Params{"requestId": "5", "type": "XHR", "response": map[string]interface{}{"status": 204.0}})
+}
+
+func TestChromeTracer_Trace_NetworkLog(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = fireTestNetworkLogEvents
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+	ct.SetNetworkLog(true)
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if len(redirects) != 2 {
+		t.Fatalf("expect 2 redirects but get %d", len(redirects))
+	}
+
+	resources := redirects[0].Resources
+	if len(resources) != 3 {
+		t.Fatalf("expect 3 resources of the first page but get %d", len(resources))
+	}
+
+	pixel := resources[0]
+	if pixel.Type != "Image" || pixel.Status != 302 || !pixel.ThirdParty || pixel.Initiator != "parser" {
+		t.Errorf("invalid redirected pixel resource %+v", pixel)
+	}
+
+	if resources[1].URL != "http://tracker2.test/p.gif" || resources[1].Status != 200 || resources[1].Size != 43 {
+		t.Errorf("invalid pixel resource %+v", resources[1])
+	}
+
+	if resources[2].ThirdParty || resources[2].Error != "net::ERR_FAILED (inspector)" {
+		t.Errorf("invalid blocked script resource %+v", resources[2])
+	}
+
+	final := redirects[1].Resources
+	if len(final) != 1 || final[0].Type != "XHR" || final[0].Status != 204 || final[0].ThirdParty {
+		t.Errorf("invalid resources of the final page %+v", final)
+	}
+}
+
+func TestChromeTracer_Trace_NetworkLogDisabled(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	fake.onNavigate = fireTestNetworkLogEvents
+
+	ct := &ChromeTracer{
+		instance:     fake,
+		size:         NewScreenSize(1920, 1080),
+		waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 10 * time.Millisecond},
+	}
+
+	traceURL, _ := url.Parse("http://step0.test")
+
+	redirects, err := ct.Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	for i, r := range redirects {
+		if len(r.Resources) != 0 {
+			t.Errorf("expect no resources of hop %d if network log is disabled", i)
+		}
+	}
+}
+
+func Test_siteOf(t *testing.T) {
+	for host, site := range map[string]string{
+		"example.com":           "example.com",
+		"www.example.com":       "example.com",
+		"a.b.cdn.example.com.":  "example.com",
+		"localhost":             "localhost",
+		"192.0.2.1":             "192.0.2.1",
+		"WWW.Example.COM":       "example.com",
+		"pixel.tracker.test":    "tracker.test",
+		"2001:db8::1":           "2001:db8::1",
+		"static.step0.test":     "step0.test",
+		"static.step0.test.com": "test.com",
+	} {
+		if result := siteOf(host); result != site {
+			t.Errorf("invalid site of %s. expect %s but get %s", host, site, result)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package tracer
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+// Timing describe time spent on network phases of a single hop.
+// Hop timing is a timing of the request which loaded `From` document
+type Timing struct {
+	// Start is an offset of the hop request from the first hop request (waterfall position)
+	Start time.Duration
+	// DNS is a domain name lookup time
+	DNS time.Duration
+	// Connect is a TCP connection time (TLS handshake excluded)
+	Connect time.Duration
+	// TLS is a TLS handshake time
+	TLS time.Duration
+	// Send is a time spent on sending the request
+	Send time.Duration
+	// TTFB (wait) is a time from request sent till first response byte received
+	TTFB time.Duration
+	// Receive is a time spent on downloading response body (0 for 3xx responses)
+	Receive time.Duration
+	// Total is a hop latency from request start till response is loaded
+	Total time.Duration
+
+	// requestTime is a hop start time in seconds, used to calculate Start and Receive
+	requestTime float64
+}
+
+// JSONTiming used to transform Timing type into json string (milliseconds)
+type JSONTiming struct {
+	Start   float64 `json:"start_ms"`
+	DNS     float64 `json:"dns_ms"`
+	Connect float64 `json:"connect_ms"`
+	TLS     float64 `json:"tls_ms"`
+	Send    float64 `json:"send_ms"`
+	TTFB    float64 `json:"ttfb_ms"`
+	Receive float64 `json:"receive_ms"`
+	Total   float64 `json:"total_ms"`
+}
+
+// ChainTiming summarize timing of the whole redirects chain
+type ChainTiming struct {
+	// Total is a time from the first hop request till the last response is loaded (including delays)
+	Total time.Duration
+	// Network is a sum of hops latency
+	Network time.Duration
+	// Delay is a sum of time spent on pages before client side redirects
+	Delay time.Duration
+	// SlowestHop is an index of the hop with the biggest latency (-1 if there is no timing)
+	SlowestHop int
+}
+
+// JSONChainTiming used to transform ChainTiming type into json string (milliseconds)
+type JSONChainTiming struct {
+	Total      float64 `json:"total_ms"`
+	Network    float64 `json:"network_ms"`
+	Delay      float64 `json:"delay_ms"`
+	SlowestHop int     `json:"slowest_hop"`
+}
+
+// NewJSONTiming convert Timing to JSONTiming, nil timing stays nil
+func NewJSONTiming(t *Timing) *JSONTiming {
+	if t == nil {
+		return nil
+	}
+
+	return &JSONTiming{
+		Start:   durationToMilliseconds(t.Start),
+		DNS:     durationToMilliseconds(t.DNS),
+		Connect: durationToMilliseconds(t.Connect),
+		TLS:     durationToMilliseconds(t.TLS),
+		Send:    durationToMilliseconds(t.Send),
+		TTFB:    durationToMilliseconds(t.TTFB),
+		Receive: durationToMilliseconds(t.Receive),
+		Total:   durationToMilliseconds(t.Total),
+	}
+}
+
+// NewChainTiming calculate timing summary of the redirects chain
+func NewChainTiming(redirects []*Redirect) *ChainTiming {
+	chain := &ChainTiming{SlowestHop: -1}
+
+	var slowest time.Duration
+
+	for i, r := range redirects {
+		chain.Delay += r.Delay
+
+		if r.Timing == nil {
+			continue
+		}
+
+		chain.Network += r.Timing.Total
+
+		if end := r.Timing.Start + r.Timing.Total; end > chain.Total {
+			chain.Total = end
+		}
+
+		if r.Timing.Total > slowest {
+			slowest = r.Timing.Total
+			chain.SlowestHop = i
+		}
+	}
+
+	return chain
+}
+
+// NewJSONChainTiming convert ChainTiming to JSONChainTiming
+func NewJSONChainTiming(c *ChainTiming) *JSONChainTiming {
+	return &JSONChainTiming{
+		Total:      durationToMilliseconds(c.Total),
+		Network:    durationToMilliseconds(c.Network),
+		Delay:      durationToMilliseconds(c.Delay),
+		SlowestHop: c.SlowestHop,
+	}
+}
+
+// setTimingOffsets calculate hops Start offsets relative to the first hop request.
+// Hops of child frames chains are positioned relative to the same first hop
+func setTimingOffsets(redirects []*Redirect) {
+	timings := collectTimings(redirects, nil)
+	first := 0.0
+
+	for _, t := range timings {
+		if t.requestTime > 0 && (first == 0 || t.requestTime < first) {
+			first = t.requestTime
+		}
+	}
+
+	for _, t := range timings {
+		if t.requestTime > 0 {
+			t.Start = secondsToDuration(t.requestTime - first)
+		}
+	}
+}
+
+// collectTimings return timings of all hops including child frames hops
+func collectTimings(redirects []*Redirect, timings []*Timing) []*Timing {
+	for _, r := range redirects {
+		if r.Timing != nil {
+			timings = append(timings, r.Timing)
+		}
+
+		for _, f := range r.Frames {
+			timings = collectTimings(f.Redirects, timings)
+		}
+	}
+
+	return timings
+}
+
+// finish add response body download time, finishedAt is a loading finished time in seconds
+func (t *Timing) finish(finishedAt float64) {
+	if t == nil || t.requestTime == 0 || finishedAt == 0 {
+		return
+	}
+
+	headersReceived := t.requestTime + t.Total.Seconds()
+	if finishedAt <= headersReceived {
+		return
+	}
+
+	t.Receive = secondsToDuration(finishedAt - headersReceived)
+	t.Total += t.Receive
+}
+
+func durationToMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// parseTimingFromRaw convert `Network.ResourceTiming` of the response to Timing.
+// Chrome reports phases as milliseconds offsets from request start, -1 means phase didn't happen
+func parseTimingFromRaw(response map[string]interface{}) *Timing {
+	rawTiming, ok := response["timing"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	timing := godet.Params(rawTiming)
+	phase := func(start, end string) time.Duration {
+		startValue, startOk := timing[start].(float64)
+		endValue, endOk := timing[end].(float64)
+
+		if !startOk || !endOk || startValue < 0 || endValue < startValue {
+			return 0
+		}
+
+		return time.Duration((endValue - startValue) * float64(time.Millisecond))
+	}
+
+	receiveHeadersEnd, _ := timing["receiveHeadersEnd"].(float64)
+	requestTime, _ := timing["requestTime"].(float64)
+
+	t := &Timing{
+		DNS:         phase("dnsStart", "dnsEnd"),
+		TLS:         phase("sslStart", "sslEnd"),
+		Send:        phase("sendStart", "sendEnd"),
+		TTFB:        phase("sendEnd", "receiveHeadersEnd"),
+		requestTime: requestTime,
+	}
+
+	if receiveHeadersEnd > 0 {
+		t.Total = time.Duration(receiveHeadersEnd * float64(time.Millisecond))
+	}
+
+	// chrome connect phase includes TLS handshake
+	t.Connect = phase("connectStart", "connectEnd") - t.TLS
+	if t.Connect < 0 {
+		t.Connect = 0
+	}
+
+	return t
+}
+
+// timingTransport collect Timing of every request made by wrapped transport
+type timingTransport struct {
+	sync.Mutex
+	next    http.RoundTripper
+	timings map[*http.Request]*Timing
+}
+
+func newTimingTransport(next http.RoundTripper) *timingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &timingTransport{
+		next:    next,
+		timings: make(map[*http.Request]*Timing),
+	}
+}
+
+// RoundTrip execute request with httptrace hooks measuring network phases
+func (tt *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timing := &Timing{}
+	// hooks could be called from dialing goroutines
+	mu := sync.Mutex{}
+	measure := func(d *time.Duration, start *time.Time) {
+		mu.Lock()
+		*d = time.Since(*start)
+		mu.Unlock()
+	}
+	mark := func(t *time.Time) {
+		mu.Lock()
+		*t = time.Now()
+		mu.Unlock()
+	}
+
+	var dnsStart, connectStart, tlsStart, gotConn, wroteRequest time.Time
+
+	started := time.Now()
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { measure(&timing.DNS, &dnsStart) },
+		ConnectStart:      func(string, string) { mark(&connectStart) },
+		ConnectDone:       func(string, string, error) { measure(&timing.Connect, &connectStart) },
+		TLSHandshakeStart: func() { mark(&tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { measure(&timing.TLS, &tlsStart) },
+		GotConn:           func(httptrace.GotConnInfo) { mark(&gotConn) },
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mark(&wroteRequest)
+			measure(&timing.Send, &gotConn)
+		},
+		GotFirstResponseByte: func() { measure(&timing.TTFB, &wroteRequest) },
+	}
+
+	tracedReq := req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := tt.next.RoundTrip(tracedReq)
+	if resp != nil {
+		// keep original request in response, so timing could be found by it
+		resp.Request = req
+	}
+
+	mu.Lock()
+	measured := *timing
+	mu.Unlock()
+
+	measured.Total = time.Since(started)
+	measured.requestTime = float64(started.UnixNano()) / float64(time.Second)
+
+	tt.Lock()
+	tt.timings[req] = &measured
+	tt.Unlock()
+
+	return resp, err
+}
+
+// timing return collected Timing of the request
+func (tt *timingTransport) timing(req *http.Request) *Timing {
+	tt.Lock()
+	defer tt.Unlock()
+
+	return tt.timings[req]
+}
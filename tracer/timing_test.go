@@ -0,0 +1,195 @@
+package tracer
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_parseTimingFromRaw(t *testing.T) {
+	response := map[string]interface{}{
+		"timing": map[string]interface{}{
+			"dnsStart":          0.1,
+			"dnsEnd":            10.1,
+			"connectStart":      10.1,
+			"connectEnd":        60.1,
+			"sslStart":          30.1,
+			"sslEnd":            60.1,
+			"sendStart":         60.2,
+			"sendEnd":           60.5,
+			"receiveHeadersEnd": 160.5,
+			"proxyStart":        -1.0,
+			"proxyEnd":          -1.0,
+			"requestTime":       1000.5,
+		},
+	}
+
+	timing := parseTimingFromRaw(response)
+	if timing == nil {
+		t.Fatal("expect timing to be parsed")
+	}
+
+	expected := &Timing{
+		DNS:     10 * time.Millisecond,
+		Connect: 20 * time.Millisecond,
+		TLS:     30 * time.Millisecond,
+		Send:    300 * time.Microsecond,
+		TTFB:    100 * time.Millisecond,
+		Total:   160500 * time.Microsecond,
+	}
+
+	for name, values := range map[string][2]time.Duration{
+		"DNS":     {expected.DNS, timing.DNS},
+		"Connect": {expected.Connect, timing.Connect},
+		"TLS":     {expected.TLS, timing.TLS},
+		"Send":    {expected.Send, timing.Send},
+		"TTFB":    {expected.TTFB, timing.TTFB},
+		"Total":   {expected.Total, timing.Total},
+	} {
+		if (values[0] - values[1]).Round(time.Millisecond) != 0 {
+			t.Errorf("invalid %s timing. expect %s but get %s", name, values[0], values[1])
+		}
+	}
+
+	if timing.requestTime != 1000.5 {
+		t.Errorf("invalid request time. expect 1000.5 but get %f", timing.requestTime)
+	}
+}
+
+func TestTiming_finish(t *testing.T) {
+	timing := &Timing{Total: 100 * time.Millisecond, requestTime: 1000}
+
+	// loading finished before headers received timestamp is ignored
+	timing.finish(1000.05)
+
+	if timing.Receive != 0 || timing.Total != 100*time.Millisecond {
+		t.Errorf("expect timing not to be changed but get %+v", timing)
+	}
+
+	timing.finish(1000.3)
+
+	if timing.Receive.Round(time.Millisecond) != 200*time.Millisecond {
+		t.Errorf("invalid receive time. expect 200ms but get %s", timing.Receive)
+	}
+
+	if timing.Total.Round(time.Millisecond) != 300*time.Millisecond {
+		t.Errorf("invalid total time. expect 300ms but get %s", timing.Total)
+	}
+
+	// nil timing (window.open hops) is ignored
+	var empty *Timing
+	empty.finish(1000)
+}
+
+func TestNewChainTiming(t *testing.T) {
+	redirects := []*Redirect{
+		{Timing: &Timing{Total: 100 * time.Millisecond, requestTime: 10}},
+		{Timing: &Timing{Total: 300 * time.Millisecond, requestTime: 10.1}},
+		{Delay: 2 * time.Second},
+		{Delay: time.Second, Timing: &Timing{Total: 50 * time.Millisecond, requestTime: 13.4}},
+	}
+
+	setTimingOffsets(redirects)
+
+	if redirects[0].Timing.Start != 0 || redirects[3].Timing.Start.Round(time.Millisecond) != 3400*time.Millisecond {
+		t.Errorf("invalid hops start offsets %s, %s", redirects[0].Timing.Start, redirects[3].Timing.Start)
+	}
+
+	chain := NewChainTiming(redirects)
+
+	if chain.Total.Round(time.Millisecond) != 3450*time.Millisecond {
+		t.Errorf("invalid chain total time. expect 3.45s but get %s", chain.Total)
+	}
+
+	if chain.Network.Round(time.Millisecond) != 450*time.Millisecond {
+		t.Errorf("invalid chain network time. expect 450ms but get %s", chain.Network)
+	}
+
+	if chain.Delay != 3*time.Second {
+		t.Errorf("invalid chain delay. expect 3s but get %s", chain.Delay)
+	}
+
+	if chain.SlowestHop != 1 {
+		t.Errorf("invalid slowest hop. expect 1 but get %d", chain.SlowestHop)
+	}
+
+	if NewChainTiming(nil).SlowestHop != -1 {
+		t.Error("expect slowest hop to be -1 for empty chain")
+	}
+}
+
+func Test_parseTimingFromRaw_Reused(t *testing.T) {
+	// reused connection: no dns, connect and ssl phases
+	timing := parseTimingFromRaw(map[string]interface{}{
+		"timing": map[string]interface{}{
+			"dnsStart":          -1.0,
+			"dnsEnd":            -1.0,
+			"connectStart":      -1.0,
+			"connectEnd":        -1.0,
+			"sslStart":          -1.0,
+			"sslEnd":            -1.0,
+			"sendEnd":           0.5,
+			"receiveHeadersEnd": 20.5,
+		},
+	})
+
+	if timing.DNS != 0 || timing.Connect != 0 || timing.TLS != 0 {
+		t.Errorf("expect skipped phases to be 0 but get %+v", timing)
+	}
+
+	if timing.TTFB.Round(time.Millisecond) != 20*time.Millisecond {
+		t.Errorf("invalid TTFB timing. expect 20ms but get %s", timing.TTFB)
+	}
+}
+
+func Test_parseTimingFromRaw_NoTiming(t *testing.T) {
+	if parseTimingFromRaw(map[string]interface{}{}) != nil {
+		t.Error("expect nil timing if response has no timing")
+	}
+}
+
+func TestNewJSONTiming(t *testing.T) {
+	if NewJSONTiming(nil) != nil {
+		t.Error("expect nil json timing for nil timing")
+	}
+
+	jsonTiming := NewJSONTiming(&Timing{DNS: 1500 * time.Microsecond, TTFB: 20 * time.Millisecond})
+	if jsonTiming.DNS != 1.5 || jsonTiming.TTFB != 20 {
+		t.Errorf("invalid json timing %+v", jsonTiming)
+	}
+}
+
+func TestHTTPTracer_Trace_Timing(t *testing.T) {
+	server := newRedirectTestServer()
+	defer server.Close()
+
+	traceURL, _ := url.Parse(server.URL + "/step0")
+
+	redirects, err := NewHTTPTracer(nil).Trace(traceURL, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	for i, redirect := range redirects {
+		if redirect.Timing == nil {
+			t.Fatalf("expect timing of hop %d to be measured", i)
+		}
+
+		if redirect.Timing.TTFB <= 0 {
+			t.Errorf("expect TTFB of hop %d to be positive", i)
+		}
+
+		if redirect.Timing.Total < redirect.Timing.TTFB {
+			t.Errorf("expect total time of hop %d to include TTFB", i)
+		}
+
+		if i > 0 && redirect.Timing.Start < redirects[i-1].Timing.Start {
+			t.Errorf("expect hop %d to start after the previous one", i)
+		}
+	}
+
+	// first request opens new connection
+	if redirects[0].Timing.Connect <= 0 {
+		t.Error("expect connect time of the first hop to be positive")
+	}
+}
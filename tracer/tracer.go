@@ -5,6 +5,6 @@ import "net/url"
 
 // Tracer interface represent required list of function for http tracers
 type Tracer interface {
-	Trace(url *url.URL) ([]*Redirect, error)
-	Screenshot(url *url.URL, size *ScreenSize, path string) error
+	Trace(url *url.URL, fileName string) ([]*Redirect, error)
+	Screenshot(url *url.URL, size *ScreenSize, fileName string) error
 }
@@ -0,0 +1,158 @@
+package tracer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Wait strategies define how tracer decides that the page is settled
+const (
+	// WaitTimeout just waits for the provided amount of time
+	WaitTimeout = "timeout"
+	// WaitLoad waits for `load` event of the last document in main frame
+	WaitLoad = "load"
+	// WaitNetworkIdle waits until there are no network requests for IdleTime
+	WaitNetworkIdle = "idle"
+	// WaitSelector waits until the element matching CSS selector appears on the page
+	WaitSelector = "selector"
+)
+
+// DefaultWaitTimeout is used when wait timeout isn't provided
+const DefaultWaitTimeout = 5 * time.Second
+
+// DefaultWaitIdleTime is used by network idle strategy when idle time isn't provided
+const DefaultWaitIdleTime = 500 * time.Millisecond
+
+const waitPollInterval = 100 * time.Millisecond
+
+const (
+	errorMessageUnknownWaitStrategy   = "unknown wait strategy"
+	errorMessageWaitSelectorRequired  = "selector is required for selector wait strategy"
+	errorMessageInvalidWaitTimeout    = "wait timeout should be positive"
+	errorMessageInvalidWaitIdleTime   = "wait idle time should be positive"
+	errorMessageWaitIdleTimeTooLarge  = "wait idle time should be less than timeout"
+	errorMessageWaitStrategyNotExists = "wait strategy not exists"
+)
+
+// WaitStrategy describe how long tracer waits for the page to settle before capturing results.
+// Timeout is a hard limit for every strategy, results captured so far are returned when it expires
+type WaitStrategy struct {
+	Type     string        `json:"type"`
+	Timeout  time.Duration `json:"timeout"`
+	IdleTime time.Duration `json:"idle_time,omitempty"`
+	Selector string        `json:"selector,omitempty"`
+}
+
+// NewWaitStrategy validate params and create new WaitStrategy instance.
+// Zero timeout and idle time are replaced with default values
+func NewWaitStrategy(waitType string, timeout, idleTime time.Duration, selector string) (*WaitStrategy, error) {
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+
+	if timeout < 0 {
+		return nil, errors.New(errorMessageInvalidWaitTimeout)
+	}
+
+	switch waitType {
+	case WaitTimeout, WaitLoad:
+	case WaitNetworkIdle:
+		if idleTime == 0 {
+			idleTime = DefaultWaitIdleTime
+		}
+
+		if idleTime < 0 {
+			return nil, errors.New(errorMessageInvalidWaitIdleTime)
+		}
+
+		if idleTime >= timeout {
+			return nil, errors.New(errorMessageWaitIdleTimeTooLarge)
+		}
+	case WaitSelector:
+		if selector == "" {
+			return nil, errors.New(errorMessageWaitSelectorRequired)
+		}
+	default:
+		return nil, fmt.Errorf("%s `%s`", errorMessageUnknownWaitStrategy, waitType)
+	}
+
+	return &WaitStrategy{
+		Type:     waitType,
+		Timeout:  timeout,
+		IdleTime: idleTime,
+		Selector: selector,
+	}, nil
+}
+
+// DefaultWaitStrategy waits DefaultWaitTimeout before capturing results
+func DefaultWaitStrategy() *WaitStrategy {
+	return &WaitStrategy{
+		Type:    WaitTimeout,
+		Timeout: DefaultWaitTimeout,
+	}
+}
+
+// wait blocks until the page is settled according to wait strategy or timeout expires
+func (ct *ChromeTracer) wait(events *traceEvents) error {
+	if ct.waitStrategy == nil {
+		return errors.New(errorMessageWaitStrategyNotExists)
+	}
+
+	deadline := time.Now().Add(ct.waitStrategy.Timeout)
+
+	for time.Now().Before(deadline) {
+		// there is nothing to wait for if the chain is truncated
+		if events.isStopped() {
+			return nil
+		}
+
+		settled, err := ct.isSettled(events)
+		if err != nil {
+			return err
+		}
+
+		if settled {
+			return nil
+		}
+
+		time.Sleep(minDuration(waitPollInterval, time.Until(deadline)))
+	}
+
+	return nil
+}
+
+func (ct *ChromeTracer) isSettled(events *traceEvents) (bool, error) {
+	switch ct.waitStrategy.Type {
+	case WaitTimeout:
+		return false, nil
+	case WaitLoad:
+		return events.isLoaded(), nil
+	case WaitNetworkIdle:
+		return events.isIdle(ct.waitStrategy.IdleTime), nil
+	case WaitSelector:
+		selector, err := json.Marshal(ct.waitStrategy.Selector)
+		if err != nil {
+			return false, err
+		}
+
+		// evaluation fails while the page is navigating, so errors just mean "not yet"
+		found, err := ct.instance.Evaluate(fmt.Sprintf("document.querySelector(%s) !== null", selector))
+		if err != nil {
+			return false, nil
+		}
+
+		return found == true, nil
+	}
+
+	return false, fmt.Errorf("%s `%s`", errorMessageUnknownWaitStrategy, ct.waitStrategy.Type)
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+
+	return b
+}
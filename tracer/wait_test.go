@@ -0,0 +1,150 @@
+package tracer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/raff/godet"
+)
+
+func TestNewWaitStrategy(t *testing.T) {
+	ws, err := NewWaitStrategy(WaitNetworkIdle, 0, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if ws.Timeout != DefaultWaitTimeout {
+		t.Errorf("expect default timeout %s but get %s", DefaultWaitTimeout, ws.Timeout)
+	}
+
+	if ws.IdleTime != DefaultWaitIdleTime {
+		t.Errorf("expect default idle time %s but get %s", DefaultWaitIdleTime, ws.IdleTime)
+	}
+
+	ws, err = NewWaitStrategy(WaitSelector, time.Second, 0, "#offer")
+	if err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if ws.Selector != "#offer" || ws.Timeout != time.Second {
+		t.Errorf("invalid wait strategy params %+v", ws)
+	}
+}
+
+func TestNewWaitStrategy_Invalid(t *testing.T) {
+	cases := []struct {
+		waitType string
+		timeout  time.Duration
+		idleTime time.Duration
+		selector string
+	}{
+		{waitType: "unknown"},
+		{waitType: WaitTimeout, timeout: -time.Second},
+		{waitType: WaitNetworkIdle, idleTime: -time.Second},
+		{waitType: WaitNetworkIdle, timeout: time.Second, idleTime: 2 * time.Second},
+		{waitType: WaitSelector},
+	}
+
+	for i, c := range cases {
+		if _, err := NewWaitStrategy(c.waitType, c.timeout, c.idleTime, c.selector); err == nil {
+			t.Errorf("case %d: expect error", i)
+		}
+	}
+}
+
+func TestChromeTracer_wait_Timeout(t *testing.T) {
+	ct := &ChromeTracer{instance: newFakeRemoteDebugger(), waitStrategy: &WaitStrategy{Type: WaitTimeout, Timeout: 200 * time.Millisecond}}
+
+	start := time.Now()
+	if err := ct.wait(newTraceEvents()); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expect to wait at least %s but waited %s", 200*time.Millisecond, elapsed)
+	}
+}
+
+func TestChromeTracer_wait_Load(t *testing.T) {
+	ct := &ChromeTracer{instance: newFakeRemoteDebugger(), waitStrategy: &WaitStrategy{Type: WaitLoad, Timeout: 5 * time.Second}}
+
+	events := newTraceEvents()
+	events.setMainFrameID("F394EA807250832376BE81745B17B0E9")
+	events.addRequest(makeTestDocumentRequest("1", "http://step0.test", "GET", "other", 10))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		events.loadFired(godet.Params{})
+	}()
+
+	start := time.Now()
+	if err := ct.wait(events); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("expect to stop waiting after load event but waited %s", elapsed)
+	}
+}
+
+func TestChromeTracer_wait_NetworkIdle(t *testing.T) {
+	ct := &ChromeTracer{instance: newFakeRemoteDebugger(), waitStrategy: &WaitStrategy{Type: WaitNetworkIdle, Timeout: 5 * time.Second, IdleTime: 200 * time.Millisecond}}
+
+	events := newTraceEvents()
+	events.requestStarted(godet.Params{"requestId": "1"})
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		events.requestFinished(godet.Params{"requestId": "1"})
+	}()
+
+	start := time.Now()
+	if err := ct.wait(events); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < 500*time.Millisecond || elapsed >= 2*time.Second {
+		t.Errorf("expect to wait for request to finish and idle time to pass but waited %s", elapsed)
+	}
+}
+
+func TestChromeTracer_wait_Selector(t *testing.T) {
+	fake := newFakeRemoteDebugger()
+	calls := 0
+	fake.evaluate = func(expr string) (interface{}, error) {
+		calls++
+
+		if expr != `document.querySelector("#offer") !== null` {
+			t.Errorf("unexpected expression `%s`", expr)
+		}
+
+		switch calls {
+		case 1:
+			return nil, errors.New("execution context was destroyed")
+		case 2:
+			return false, nil
+		}
+
+		return true, nil
+	}
+
+	ct := &ChromeTracer{instance: fake, waitStrategy: &WaitStrategy{Type: WaitSelector, Timeout: 5 * time.Second, Selector: "#offer"}}
+
+	if err := ct.wait(newTraceEvents()); err != nil {
+		t.Fatalf("unexpected error `%s`", err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expect 3 selector evaluations but get %d", calls)
+	}
+}
+
+func TestChromeTracer_wait_NoStrategy(t *testing.T) {
+	ct := &ChromeTracer{instance: newFakeRemoteDebugger()}
+
+	if err := ct.wait(newTraceEvents()); err == nil || err.Error() != errorMessageWaitStrategyNotExists {
+		t.Errorf("expect error: %s but got %v", errorMessageWaitStrategyNotExists, err)
+	}
+}